@@ -0,0 +1,64 @@
+package main
+
+import (
+    "os"      // for reading environment variables
+    "strconv" // for parsing numeric and boolean settings
+    "time"    // for parsing duration settings
+
+    "github.com/sirupsen/logrus" // warn about malformed settings
+)
+
+// envString returns the value of the environment variable key,
+// or def when it is unset or empty.
+func envString(key, def string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return def
+}
+
+// envDuration parses the environment variable key as a time.Duration
+// (e.g. "5s", "250ms"). Missing or malformed values fall back to def;
+// malformed ones are logged so a typo does not go unnoticed.
+func envDuration(key string, def time.Duration) time.Duration {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil {
+        logrus.WithField("key", key).Warnf("invalid duration %q, using default %s", v, def)
+        return def
+    }
+    return d
+}
+
+// envInt parses the environment variable key as an integer.
+// Missing or malformed values fall back to def.
+func envInt(key string, def int) int {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil {
+        logrus.WithField("key", key).Warnf("invalid integer %q, using default %d", v, def)
+        return def
+    }
+    return n
+}
+
+// envBool parses the environment variable key as a boolean ("true", "1",
+// "false", "0", ...). Missing or malformed values fall back to def.
+func envBool(key string, def bool) bool {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    b, err := strconv.ParseBool(v)
+    if err != nil {
+        logrus.WithField("key", key).Warnf("invalid boolean %q, using default %t", v, def)
+        return def
+    }
+    return b
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+    "errors"   // mapping storage errors
+    "net/http" // HTTP request/response types and cookies
+    "regexp"   // user name and token ID validation
+    "strings"  // the reserved guest name prefix
+    "time"     // session and token timestamps
+
+    "github.com/adrian/gif-backend/models"  // provider-neutral response schema
+    "github.com/adrian/gif-backend/storage" // persisted users and tokens
+    "github.com/gorilla/mux"                // path variables
+)
+
+// DefaultSessionTTL is how long a session cookie lasts when
+// AccountConfig.SessionTTL is zero.
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+// maxTokenLabelLength caps API token labels, in characters.
+const maxTokenLabelLength = 50
+
+// userNamePattern matches the user names we accept.
+var userNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// guestPrefix starts the name of every guest account; sign-up can't use it.
+const guestPrefix = "guest-"
+
+// tokenIDPattern matches the IDs storage assigns to tokens.
+var tokenIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// AccountConfig configures an AccountHandler.
+type AccountConfig struct {
+    // AllowSignup lets anyone create an account with POST /api/users.
+    AllowSignup bool
+
+    // AllowGuests lets any browser get a guest account, which lasts as long
+    // as its session, with POST /api/guests. Anonymous clients then cause
+    // storage writes, so it suits deployments that can afford them.
+    AllowGuests bool
+
+    // SessionTTL is how long a session cookie lasts; 0 means DefaultSessionTTL.
+    SessionTTL time.Duration
+
+    // SecureCookies marks the session cookie Secure, so browsers only send
+    // it over HTTPS. Enable it whenever the API is served over TLS.
+    SecureCookies bool
+}
+
+// AccountHandler serves the account endpoints: sign-up, the signed-in user,
+// browser sessions, API tokens and search history.
+type AccountHandler struct {
+    store storage.Store // where users and tokens are persisted
+    cfg   AccountConfig // sign-up and session settings
+}
+
+// NewAccountHandler returns an AccountHandler that persists to store.
+func NewAccountHandler(store storage.Store, cfg AccountConfig) *AccountHandler {
+    if cfg.SessionTTL <= 0 {
+        cfg.SessionTTL = DefaultSessionTTL
+    }
+    return &AccountHandler{store: store, cfg: cfg}
+}
+
+// signupRequest is the body of POST /api/users.
+type signupRequest struct {
+    Name string `json:"name"`
+}
+
+// tokenRequest is the body of POST /api/tokens.
+type tokenRequest struct {
+    Label string `json:"label"`
+}
+
+// Signup handles POST /api/users with {"name": "..."}. It creates the
+// account and its first API token, answering 201 with the token secret,
+// which is shown only this once.
+func (h *AccountHandler) Signup(w http.ResponseWriter, r *http.Request) {
+    // 1) Sign-up may be closed; the name must be well-formed and free.
+    if !h.cfg.AllowSignup {
+        writeError(w, r, http.StatusForbidden, "Sign-up is disabled")
+        return
+    }
+    var req signupRequest
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    if !userNamePattern.MatchString(req.Name) {
+        writeInvalidField(w, r, "name", "must be 3 to 32 letters, digits, '_', '.' or '-'")
+        return
+    }
+    if strings.HasPrefix(strings.ToLower(req.Name), guestPrefix) {
+        writeInvalidField(w, r, "name", "must not start with '"+guestPrefix+"'")
+        return
+    }
+    user, err := h.store.CreateUser(r.Context(), req.Name)
+    if errors.Is(err, storage.ErrExists) {
+        writeError(w, r, http.StatusConflict, "That name is taken")
+        return
+    }
+    if err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+
+    // 2) Issue the first API token.
+    token, secret, ok := h.issueToken(w, r, user.ID, "default", false)
+    if !ok {
+        return
+    }
+    writePrivateJSON(w, http.StatusCreated, models.NewTokenResponse{
+        Version: models.SchemaVersion,
+        Data:    apiToken(token),
+        Token:   secret,
+        User:    &user,
+    })
+}
+
+// CreateGuest handles POST /api/guests. It gives an anonymous browser a
+// guest account signed in with a session cookie, answering 201 with the
+// user. The guest has no API token, so it lasts only as long as its
+// session, which GET /api/me renews.
+func (h *AccountHandler) CreateGuest(w http.ResponseWriter, r *http.Request) {
+    if !h.cfg.AllowGuests {
+        writeError(w, r, http.StatusForbidden, "Guest accounts are disabled")
+        return
+    }
+    session, secret := h.newToken("", "", true)
+    user, err := h.store.CreateGuest(r.Context(), guestPrefix+newRequestID(), session)
+    if err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+    h.setSessionCookie(w, secret, session.ExpiresAt)
+    writePrivateJSON(w, http.StatusCreated, models.UserResponse{Version: models.SchemaVersion, Data: user})
+}
+
+// Me handles GET /api/me, returning the signed-in user. A session past half
+// its lifetime is renewed, so a browser in regular use stays signed in.
+func (h *AccountHandler) Me(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    token, _ := tokenFrom(r.Context())
+    if c, err := r.Cookie(sessionCookie); err == nil && token.Session && time.Until(token.ExpiresAt) < h.cfg.SessionTTL/2 {
+        expiresAt := time.Now().UTC().Add(h.cfg.SessionTTL)
+        if err := h.store.RenewToken(r.Context(), user.ID, token.ID, expiresAt); err != nil {
+            writeStorageError(w, r, err, "")
+            return
+        }
+        h.setSessionCookie(w, c.Value, expiresAt)
+    }
+    writePrivateJSON(w, http.StatusOK, models.UserResponse{Version: models.SchemaVersion, Data: user})
+}
+
+// CreateSession handles POST /api/session. A client signed in with an API
+// token gets an HttpOnly session cookie in exchange, so a browser app can
+// stop holding the token. A session can't start another, or it could
+// outlive its TTL forever. It answers 204.
+func (h *AccountHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    if current, _ := tokenFrom(r.Context()); current.Session {
+        writeError(w, r, http.StatusForbidden, "Sessions can only be started with an API token")
+        return
+    }
+    token, secret, ok := h.issueToken(w, r, user.ID, "", true)
+    if !ok {
+        return
+    }
+    h.setSessionCookie(w, secret, token.ExpiresAt)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteSession handles DELETE /api/session, signing the browser out: the
+// session is revoked and its cookie cleared. It answers 204.
+func (h *AccountHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+    if token, ok := tokenFrom(r.Context()); ok && token.Session {
+        if err := h.store.RevokeToken(r.Context(), token.UserID, token.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+            writeStorageError(w, r, err, "")
+            return
+        }
+    }
+    clearSessionCookie(w)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTokens handles GET /api/tokens, returning the user's API tokens
+// without their secrets.
+func (h *AccountHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    tokens, err := h.store.Tokens(r.Context(), user.ID)
+    if err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+    data := make([]models.APIToken, len(tokens))
+    for i, t := range tokens {
+        data[i] = apiToken(t)
+    }
+    writePrivateJSON(w, http.StatusOK, models.TokensResponse{Version: models.SchemaVersion, Data: data})
+}
+
+// CreateToken handles POST /api/tokens with an optional {"label": "..."},
+// answering 201 with the new token's secret. Guests can't have one, or they
+// would outlive their sessions.
+func (h *AccountHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    if user.Guest {
+        writeError(w, r, http.StatusForbidden, "Guest accounts can't create API tokens")
+        return
+    }
+    var req tokenRequest
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    if reason := checkText(req.Label, maxTokenLabelLength); reason != "" {
+        writeInvalidField(w, r, "label", reason)
+        return
+    }
+    token, secret, ok := h.issueToken(w, r, user.ID, req.Label, false)
+    if !ok {
+        return
+    }
+    writePrivateJSON(w, http.StatusCreated, models.NewTokenResponse{Version: models.SchemaVersion, Data: apiToken(token), Token: secret})
+}
+
+// RevokeToken handles DELETE /api/tokens/{id}, answering 204. Revoking the
+// token the request was made with is allowed; it stops working at once.
+func (h *AccountHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    id := mux.Vars(r)["id"]
+    if !tokenIDPattern.MatchString(id) {
+        writeError(w, r, http.StatusBadRequest, "Invalid token id")
+        return
+    }
+    if err := h.store.RevokeToken(r.Context(), user.ID, id); err != nil {
+        writeStorageError(w, r, err, "Token not found")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// History handles GET /api/history, returning the user's recent searches,
+// newest first.
+func (h *AccountHandler) History(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    history, err := h.store.History(r.Context(), user.ID)
+    if err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+    if history == nil {
+        history = []models.HistoryEntry{} // always an array, never null
+    }
+    writePrivateJSON(w, http.StatusOK, models.HistoryResponse{Version: models.SchemaVersion, Data: history})
+}
+
+// ClearHistory handles DELETE /api/history, answering 204.
+func (h *AccountHandler) ClearHistory(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    if err := h.store.ClearHistory(r.Context(), user.ID); err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// issueToken creates and stores a token for userID, as newToken. On failure
+// it writes the error response and returns false.
+func (h *AccountHandler) issueToken(w http.ResponseWriter, r *http.Request, userID, label string, session bool) (storage.Token, string, bool) {
+    token, secret := h.newToken(userID, label, session)
+    if err := h.store.AddToken(r.Context(), token); err != nil {
+        writeStorageError(w, r, err, "")
+        return storage.Token{}, "", false
+    }
+    return token, secret, true
+}
+
+// newToken returns a token for userID and its secret: a session, which
+// expires after the session TTL, or an API token, which doesn't.
+func (h *AccountHandler) newToken(userID, label string, session bool) (storage.Token, string) {
+    secret, hash := newTokenSecret()
+    now := time.Now().UTC()
+    token := storage.Token{
+        ID:        newRequestID(), // 16 random hex characters
+        UserID:    userID,
+        Hash:      hash,
+        Label:     label,
+        Session:   session,
+        CreatedAt: now,
+    }
+    if session {
+        token.ExpiresAt = now.Add(h.cfg.SessionTTL)
+    }
+    return token, secret
+}
+
+// setSessionCookie hands the browser the session secret as an HttpOnly
+// cookie lasting until expiresAt.
+func (h *AccountHandler) setSessionCookie(w http.ResponseWriter, secret string, expiresAt time.Time) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     sessionCookie,
+        Value:    secret,
+        Path:     "/",
+        Expires:  expiresAt,
+        HttpOnly: true,
+        Secure:   h.cfg.SecureCookies,
+        SameSite: http.SameSiteLaxMode,
+    })
+}
+
+// apiToken returns the public description of t.
+func apiToken(t storage.Token) models.APIToken {
+    return models.APIToken{ID: t.ID, Label: t.Label, CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+}
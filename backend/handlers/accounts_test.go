@@ -0,0 +1,235 @@
+package handlers
+
+import (
+    "context"           // seeding the store directly
+    "encoding/json"     // to decode account responses
+    "net/http"          // for HTTP status codes, methods and cookies
+    "net/http/httptest" // to create fake Requests and ResponseRecorders
+    "path/filepath"     // data file inside the test's temp dir
+    "strings"           // request bodies
+    "testing"           // the Go testing framework
+    "time"              // session expiry
+
+    "github.com/adrian/gif-backend/models"  // account schema
+    "github.com/adrian/gif-backend/storage" // file-backed store
+    "github.com/adrian/gif-backend/utils"   // provider types
+    "github.com/gorilla/mux"                // to route path variables
+)
+
+// newAccountsRouter returns a router serving the account, favorites and
+// search endpoints behind AuthMiddleware, from a fresh file store.
+func newAccountsRouter(t *testing.T, stub *stubProvider) *mux.Router {
+    t.Helper()
+    store, err := storage.OpenFileStore(filepath.Join(t.TempDir(), "store.json"))
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    t.Cleanup(func() { store.Close() }) // write the history before the temp dir goes
+    accounts := NewAccountHandler(store, AccountConfig{AllowSignup: true, AllowGuests: true})
+    favorites := NewFavoritesHandler(store, stub, RatingPolicy{})
+    gifs := NewGifHandler(stub, RatingPolicy{}, nil, store)
+    r := mux.NewRouter()
+    r.Use(AuthMiddleware(store))
+    r.HandleFunc("/api/users", accounts.Signup).Methods("POST")
+    r.HandleFunc("/api/guests", accounts.CreateGuest).Methods("POST")
+    r.HandleFunc("/api/me", accounts.Me).Methods("GET")
+    r.HandleFunc("/api/session", accounts.CreateSession).Methods("POST")
+    r.HandleFunc("/api/session", accounts.DeleteSession).Methods("DELETE")
+    r.HandleFunc("/api/tokens", accounts.ListTokens).Methods("GET")
+    r.HandleFunc("/api/tokens", accounts.CreateToken).Methods("POST")
+    r.HandleFunc("/api/tokens/{id}", accounts.RevokeToken).Methods("DELETE")
+    r.HandleFunc("/api/history", accounts.History).Methods("GET")
+    r.HandleFunc("/api/favorites", favorites.ListFavorites).Methods("GET")
+    r.HandleFunc("/api/favorites", favorites.AddFavorite).Methods("POST")
+    r.HandleFunc("/api/search", gifs.SearchGIFs).Methods("GET")
+    return r
+}
+
+// serveAs sends a request through router, authenticated with the given
+// Bearer token and/or session cookie when they are set.
+func serveAs(router http.Handler, method, target, body, token string, session *http.Cookie) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, target, strings.NewReader(body))
+    if body != "" {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    if session != nil {
+        req.AddCookie(session)
+    }
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, req)
+    return w
+}
+
+// signup creates an account and returns its first API token.
+func signup(t *testing.T, router http.Handler, name string) models.NewTokenResponse {
+    t.Helper()
+    w := serveAs(router, http.MethodPost, "/api/users", `{"name":"`+name+`"}`, "", nil)
+    if w.Code != http.StatusCreated {
+        t.Fatalf("signup %s: expected status 201; got %d: %s", name, w.Code, w.Body.String())
+    }
+    var resp models.NewTokenResponse
+    json.NewDecoder(w.Body).Decode(&resp)
+    return resp
+}
+
+// TestAccounts walks through signing up, using the API token, exchanging it
+// for a session cookie, per-user data and history, signing out and
+// revoking the token.
+func TestAccounts(t *testing.T) {
+    stub := &stubProvider{}
+    router := newAccountsRouter(t, stub)
+
+    // 1) Sign up; names are unique and the token works straight away.
+    alice := signup(t, router, "alice")
+    if !strings.HasPrefix(alice.Token, tokenPrefix) || alice.User == nil || alice.User.Name != "alice" {
+        t.Fatalf("unexpected signup response %+v", alice)
+    }
+    if w := serveAs(router, http.MethodPost, "/api/users", `{"name":"ALICE"}`, "", nil); w.Code != http.StatusConflict {
+        t.Errorf("expected status 409 for a taken name; got %d", w.Code)
+    }
+    if w := serveAs(router, http.MethodGet, "/api/me", "", alice.Token, nil); w.Code != http.StatusOK {
+        t.Errorf("expected status 200 with the token; got %d", w.Code)
+    }
+
+    // 2) No credentials or an unknown token is a 401 with a challenge;
+    //    other Bearer values are left to the rating and rate limit policies.
+    for _, token := range []string{"", tokenPrefix + "nope", "rating-client-token"} {
+        w := serveAs(router, http.MethodGet, "/api/me", "", token, nil)
+        if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+            t.Errorf("token %q: expected a 401 challenge; got %d", token, w.Code)
+        }
+    }
+
+    // 3) Exchange the token for an HttpOnly session cookie, which signs in too.
+    w := serveAs(router, http.MethodPost, "/api/session", "", alice.Token, nil)
+    cookies := w.Result().Cookies()
+    if w.Code != http.StatusNoContent || len(cookies) != 1 || !cookies[0].HttpOnly {
+        t.Fatalf("expected status 204 and an HttpOnly cookie; got %d, %+v", w.Code, cookies)
+    }
+    session := cookies[0]
+    if w := serveAs(router, http.MethodGet, "/api/me", "", "", session); w.Code != http.StatusOK {
+        t.Errorf("expected status 200 with the session; got %d", w.Code)
+    }
+    if w := serveAs(router, http.MethodPost, "/api/session", "", "", session); w.Code != http.StatusForbidden {
+        t.Errorf("expected status 403 starting a session from a session; got %d", w.Code)
+    }
+
+    // 4) Favorites are per user.
+    stub.gif = utils.Gif{ID: "a1", Rating: "g"}
+    serveAs(router, http.MethodPost, "/api/favorites", `{"id":"a1"}`, "", session)
+    bob := signup(t, router, "bob")
+    var favs models.FavoritesResponse
+    json.NewDecoder(serveAs(router, http.MethodGet, "/api/favorites", "", bob.Token, nil).Body).Decode(&favs)
+    if len(favs.Data) != 0 {
+        t.Errorf("expected bob to see no favorites; got %+v", favs.Data)
+    }
+
+    // 5) Fresh searches are recorded; paging through results is not.
+    serveAs(router, http.MethodGet, "/api/search?q=cats", "", "", session)
+    serveAs(router, http.MethodGet, "/api/search?q=dogs&page=2", "", "", session)
+    var history models.HistoryResponse
+    json.NewDecoder(serveAs(router, http.MethodGet, "/api/history", "", alice.Token, nil).Body).Decode(&history)
+    if len(history.Data) != 1 || history.Data[0].Query != "cats" {
+        t.Errorf("expected [cats] in the history; got %+v", history.Data)
+    }
+
+    // 6) Signing out revokes the session; the stale cookie is then cleared
+    //    and the 401 says the session expired.
+    if w := serveAs(router, http.MethodDelete, "/api/session", "", "", session); w.Code != http.StatusNoContent {
+        t.Errorf("expected status 204 signing out; got %d", w.Code)
+    }
+    w = serveAs(router, http.MethodGet, "/api/me", "", "", session)
+    if cookies := w.Result().Cookies(); w.Code != http.StatusUnauthorized || len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+        t.Errorf("expected a 401 clearing the cookie; got %d, %+v", w.Code, cookies)
+    }
+    var problem Problem
+    json.NewDecoder(w.Body).Decode(&problem)
+    if problem.Cause != causeSessionExpired {
+        t.Errorf("expected cause %q; got %q", causeSessionExpired, problem.Cause)
+    }
+
+    // 7) Revoking the API token stops it working; only its owner can.
+    var tokens models.TokensResponse
+    json.NewDecoder(serveAs(router, http.MethodGet, "/api/tokens", "", alice.Token, nil).Body).Decode(&tokens)
+    if len(tokens.Data) != 1 || tokens.Data[0].ID != alice.Data.ID {
+        t.Fatalf("expected only the signup token, without the session; got %+v", tokens.Data)
+    }
+    if w := serveAs(router, http.MethodDelete, "/api/tokens/"+alice.Data.ID, "", bob.Token, nil); w.Code != http.StatusNotFound {
+        t.Errorf("expected status 404 revoking another user's token; got %d", w.Code)
+    }
+    serveAs(router, http.MethodDelete, "/api/tokens/"+alice.Data.ID, "", alice.Token, nil)
+    if w := serveAs(router, http.MethodGet, "/api/me", "", alice.Token, nil); w.Code != http.StatusUnauthorized {
+        t.Errorf("expected status 401 with a revoked token; got %d", w.Code)
+    }
+}
+
+// TestGuests verifies that a browser gets a guest account with a session
+// cookie, that guests can't hold API tokens, and that sign-up can't take a
+// guest's name.
+func TestGuests(t *testing.T) {
+    router := newAccountsRouter(t, &stubProvider{})
+
+    // 1) A guest is signed in by its cookie alone.
+    w := serveAs(router, http.MethodPost, "/api/guests", "", "", nil)
+    cookies := w.Result().Cookies()
+    if w.Code != http.StatusCreated || len(cookies) != 1 || !cookies[0].HttpOnly {
+        t.Fatalf("expected status 201 and an HttpOnly cookie; got %d, %+v", w.Code, cookies)
+    }
+    var me models.UserResponse
+    json.NewDecoder(serveAs(router, http.MethodGet, "/api/me", "", "", cookies[0]).Body).Decode(&me)
+    if !me.Data.Guest || !strings.HasPrefix(me.Data.Name, guestPrefix) {
+        t.Errorf("expected a guest; got %+v", me.Data)
+    }
+
+    // 2) It can't get an API token that would outlive the session.
+    if w := serveAs(router, http.MethodPost, "/api/tokens", `{}`, "", cookies[0]); w.Code != http.StatusForbidden {
+        t.Errorf("expected status 403 creating a token as a guest; got %d", w.Code)
+    }
+
+    // 3) Guest names are reserved.
+    if w := serveAs(router, http.MethodPost, "/api/users", `{"name":"Guest-me"}`, "", nil); w.Code != http.StatusBadRequest {
+        t.Errorf("expected status 400 signing up with a guest name; got %d", w.Code)
+    }
+}
+
+// TestMe_RenewsSession verifies that GET /api/me extends a session past
+// half its lifetime, in the store and in the cookie.
+func TestMe_RenewsSession(t *testing.T) {
+    ctx := context.Background()
+    store, err := storage.OpenFileStore(filepath.Join(t.TempDir(), "store.json"))
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    user, _ := store.CreateUser(ctx, "alice")
+    secret, hash := newTokenSecret()
+    now := time.Now()
+    store.AddToken(ctx, storage.Token{ID: "s1", UserID: user.ID, Hash: hash, Session: true, CreatedAt: now, ExpiresAt: now.Add(10 * time.Minute)})
+
+    h := AuthMiddleware(store)(http.HandlerFunc(NewAccountHandler(store, AccountConfig{SessionTTL: time.Hour}).Me))
+    w := serveAs(h, http.MethodGet, "/api/me", "", "", &http.Cookie{Name: sessionCookie, Value: secret})
+    cookies := w.Result().Cookies()
+    if w.Code != http.StatusOK || len(cookies) != 1 || time.Until(cookies[0].Expires) < 50*time.Minute {
+        t.Fatalf("expected status 200 and a cookie lasting about an hour; got %d, %+v", w.Code, cookies)
+    }
+    if _, token, _ := store.UserByToken(ctx, hash); time.Until(token.ExpiresAt) < 50*time.Minute {
+        t.Errorf("expected the stored session to be renewed; expires %v", token.ExpiresAt)
+    }
+}
+
+// TestSignup_Disabled verifies that sign-up and guests are closed unless allowed.
+func TestSignup_Disabled(t *testing.T) {
+    store, err := storage.OpenFileStore(filepath.Join(t.TempDir(), "store.json"))
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    accounts := NewAccountHandler(store, AccountConfig{})
+    if w := serveAs(http.HandlerFunc(accounts.Signup), http.MethodPost, "/api/users", `{"name":"alice"}`, "", nil); w.Code != http.StatusForbidden {
+        t.Errorf("expected status 403 signing up; got %d", w.Code)
+    }
+    if w := serveAs(http.HandlerFunc(accounts.CreateGuest), http.MethodPost, "/api/guests", "", "", nil); w.Code != http.StatusForbidden {
+        t.Errorf("expected status 403 for a guest; got %d", w.Code)
+    }
+}
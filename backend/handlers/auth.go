@@ -0,0 +1,150 @@
+package handlers
+
+import (
+    "context"         // carrying the user through the request
+    "crypto/rand"     // generating token secrets
+    "crypto/sha256"   // hashing token secrets
+    "encoding/base64" // formatting token secrets
+    "encoding/hex"    // formatting token hashes
+    "errors"          // telling unknown tokens from storage failures
+    "net/http"        // middleware types and cookies
+    "strings"         // recognizing our tokens
+
+    "github.com/adrian/gif-backend/models"  // the signed-in user
+    "github.com/adrian/gif-backend/storage" // token lookup
+)
+
+// tokenPrefix starts every token secret we issue. Bearer tokens without it
+// are left to the other users of the Authorization header, such as the
+// RATING_CLIENTS policy and the rate limiter.
+const tokenPrefix = "gx_"
+
+// sessionCookie is the name of the browser session cookie.
+const sessionCookie = "gx_session"
+
+// causeSessionExpired is the Problem.Cause of a 401 for a request whose
+// session cookie has expired or was signed out.
+const causeSessionExpired = "session_expired"
+
+// authKey is the context key for the signed-in user.
+type authKey struct{}
+
+// staleSessionKey is the context key marking a request that came with a
+// session cookie which no longer works.
+type staleSessionKey struct{}
+
+// authInfo is what AuthMiddleware stores in the context: the user and the
+// token or session they signed in with.
+type authInfo struct {
+    user  models.User
+    token storage.Token
+}
+
+// AuthMiddleware signs requests in. An "Authorization: Bearer gx_..." API
+// token takes precedence over the session cookie. An unknown or expired API
+// token is a 401, since the client asked to be someone; a stale session
+// cookie is cleared and the request carries on anonymously, though a 401
+// from requireUser then says the session expired. Either way, handlers read
+// the user with UserFrom.
+func AuthMiddleware(store storage.Store) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            // 1) An API token must be valid.
+            if secret := requestToken(r); strings.HasPrefix(secret, tokenPrefix) {
+                user, token, err := store.UserByToken(r.Context(), hashToken(secret))
+                switch {
+                case errors.Is(err, storage.ErrNotFound):
+                    writeUnauthorized(w, r, "Invalid or expired API token")
+                case err != nil:
+                    writeStorageError(w, r, err, "")
+                default:
+                    next.ServeHTTP(w, r.WithContext(withAuth(r.Context(), user, token)))
+                }
+                return
+            }
+
+            // 2) Otherwise a session cookie signs the browser in, if still valid.
+            if c, err := r.Cookie(sessionCookie); err == nil {
+                user, token, err := store.UserByToken(r.Context(), hashToken(c.Value))
+                switch {
+                case errors.Is(err, storage.ErrNotFound):
+                    clearSessionCookie(w)
+                    r = r.WithContext(context.WithValue(r.Context(), staleSessionKey{}, true))
+                case err != nil:
+                    writeStorageError(w, r, err, "")
+                    return
+                default:
+                    r = r.WithContext(withAuth(r.Context(), user, token))
+                }
+            }
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+// UserFrom returns the user AuthMiddleware signed the request in as, and
+// false for anonymous requests.
+func UserFrom(ctx context.Context) (models.User, bool) {
+    info, ok := ctx.Value(authKey{}).(authInfo)
+    return info.user, ok
+}
+
+// withAuth returns ctx carrying the signed-in user and their token.
+func withAuth(ctx context.Context, user models.User, token storage.Token) context.Context {
+    return context.WithValue(ctx, authKey{}, authInfo{user: user, token: token})
+}
+
+// tokenFrom returns the token or session the request was signed in with.
+func tokenFrom(ctx context.Context) (storage.Token, bool) {
+    info, ok := ctx.Value(authKey{}).(authInfo)
+    return info.token, ok
+}
+
+// requireUser returns the signed-in user, or writes a 401 and returns false.
+// The 401 tells a browser whose session ran out so, letting the app say so
+// rather than starting over silently.
+func requireUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+    user, ok := UserFrom(r.Context())
+    switch {
+    case ok:
+    case r.Context().Value(staleSessionKey{}) != nil:
+        w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+        writeProblem(w, r, Problem{Status: http.StatusUnauthorized, Detail: "Your session has expired", Cause: causeSessionExpired})
+    default:
+        writeUnauthorized(w, r, "Sign in with an API token or a session")
+    }
+    return user, ok
+}
+
+// writeUnauthorized writes a 401 problem document with a Bearer challenge.
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, detail string) {
+    w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+    writeError(w, r, http.StatusUnauthorized, detail)
+}
+
+// newTokenSecret returns a fresh token secret and the hash to store for it.
+func newTokenSecret() (secret, hash string) {
+    b := make([]byte, 32)
+    rand.Read(b)
+    secret = tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+    return secret, hashToken(secret)
+}
+
+// hashToken returns the stored form of a token secret: its hex SHA-256.
+// Secrets are random, so no salt or slow hash is needed.
+func hashToken(secret string) string {
+    sum := sha256.Sum256([]byte(secret))
+    return hex.EncodeToString(sum[:])
+}
+
+// clearSessionCookie tells the browser to drop the session cookie.
+func clearSessionCookie(w http.ResponseWriter) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     sessionCookie,
+        Value:    "",
+        Path:     "/",
+        MaxAge:   -1,
+        HttpOnly: true,
+        SameSite: http.SameSiteLaxMode,
+    })
+}
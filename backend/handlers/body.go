@@ -0,0 +1,61 @@
+package handlers
+
+import (
+    "encoding/json" // decoding request bodies
+    "errors"        // detecting oversized bodies
+    "io"            // io.EOF after the single JSON value
+    "mime"          // parsing Content-Type
+    "net/http"      // request and response types
+)
+
+// maxBodyBytes caps JSON request bodies.
+const maxBodyBytes = 64 << 10
+
+// decodeBody decodes r's JSON body, which must be a single value with no
+// unknown fields, into dst. On failure it writes a problem document (400,
+// 413 or 415) and returns false.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+    // 1) Only JSON is accepted.
+    if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+        writeError(w, r, http.StatusUnsupportedMediaType, "Request body must be application/json")
+        return false
+    }
+
+    // 2) Decode exactly one value, refusing fields we don't know.
+    dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+    dec.DisallowUnknownFields()
+    err := dec.Decode(dst)
+    if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+        err = errors.New("unexpected data after the JSON value")
+    }
+
+    // 3) Report what went wrong.
+    var tooLarge *http.MaxBytesError
+    switch {
+    case err == nil:
+        return true
+    case errors.As(err, &tooLarge):
+        writeError(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+    default:
+        writeError(w, r, http.StatusBadRequest, "Malformed JSON body: "+err.Error())
+    }
+    return false
+}
+
+// writeInvalidField writes a 400 problem document for one invalid body field.
+func writeInvalidField(w http.ResponseWriter, r *http.Request, name, reason string) {
+    writeProblem(w, r, Problem{
+        Status:        http.StatusBadRequest,
+        Detail:        "Field '" + name + "' " + reason,
+        InvalidParams: []FieldError{{Name: name, Reason: reason}},
+    })
+}
+
+// writePrivateJSON writes v as a JSON response with status. It is for
+// per-user data that changes often, so the response must not be cached.
+func writePrivateJSON(w http.ResponseWriter, status int, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
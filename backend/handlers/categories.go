@@ -0,0 +1,101 @@
+package handlers
+
+import (
+    "encoding/json" // JSON encoding for responses
+    "net/http"      // HTTP request/response types
+    "regexp"        // category slug validation
+    "strings"       // case-folding slugs
+
+    "github.com/adrian/gif-backend/models" // provider-neutral response schema
+    "github.com/adrian/gif-backend/utils"  // cache info
+    "github.com/gorilla/mux"               // path variables
+)
+
+// categorySlugPattern matches the encoded category names we accept
+// ("animals", "reactions", "tv-shows").
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+
+// GetCategories handles GET /api/categories. It lists the top-level
+// categories, each with a representative GIF and its subcategory names.
+func (h *GifHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+    // 1) Ask the provider (through the long-TTL cache) for the category tree.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    cats, err := h.provider.Categories(ctx)
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to fetch categories", "Category not found")
+        return
+    }
+
+    // 2) Encode them in our schema, without GIFs the caller may not see.
+    w.Header().Set("Content-Type", "application/json")
+    writeCacheHeaders(w, cacheInfo)
+    json.NewEncoder(w).Encode(models.FromGiphyCategories(h.allowedCategories(r, cats)))
+}
+
+// GetCategory handles GET /api/categories/{name}. It returns one category
+// with its subcategories, each carrying a representative GIF.
+func (h *GifHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+    // 1) Validate the slug from the path.
+    slug := strings.ToLower(mux.Vars(r)["name"])
+    if !categorySlugPattern.MatchString(slug) {
+        writeError(w, r, http.StatusBadRequest, "Invalid category name")
+        return
+    }
+
+    // 2) Look the category up in the (cached) list, for its name and GIF.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    cats, err := h.provider.Categories(ctx)
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to fetch categories", "Category not found")
+        return
+    }
+    var category *utils.Category
+    for i := range cats {
+        if cats[i].NameEncoded == slug {
+            category = &cats[i]
+            break
+        }
+    }
+    if category == nil {
+        writeError(w, r, http.StatusNotFound, "Category not found")
+        return
+    }
+
+    // 3) Fetch its subcategories with their GIFs. The second lookup's cache
+    //    outcome is the one reported, since it decides how fresh the body is.
+    subs, err := h.provider.Subcategories(ctx, slug)
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to fetch subcategories", "Category not found")
+        return
+    }
+
+    // 4) Combine: the category's own fields plus the detailed subcategories,
+    //    without GIFs the caller may not see.
+    combined := h.allowedCategories(r, []utils.Category{{
+        Name:          category.Name,
+        NameEncoded:   category.NameEncoded,
+        Gif:           category.Gif,
+        Subcategories: subs,
+    }})
+    out := models.FromGiphyCategory(combined[0])
+    w.Header().Set("Content-Type", "application/json")
+    writeCacheHeaders(w, cacheInfo)
+    json.NewEncoder(w).Encode(models.CategoryResponse{Version: models.SchemaVersion, Data: out})
+}
+
+// allowedCategories returns a copy of cats, and of their subcategories, in
+// which representative GIFs above the caller's maximum rating are left out.
+// cats itself is shared with the cache and must not change.
+func (h *GifHandler) allowedCategories(r *http.Request, cats []utils.Category) []utils.Category {
+    out := make([]utils.Category, len(cats))
+    for i, c := range cats {
+        if c.Gif != nil && !h.ratings.allows(r, c.Gif.Rating) {
+            c.Gif = nil
+        }
+        if c.Subcategories != nil {
+            c.Subcategories = h.allowedCategories(r, c.Subcategories)
+        }
+        out[i] = c
+    }
+    return out
+}
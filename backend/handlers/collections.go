@@ -0,0 +1,371 @@
+package handlers
+
+import (
+    "errors"   // mapping storage errors
+    "net/http" // HTTP request/response types
+    "regexp"   // collection ID validation
+    "strings"  // trimming names
+
+    "github.com/adrian/gif-backend/models"  // provider-neutral response schema
+    "github.com/adrian/gif-backend/storage" // persisted collections
+    "github.com/adrian/gif-backend/utils"   // provider used to snapshot GIFs
+    "github.com/gorilla/mux"                // path variables
+)
+
+// Collection field limits.
+const (
+    // maxCollectionNameLength caps collection names, in characters.
+    maxCollectionNameLength = 50
+
+    // maxDescriptionLength caps collection descriptions, in characters.
+    maxDescriptionLength = 280
+)
+
+// Details reported for missing and duplicate collections.
+const (
+    collectionNotFound = "Collection not found"
+    nameTaken          = "A collection with that name already exists"
+)
+
+// collectionIDPattern matches the IDs storage assigns to collections.
+var collectionIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// CollectionsHandler serves the /api/collections endpoints: named, ordered
+// sets of GIFs such as "reactions" or "deploy fails", owned by the
+// signed-in user. Like favorites, their GIFs are stored as snapshots taken
+// from the provider when added.
+type CollectionsHandler struct {
+    store    storage.Store  // where collections are persisted
+    provider utils.Provider // source of the GIF snapshots
+    ratings  RatingPolicy   // which GIFs each caller may save
+}
+
+// NewCollectionsHandler returns a CollectionsHandler that persists to store
+// and snapshots GIFs from provider, under the given rating policy.
+func NewCollectionsHandler(store storage.Store, provider utils.Provider, ratings RatingPolicy) *CollectionsHandler {
+    return &CollectionsHandler{store: store, provider: provider, ratings: ratings}
+}
+
+// collectionRequest is the body of POST /api/collections and
+// PATCH /api/collections/{id}. Omitted fields are left unchanged.
+type collectionRequest struct {
+    Name        *string `json:"name"`
+    Description *string `json:"description"`
+}
+
+// addCollectionGIFRequest is the body of POST /api/collections/{id}/gifs.
+// Without a position the GIF is appended.
+type addCollectionGIFRequest struct {
+    ID       string `json:"id"`
+    Position *int   `json:"position"`
+}
+
+// moveCollectionGIFRequest is the body of PATCH
+// /api/collections/{id}/gifs/{gif}. Collection moves the GIF into another
+// collection; Position places it (the end when omitted).
+type moveCollectionGIFRequest struct {
+    Position   *int   `json:"position"`
+    Collection string `json:"collection"`
+}
+
+// ListCollections handles GET /api/collections, returning every
+// collection's name, description and GIF count, oldest first.
+func (h *CollectionsHandler) ListCollections(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    cols, err := h.store.Collections(r.Context(), user.ID)
+    if err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+    if cols == nil {
+        cols = []models.Collection{} // always an array, never null
+    }
+    writePrivateJSON(w, http.StatusOK, models.CollectionsResponse{Version: models.SchemaVersion, Data: cols})
+}
+
+// CreateCollection handles POST /api/collections with {"name": "...",
+// "description": "..."}, answering 201 with a Location header.
+func (h *CollectionsHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+    // 1) Require a user, then decode and validate the body; a name is required.
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    var req collectionRequest
+    if !decodeBody(w, r, &req) || !validCollectionFields(w, r, &req) {
+        return
+    }
+    if req.Name == nil {
+        writeInvalidField(w, r, "name", "is required")
+        return
+    }
+    description := ""
+    if req.Description != nil {
+        description = *req.Description
+    }
+
+    // 2) Create it.
+    col, err := h.store.CreateCollection(r.Context(), user.ID, *req.Name, description)
+    if err != nil {
+        writeCollectionError(w, r, err, nameTaken)
+        return
+    }
+    w.Header().Set("Location", "/api/collections/"+col.ID)
+    writePrivateJSON(w, http.StatusCreated, models.CollectionResponse{Version: models.SchemaVersion, Data: col})
+}
+
+// GetCollection handles GET /api/collections/{id}, returning the collection
+// and one page of its GIFs (limit and page, as on the other list endpoints).
+func (h *CollectionsHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
+    // 1) Require a user, then validate the ID and pagination.
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    id, ok := collectionID(w, r)
+    if !ok {
+        return
+    }
+    params := parseQuery(r)
+    limit, offset := params.pagination()
+    if !params.valid(w) {
+        return
+    }
+
+    // 2) Read the page.
+    col, items, err := h.store.Collection(r.Context(), user.ID, id, offset, limit)
+    if err != nil {
+        writeStorageError(w, r, err, collectionNotFound)
+        return
+    }
+    if items == nil {
+        items = []models.CollectionItem{} // always an array, never null
+    }
+    writePrivateJSON(w, http.StatusOK, models.CollectionPageResponse{
+        Version: models.SchemaVersion,
+        Data:    col,
+        Items:   items,
+        Pagination: models.Pagination{
+            Offset:     offset,
+            Limit:      limit,
+            Count:      len(items),
+            TotalCount: col.GIFCount,
+            HasMore:    offset+len(items) < col.GIFCount,
+        },
+    })
+}
+
+// UpdateCollection handles PATCH /api/collections/{id} to rename a
+// collection or change its description.
+func (h *CollectionsHandler) UpdateCollection(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    id, ok := collectionID(w, r)
+    if !ok {
+        return
+    }
+    var req collectionRequest
+    if !decodeBody(w, r, &req) || !validCollectionFields(w, r, &req) {
+        return
+    }
+    col, err := h.store.UpdateCollection(r.Context(), user.ID, id, storage.CollectionUpdate{Name: req.Name, Description: req.Description})
+    if err != nil {
+        writeCollectionError(w, r, err, nameTaken)
+        return
+    }
+    writePrivateJSON(w, http.StatusOK, models.CollectionResponse{Version: models.SchemaVersion, Data: col})
+}
+
+// DeleteCollection handles DELETE /api/collections/{id}, answering 204.
+func (h *CollectionsHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    id, ok := collectionID(w, r)
+    if !ok {
+        return
+    }
+    if err := h.store.DeleteCollection(r.Context(), user.ID, id); err != nil {
+        writeStorageError(w, r, err, collectionNotFound)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// AddGIF handles POST /api/collections/{id}/gifs with {"id": "...",
+// "position": n}. The response is 201 for a new GIF and 200 if the
+// collection already held it.
+func (h *CollectionsHandler) AddGIF(w http.ResponseWriter, r *http.Request) {
+    // 1) Require a user, then validate the collection ID and the body.
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    id, ok := collectionID(w, r)
+    if !ok {
+        return
+    }
+    var req addCollectionGIFRequest
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    if !gifIDPattern.MatchString(req.ID) {
+        writeInvalidField(w, r, "id", "must be a GIF id")
+        return
+    }
+    position, ok := positionField(w, r, req.Position)
+    if !ok {
+        return
+    }
+
+    // 2) Snapshot the GIF and store it.
+    gif, ok := snapshotGIF(w, r, h.provider, h.ratings, req.ID)
+    if !ok {
+        return
+    }
+    item, created, err := h.store.AddToCollection(r.Context(), user.ID, id, gif, position)
+    if err != nil {
+        writeStorageError(w, r, err, collectionNotFound)
+        return
+    }
+    writePrivateJSON(w, createdStatus(created), models.CollectionItemResponse{Version: models.SchemaVersion, Data: item})
+}
+
+// RemoveGIF handles DELETE /api/collections/{id}/gifs/{gif}, answering 204.
+func (h *CollectionsHandler) RemoveGIF(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    id, gifID, ok := collectionGIF(w, r)
+    if !ok {
+        return
+    }
+    if err := h.store.RemoveFromCollection(r.Context(), user.ID, id, gifID); err != nil {
+        writeStorageError(w, r, err, "GIF is not in the collection")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// MoveGIF handles PATCH /api/collections/{id}/gifs/{gif} with
+// {"position": n} to reorder a GIF, and/or {"collection": "..."} to move
+// it into another collection. It answers 204.
+func (h *CollectionsHandler) MoveGIF(w http.ResponseWriter, r *http.Request) {
+    // 1) Require a user, then validate the path and body; there must be
+    //    something to do.
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    id, gifID, ok := collectionGIF(w, r)
+    if !ok {
+        return
+    }
+    var req moveCollectionGIFRequest
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    position, ok := positionField(w, r, req.Position)
+    if !ok {
+        return
+    }
+    toID := id
+    switch {
+    case req.Collection != "" && !collectionIDPattern.MatchString(req.Collection):
+        writeInvalidField(w, r, "collection", "must be a collection id")
+        return
+    case req.Collection != "":
+        toID = req.Collection
+    case req.Position == nil:
+        writeInvalidField(w, r, "position", "is required unless collection is given")
+        return
+    }
+
+    // 2) Move it.
+    if err := h.store.MoveInCollection(r.Context(), user.ID, id, gifID, toID, position); err != nil {
+        writeCollectionError(w, r, err, "GIF is already in the target collection")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// validCollectionFields trims and validates the name and description of
+// req in place. On failure it writes a 400 and returns false.
+func validCollectionFields(w http.ResponseWriter, r *http.Request, req *collectionRequest) bool {
+    if req.Name != nil {
+        name := strings.TrimSpace(*req.Name)
+        reason := checkText(name, maxCollectionNameLength)
+        if name == "" {
+            reason = "must not be empty"
+        }
+        if reason != "" {
+            writeInvalidField(w, r, "name", reason)
+            return false
+        }
+        req.Name = &name
+    }
+    if req.Description != nil {
+        description := strings.TrimSpace(*req.Description)
+        if reason := checkText(description, maxDescriptionLength); reason != "" {
+            writeInvalidField(w, r, "description", reason)
+            return false
+        }
+        req.Description = &description
+    }
+    return true
+}
+
+// positionField returns the optional zero-based position, or -1 (the end)
+// when omitted. A negative position is a 400, written here.
+func positionField(w http.ResponseWriter, r *http.Request, position *int) (int, bool) {
+    if position == nil {
+        return -1, true
+    }
+    if *position < 0 {
+        writeInvalidField(w, r, "position", "must be zero or more")
+        return 0, false
+    }
+    return *position, true
+}
+
+// collectionID returns the validated {id} path variable, or writes a 400.
+func collectionID(w http.ResponseWriter, r *http.Request) (string, bool) {
+    id := mux.Vars(r)["id"]
+    if !collectionIDPattern.MatchString(id) {
+        writeError(w, r, http.StatusBadRequest, "Invalid collection id")
+        return "", false
+    }
+    return id, true
+}
+
+// collectionGIF returns the validated {id} and {gif} path variables, or
+// writes a 400.
+func collectionGIF(w http.ResponseWriter, r *http.Request) (id, gifID string, ok bool) {
+    if id, ok = collectionID(w, r); !ok {
+        return "", "", false
+    }
+    gifID = mux.Vars(r)["gif"]
+    if !gifIDPattern.MatchString(gifID) {
+        writeError(w, r, http.StatusBadRequest, "Invalid GIF id")
+        return "", "", false
+    }
+    return id, gifID, true
+}
+
+// writeCollectionError maps a storage error from a change that can
+// conflict to a problem document; storage.ErrExists is a 409 with conflict
+// as its detail.
+func writeCollectionError(w http.ResponseWriter, r *http.Request, err error, conflict string) {
+    if errors.Is(err, storage.ErrExists) {
+        writeError(w, r, http.StatusConflict, conflict)
+        return
+    }
+    writeStorageError(w, r, err, collectionNotFound)
+}
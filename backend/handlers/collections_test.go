@@ -0,0 +1,112 @@
+package handlers
+
+import (
+    "encoding/json" // to decode collection responses
+    "net/http"      // for HTTP status codes and method constants
+    "path/filepath" // data file inside the test's temp dir
+    "testing"       // the Go testing framework
+
+    "github.com/adrian/gif-backend/models"  // collection schema
+    "github.com/adrian/gif-backend/storage" // file-backed store
+    "github.com/adrian/gif-backend/utils"   // provider types
+    "github.com/gorilla/mux"                // to route path variables
+)
+
+// newCollectionsRouter returns a router serving the collections endpoints
+// from a fresh file store, with stub as the provider, signed in as a new user.
+func newCollectionsRouter(t *testing.T, stub *stubProvider) http.Handler {
+    t.Helper()
+    store, err := storage.OpenFileStore(filepath.Join(t.TempDir(), "store.json"))
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    h := NewCollectionsHandler(store, stub, RatingPolicy{})
+    r := mux.NewRouter()
+    r.HandleFunc("/api/collections", h.ListCollections).Methods("GET")
+    r.HandleFunc("/api/collections", h.CreateCollection).Methods("POST")
+    r.HandleFunc("/api/collections/{id}", h.GetCollection).Methods("GET")
+    r.HandleFunc("/api/collections/{id}", h.UpdateCollection).Methods("PATCH")
+    r.HandleFunc("/api/collections/{id}", h.DeleteCollection).Methods("DELETE")
+    r.HandleFunc("/api/collections/{id}/gifs", h.AddGIF).Methods("POST")
+    r.HandleFunc("/api/collections/{id}/gifs/{gif}", h.MoveGIF).Methods("PATCH")
+    r.HandleFunc("/api/collections/{id}/gifs/{gif}", h.RemoveGIF).Methods("DELETE")
+    return signIn(t, store, r)
+}
+
+// TestCollections walks a collection through its life: create, rename,
+// add GIFs at positions, page through them, move one across, and delete.
+func TestCollections(t *testing.T) {
+    stub := &stubProvider{}
+    router := newCollectionsRouter(t, stub)
+
+    // create makes a collection and returns it.
+    create := func(body string) models.Collection {
+        t.Helper()
+        w := serveJSON(router, http.MethodPost, "/api/collections", body)
+        if w.Code != http.StatusCreated {
+            t.Fatalf("create: expected status 201; got %d: %s", w.Code, w.Body.String())
+        }
+        var resp models.CollectionResponse
+        json.NewDecoder(w.Body).Decode(&resp)
+        if w.Header().Get("Location") != "/api/collections/"+resp.Data.ID {
+            t.Errorf("unexpected Location %q", w.Header().Get("Location"))
+        }
+        return resp.Data
+    }
+
+    // 1) Create two; names are trimmed, required and unique.
+    reactions := create(`{"name":"  Reactions ","description":"for chat"}`)
+    deploys := create(`{"name":"Deploy fails"}`)
+    if reactions.Name != "Reactions" {
+        t.Errorf("expected a trimmed name; got %q", reactions.Name)
+    }
+    if w := serveJSON(router, http.MethodPost, "/api/collections", `{"description":"x"}`); w.Code != http.StatusBadRequest {
+        t.Errorf("expected status 400 without a name; got %d", w.Code)
+    }
+    if w := serveJSON(router, http.MethodPatch, "/api/collections/"+reactions.ID, `{"name":"deploy FAILS"}`); w.Code != http.StatusConflict {
+        t.Errorf("expected status 409 renaming onto a taken name; got %d", w.Code)
+    }
+
+    // 2) Add three GIFs, the last at the front.
+    base := "/api/collections/" + reactions.ID
+    for _, add := range []struct{ id, body string }{
+        {"a1", `{"id":"a1"}`},
+        {"b2", `{"id":"b2"}`},
+        {"c3", `{"id":"c3","position":0}`},
+    } {
+        stub.gif = utils.Gif{ID: add.id, Rating: "g"}
+        if w := serveJSON(router, http.MethodPost, base+"/gifs", add.body); w.Code != http.StatusCreated {
+            t.Fatalf("add %s: expected status 201; got %d: %s", add.id, w.Code, w.Body.String())
+        }
+    }
+
+    // 3) The second page of two holds the last GIF.
+    w := serveJSON(router, http.MethodGet, base+"?limit=2&page=2", "")
+    var page models.CollectionPageResponse
+    json.NewDecoder(w.Body).Decode(&page)
+    if len(page.Items) != 1 || page.Items[0].GIF.ID != "b2" || page.Pagination.TotalCount != 3 || page.Pagination.HasMore {
+        t.Errorf("expected the last of [c3 a1 b2] on page 2; got %+v / %+v", page.Items, page.Pagination)
+    }
+
+    // 4) Move one into the other collection; the move needs something to do.
+    if w := serveJSON(router, http.MethodPatch, base+"/gifs/a1", `{}`); w.Code != http.StatusBadRequest {
+        t.Errorf("expected status 400 for an empty move; got %d", w.Code)
+    }
+    if w := serveJSON(router, http.MethodPatch, base+"/gifs/a1", `{"collection":"`+deploys.ID+`"}`); w.Code != http.StatusNoContent {
+        t.Fatalf("expected status 204 for a move; got %d: %s", w.Code, w.Body.String())
+    }
+
+    // 5) Delete the first; the list keeps the second with its GIF.
+    if w := serveJSON(router, http.MethodDelete, base, ""); w.Code != http.StatusNoContent {
+        t.Errorf("expected status 204; got %d", w.Code)
+    }
+    if w := serveJSON(router, http.MethodGet, base, ""); w.Code != http.StatusNotFound {
+        t.Errorf("expected status 404 after deleting; got %d", w.Code)
+    }
+    w = serveJSON(router, http.MethodGet, "/api/collections", "")
+    var list models.CollectionsResponse
+    json.NewDecoder(w.Body).Decode(&list)
+    if len(list.Data) != 1 || list.Data[0].ID != deploys.ID || list.Data[0].GIFCount != 1 {
+        t.Errorf("expected only the deploy collection with one GIF; got %+v", list.Data)
+    }
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+    "crypto/hmac"     // signing cursors
+    "crypto/rand"     // random key when no secret is configured
+    "crypto/sha256"   // HMAC hash
+    "encoding/base64" // URL-safe cursor encoding
+    "fmt"             // Link header formatting
+    "net/http"        // request and response types
+    "net/url"         // cursor payloads are query strings
+    "strconv"         // offsets
+    "strings"         // splitting cursors and joining links
+
+    "github.com/adrian/gif-backend/models" // pagination schema
+)
+
+// CursorCodec signs and verifies the opaque pagination cursors handed to
+// clients. A cursor carries the canonical query of one page (endpoint,
+// filters, limit and offset) plus an HMAC over it, so clients can follow
+// it but not alter it.
+type CursorCodec struct {
+    key []byte // HMAC-SHA256 key
+}
+
+// NewCursorCodec returns a codec that signs with secret. An empty secret
+// gets a random key, so cursors stop verifying when the process restarts
+// and are not accepted by other replicas.
+func NewCursorCodec(secret []byte) *CursorCodec {
+    if len(secret) == 0 {
+        secret = make([]byte, sha256.Size)
+        rand.Read(secret)
+    }
+    return &CursorCodec{key: secret}
+}
+
+// encode returns the cursor for the page of endpoint described by values.
+func (c *CursorCodec) encode(endpoint string, values url.Values) string {
+    payload := endpoint + "?" + values.Encode()
+    return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+        base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// decode verifies cursor and returns the query it carries. It fails if the
+// cursor is malformed, its signature doesn't match, or it was issued for
+// another endpoint.
+func (c *CursorCodec) decode(endpoint, cursor string) (url.Values, bool) {
+    // 1) Split and decode the payload and signature.
+    encPayload, encSig, ok := strings.Cut(cursor, ".")
+    if !ok {
+        return nil, false
+    }
+    payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+    if err != nil {
+        return nil, false
+    }
+    sig, err := base64.RawURLEncoding.DecodeString(encSig)
+    if err != nil || !hmac.Equal(sig, c.sign(string(payload))) {
+        return nil, false
+    }
+
+    // 2) The payload must belong to this endpoint.
+    name, query, _ := strings.Cut(string(payload), "?")
+    if name != endpoint {
+        return nil, false
+    }
+    values, err := url.ParseQuery(query)
+    if err != nil {
+        return nil, false
+    }
+    return values, true
+}
+
+// sign returns the HMAC of payload.
+func (c *CursorCodec) sign(payload string) []byte {
+    mac := hmac.New(sha256.New, c.key)
+    mac.Write([]byte(payload))
+    return mac.Sum(nil)
+}
+
+// paginate adds next/prev cursors to resp and advertises them in a Link
+// header (RFC 8288). page is the canonical query of the current page,
+// without its offset; offset is where the page starts. There is no next
+// page past maxOffset, and no previous page for the first one.
+func (h *GifHandler) paginate(w http.ResponseWriter, r *http.Request, endpoint string, page url.Values, offset int, resp *models.ListResponse) {
+    p := &resp.Pagination
+    var links []string
+
+    // link returns the cursor for the page at off and records its Link.
+    link := func(rel string, off int) string {
+        page.Set("offset", strconv.Itoa(off))
+        cursor := h.cursors.encode(endpoint, page)
+        links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, url.Values{"cursor": {cursor}}.Encode(), rel))
+        return cursor
+    }
+
+    if next := offset + p.Limit; p.HasMore && next <= maxOffset {
+        p.NextCursor = link("next", next)
+    }
+    if offset > 0 {
+        p.PrevCursor = link("prev", max(0, offset-p.Limit))
+    }
+    if len(links) > 0 {
+        w.Header().Set("Link", strings.Join(links, ", "))
+    }
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+    "encoding/json"     // to decode list responses
+    "net/http"          // for HTTP status codes and method constants
+    "net/http/httptest" // to create fake Requests and ResponseRecorders
+    "net/url"           // to build cursor requests
+    "strings"           // to tamper with cursors and inspect Link headers
+    "testing"           // the Go testing framework
+
+    "github.com/adrian/gif-backend/models" // list response schema
+    "github.com/adrian/gif-backend/utils"  // provider types
+)
+
+// TestSearchGIFs_Cursors verifies that following next_cursor repeats the
+// query at the next offset, that Link headers match the cursors, and that
+// tampered or foreign cursors are rejected.
+func TestSearchGIFs_Cursors(t *testing.T) {
+    stub := &stubProvider{search: utils.GiphyResponse{
+        Data:       []utils.Gif{{ID: "abc123"}},
+        Pagination: utils.Pagination{TotalCount: 100},
+    }}
+    h := NewGifHandler(stub, RatingPolicy{Deployment: RatingLimits{Max: "r"}}, NewCursorCodec([]byte("secret")), nil)
+
+    // search runs SearchGIFs with query and decodes a successful response.
+    search := func(query string) (models.ListResponse, http.Header) {
+        t.Helper()
+        w := httptest.NewRecorder()
+        h.SearchGIFs(w, httptest.NewRequest(http.MethodGet, "/api/search?"+query, nil))
+        if w.Code != http.StatusOK {
+            t.Fatalf("expected status 200 for %q; got %d: %s", query, w.Code, w.Body.String())
+        }
+        var resp models.ListResponse
+        if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+            t.Fatalf("decode error: %v", err)
+        }
+        return resp, w.Header()
+    }
+
+    // 1) The first page links forward only.
+    first, header := search("q=cats&limit=10&lang=es&rating=pg")
+    if first.Pagination.NextCursor == "" || first.Pagination.PrevCursor != "" {
+        t.Fatalf("expected only a next cursor; got %+v", first.Pagination)
+    }
+    next := url.Values{"cursor": {first.Pagination.NextCursor}}.Encode()
+    if link := header.Get("Link"); link != `</api/search?`+next+`>; rel="next"` {
+        t.Errorf("unexpected Link header: %q", link)
+    }
+
+    // 2) Following it repeats the query at the next offset, and links both ways.
+    second, header := search(next)
+    want := utils.SearchParams{Type: utils.MediaGIFs, Query: "cats", Rating: "pg", Lang: "es", Limit: 10, Offset: 10}
+    if stub.lastSearch != want {
+        t.Errorf("expected %+v; got %+v", want, stub.lastSearch)
+    }
+    if second.Pagination.NextCursor == "" || second.Pagination.PrevCursor == "" || !strings.Contains(header.Get("Link"), `rel="prev"`) {
+        t.Errorf("expected next and prev links; got %+v / %q", second.Pagination, header.Get("Link"))
+    }
+
+    // 3) Cursors that were altered, issued elsewhere, or mixed with page are rejected.
+    other := NewGifHandler(stub, RatingPolicy{Deployment: RatingLimits{Max: "r"}}, NewCursorCodec([]byte("other")), nil)
+    cases := map[string]string{
+        "tampered":     "cursor=" + strings.Replace(first.Pagination.NextCursor, ".", "x.", 1),
+        "wrong secret": "cursor=" + other.cursors.encode("search", url.Values{"q": {"dogs"}}),
+        "wrong route":  "cursor=" + h.cursors.encode("trending", url.Values{"limit": {"10"}}),
+        "with page":    next + "&page=2",
+    }
+    for name, query := range cases {
+        w := httptest.NewRecorder()
+        h.SearchGIFs(w, httptest.NewRequest(http.MethodGet, "/api/search?"+query, nil))
+        if w.Code != http.StatusBadRequest {
+            t.Errorf("%s: expected status 400; got %d", name, w.Code)
+        }
+    }
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+    "context"  // to recognise deadline errors
+    "errors"   // for unwrapping typed errors
+    "net/http" // HTTP status codes and response writer
+    "strconv"  // for formatting Retry-After
+    "time"     // Retry-After durations
+
+    "github.com/adrian/gif-backend/utils" // UpstreamError type
+)
+
+// providerFailure is how a provider error should be reported to clients.
+type providerFailure struct {
+    status         int           // HTTP status to respond with
+    message        string        // client-facing message
+    retryAfter     time.Duration // Retry-After hint, if any
+    cause          string        // machine-readable cause for Problem.Cause
+    upstreamStatus int           // Giphy's status code, if it answered
+}
+
+// Causes reported in Problem.Cause for provider failures.
+const (
+    causeUpstreamRateLimited = "upstream_rate_limited" // Giphy answered 429
+    causeUpstreamAuth        = "upstream_auth"         // Giphy rejected our API key
+    causeUpstreamNotFound    = "upstream_not_found"    // Giphy has no such resource
+    causeUpstreamUnavailable = "upstream_unavailable"  // Giphy answered 5xx
+    causeUpstreamError       = "upstream_error"        // any other non-2xx from Giphy
+    causeQuotaExhausted      = "quota_exhausted"       // our own limiter protected the API key
+    causeCircuitOpen         = "circuit_open"          // the breaker is failing fast
+    causeUpstreamTimeout     = "upstream_timeout"      // the call ran out of time
+)
+
+// classifyProviderError maps an error returned by a utils.Provider to a
+// status and message. Upstream rate limits, auth failures, missing
+// resources and outages get distinct statuses; anything else falls back to
+// a 500 with the handler-specific message. A missing resource is reported
+// with notFound, naming what the handler looked up; empty means "Not found".
+func classifyProviderError(err error, fallback, notFound string) providerFailure {
+    // 1) Typed upstream failures carry Giphy's status code.
+    var upErr *utils.UpstreamError
+    if errors.As(err, &upErr) {
+        f := providerFailure{upstreamStatus: upErr.StatusCode}
+        switch {
+        case upErr.IsRateLimited():
+            // Pass Giphy's back-off hint through so clients slow down too.
+            f.status, f.message, f.cause = http.StatusTooManyRequests, "Upstream rate limit exceeded, try again later", causeUpstreamRateLimited
+            f.retryAfter = upErr.RetryAfter
+        case upErr.IsAuth():
+            // Our API key was rejected: the client can't fix this, so it's a gateway error.
+            f.status, f.message, f.cause = http.StatusBadGateway, "Upstream rejected our credentials", causeUpstreamAuth
+        case upErr.IsNotFound():
+            if notFound == "" {
+                notFound = "Not found"
+            }
+            f.status, f.message, f.cause = http.StatusNotFound, notFound, causeUpstreamNotFound
+        case upErr.IsServerError():
+            f.status, f.message, f.cause = http.StatusServiceUnavailable, "Upstream service unavailable", causeUpstreamUnavailable
+        default:
+            f.status, f.message, f.cause = http.StatusBadGateway, fallback, causeUpstreamError
+        }
+        return f
+    }
+
+    // 2) Our client-side limiter refused the call to protect the API quota.
+    var quotaErr *utils.QuotaError
+    if errors.As(err, &quotaErr) {
+        return providerFailure{
+            status:     http.StatusTooManyRequests,
+            message:    "Upstream request budget exhausted, try again later",
+            retryAfter: quotaErr.RetryAfter,
+            cause:      causeQuotaExhausted,
+        }
+    }
+
+    // 3) The circuit breaker is open: fail fast instead of waiting on Giphy.
+    if errors.Is(err, utils.ErrCircuitOpen) {
+        return providerFailure{status: http.StatusServiceUnavailable, message: "Upstream service unavailable", cause: causeCircuitOpen}
+    }
+
+    // 4) The upstream call ran out of time.
+    if errors.Is(err, context.DeadlineExceeded) {
+        return providerFailure{status: http.StatusGatewayTimeout, message: "Upstream request timed out", cause: causeUpstreamTimeout}
+    }
+
+    // 5) Anything else (decode errors, network failures) is a generic 500.
+    return providerFailure{status: http.StatusInternalServerError, message: fallback}
+}
+
+// writeProviderError writes the problem response for a provider error, as
+// classified by classifyProviderError.
+func writeProviderError(w http.ResponseWriter, r *http.Request, err error, fallback, notFound string) {
+    f := classifyProviderError(err, fallback, notFound)
+    setRetryAfter(w, f.retryAfter)
+    writeProblem(w, r, Problem{
+        Status:         f.status,
+        Detail:         f.message,
+        Cause:          f.cause,
+        UpstreamStatus: f.upstreamStatus,
+    })
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds (rounded up),
+// or leaves it unset when d is zero.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+    if d <= 0 {
+        return
+    }
+    w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(d)))
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+    "errors"   // mapping storage errors
+    "fmt"      // limit messages
+    "net/http" // HTTP request/response types
+
+    "github.com/adrian/gif-backend/models"  // provider-neutral response schema
+    "github.com/adrian/gif-backend/storage" // persisted favorites
+    "github.com/adrian/gif-backend/utils"   // provider used to snapshot GIFs
+    "github.com/gorilla/mux"                // path variables
+    "github.com/sirupsen/logrus"            // logging storage failures
+)
+
+// FavoritesHandler serves the /api/favorites endpoints, which require a
+// signed-in user and only see that user's favorites. Favorites live in
+// a storage.Store as snapshots of our GIF schema, taken from the provider
+// when they are added, so listing them never goes upstream and keeps
+// working while Giphy is down.
+type FavoritesHandler struct {
+    store    storage.Store  // where favorites are persisted
+    provider utils.Provider // source of the GIF snapshots
+    ratings  RatingPolicy   // which GIFs each caller may favorite
+}
+
+// NewFavoritesHandler returns a FavoritesHandler that persists to store and
+// snapshots GIFs from provider, under the given rating policy.
+func NewFavoritesHandler(store storage.Store, provider utils.Provider, ratings RatingPolicy) *FavoritesHandler {
+    return &FavoritesHandler{store: store, provider: provider, ratings: ratings}
+}
+
+// addFavoriteRequest is the body of POST /api/favorites.
+type addFavoriteRequest struct {
+    ID string `json:"id"`
+}
+
+// reorderFavoritesRequest is the body of PUT /api/favorites/order.
+type reorderFavoritesRequest struct {
+    IDs []string `json:"ids"`
+}
+
+// ListFavorites handles GET /api/favorites, returning every favorite in the
+// user's order.
+func (h *FavoritesHandler) ListFavorites(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    favs, err := h.store.Favorites(r.Context(), user.ID)
+    if err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+    writeFavorites(w, http.StatusOK, favs)
+}
+
+// AddFavorite handles POST /api/favorites with {"id": "..."}. The GIF is
+// looked up through the provider and its snapshot stored; the response is
+// 201 for a new favorite and 200 if it already was one.
+func (h *FavoritesHandler) AddFavorite(w http.ResponseWriter, r *http.Request) {
+    // 1) Require a user, then decode and validate the body.
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    var req addFavoriteRequest
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    if !gifIDPattern.MatchString(req.ID) {
+        writeInvalidField(w, r, "id", "must be a GIF id")
+        return
+    }
+
+    // 2) Snapshot the GIF as it is now.
+    gif, ok := snapshotGIF(w, r, h.provider, h.ratings, req.ID)
+    if !ok {
+        return
+    }
+
+    // 3) Store it.
+    fav, created, err := h.store.AddFavorite(r.Context(), user.ID, gif)
+    if err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+    writePrivateJSON(w, createdStatus(created), models.FavoriteResponse{Version: models.SchemaVersion, Data: fav})
+}
+
+// RemoveFavorite handles DELETE /api/favorites/{id}, answering 204.
+func (h *FavoritesHandler) RemoveFavorite(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    id := mux.Vars(r)["id"]
+    if !gifIDPattern.MatchString(id) {
+        writeError(w, r, http.StatusBadRequest, "Invalid GIF id")
+        return
+    }
+    if err := h.store.RemoveFavorite(r.Context(), user.ID, id); err != nil {
+        writeStorageError(w, r, err, "GIF is not a favorite")
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderFavorites handles PUT /api/favorites/order with {"ids": [...]},
+// which must list every favorite exactly once, in the new order.
+func (h *FavoritesHandler) ReorderFavorites(w http.ResponseWriter, r *http.Request) {
+    user, ok := requireUser(w, r)
+    if !ok {
+        return
+    }
+    var req reorderFavoritesRequest
+    if !decodeBody(w, r, &req) {
+        return
+    }
+    favs, err := h.store.ReorderFavorites(r.Context(), user.ID, req.IDs)
+    if errors.Is(err, storage.ErrOrderMismatch) {
+        writeInvalidField(w, r, "ids", "must list every favorite exactly once")
+        return
+    }
+    if err != nil {
+        writeStorageError(w, r, err, "")
+        return
+    }
+    writeFavorites(w, http.StatusOK, favs)
+}
+
+// writeFavorites writes favs as a FavoritesResponse.
+func writeFavorites(w http.ResponseWriter, status int, favs []models.Favorite) {
+    if favs == nil {
+        favs = []models.Favorite{} // always an array, never null
+    }
+    writePrivateJSON(w, status, models.FavoritesResponse{Version: models.SchemaVersion, Data: favs})
+}
+
+// snapshotGIF looks up GIF id through provider and returns it in our schema,
+// to be stored. Callers may only save GIFs they're allowed to see under
+// ratings. On failure it writes the error response and returns false.
+func snapshotGIF(w http.ResponseWriter, r *http.Request, provider utils.Provider, ratings RatingPolicy, id string) (models.GIF, bool) {
+    gif, err := provider.GetByID(r.Context(), id)
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to fetch GIF", "GIF not found")
+        return models.GIF{}, false
+    }
+    if !ratings.allows(r, gif.Rating) {
+        writeError(w, r, http.StatusForbidden, ratingForbidden)
+        return models.GIF{}, false
+    }
+    return models.FromGiphy(gif), true
+}
+
+// createdStatus is 201 for a newly saved item and 200 for one that already
+// existed, so clients can tell an idempotent repeat apart.
+func createdStatus(created bool) int {
+    if created {
+        return http.StatusCreated
+    }
+    return http.StatusOK
+}
+
+// writeStorageError maps a storage error to a problem document: 404 with
+// notFound for storage.ErrNotFound, 409 when a limit is reached, and a
+// logged 500 for anything else.
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error, notFound string) {
+    var limit *storage.LimitError
+    switch {
+    case errors.Is(err, storage.ErrNotFound):
+        writeError(w, r, http.StatusNotFound, notFound)
+    case errors.As(err, &limit):
+        writeError(w, r, http.StatusConflict, fmt.Sprintf("At most %d %s are allowed", limit.Max, limit.What))
+    default:
+        logrus.WithFields(logrus.Fields{
+            "error":      err,
+            "request_id": RequestIDFrom(r.Context()),
+        }).Error("storage failure")
+        writeError(w, r, http.StatusInternalServerError, "Failed to access storage")
+    }
+}
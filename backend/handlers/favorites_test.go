@@ -0,0 +1,130 @@
+package handlers
+
+import (
+    "context"           // creating the test user
+    "encoding/json"     // to decode favorites responses
+    "errors"            // to simulate a provider outage
+    "net/http"          // for HTTP status codes and method constants
+    "net/http/httptest" // to create fake Requests and ResponseRecorders
+    "path/filepath"     // data file inside the test's temp dir
+    "strings"           // request bodies
+    "testing"           // the Go testing framework
+
+    "github.com/adrian/gif-backend/models"  // favorites schema
+    "github.com/adrian/gif-backend/storage" // file-backed store
+    "github.com/adrian/gif-backend/utils"   // provider types
+    "github.com/gorilla/mux"                // to route path variables
+)
+
+// newFavoritesRouter returns a router serving the favorites endpoints from a
+// fresh file store, with stub as the provider, signed in as a new user.
+func newFavoritesRouter(t *testing.T, stub *stubProvider, ratings RatingPolicy) http.Handler {
+    t.Helper()
+    store, err := storage.OpenFileStore(filepath.Join(t.TempDir(), "store.json"))
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    h := NewFavoritesHandler(store, stub, ratings)
+    r := mux.NewRouter()
+    r.HandleFunc("/api/favorites", h.ListFavorites).Methods("GET")
+    r.HandleFunc("/api/favorites", h.AddFavorite).Methods("POST")
+    r.HandleFunc("/api/favorites/order", h.ReorderFavorites).Methods("PUT")
+    r.HandleFunc("/api/favorites/{id}", h.RemoveFavorite).Methods("DELETE")
+    return signIn(t, store, r)
+}
+
+// signIn creates a user in store and returns next with every request
+// signed in as them, as AuthMiddleware would.
+func signIn(t *testing.T, store storage.Store, next http.Handler) http.Handler {
+    t.Helper()
+    user, err := store.CreateUser(context.Background(), "tester")
+    if err != nil {
+        t.Fatalf("create user error: %v", err)
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        next.ServeHTTP(w, r.WithContext(withAuth(r.Context(), user, storage.Token{UserID: user.ID})))
+    })
+}
+
+// serveJSON sends a request with a JSON body through router.
+func serveJSON(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, target, strings.NewReader(body))
+    if body != "" {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, req)
+    return w
+}
+
+// TestFavorites verifies adding, listing, reordering and removing favorites,
+// and that the list is served from snapshots while the provider is down.
+func TestFavorites(t *testing.T) {
+    stub := &stubProvider{}
+    router := newFavoritesRouter(t, stub, RatingPolicy{})
+
+    // 1) Add two GIFs; adding one again is a 200, not a 201.
+    for _, id := range []string{"a1", "b2"} {
+        stub.gif = utils.Gif{ID: id, Title: "gif " + id, Rating: "g"}
+        if w := serveJSON(router, http.MethodPost, "/api/favorites", `{"id":"`+id+`"}`); w.Code != http.StatusCreated {
+            t.Fatalf("add %s: expected status 201; got %d: %s", id, w.Code, w.Body.String())
+        }
+    }
+    if w := serveJSON(router, http.MethodPost, "/api/favorites", `{"id":"b2"}`); w.Code != http.StatusOK {
+        t.Errorf("expected status 200 for an existing favorite; got %d", w.Code)
+    }
+
+    // 2) Reorder them; an incomplete order is rejected.
+    if w := serveJSON(router, http.MethodPut, "/api/favorites/order", `{"ids":["b2"]}`); w.Code != http.StatusBadRequest {
+        t.Errorf("expected status 400 for an incomplete order; got %d", w.Code)
+    }
+    if w := serveJSON(router, http.MethodPut, "/api/favorites/order", `{"ids":["b2","a1"]}`); w.Code != http.StatusOK {
+        t.Fatalf("expected status 200 for a reorder; got %d: %s", w.Code, w.Body.String())
+    }
+
+    // 3) With the provider failing, the list still comes from the snapshots.
+    stub.err = errors.New("giphy is down")
+    w := serveJSON(router, http.MethodGet, "/api/favorites", "")
+    var resp models.FavoritesResponse
+    if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+        t.Fatalf("decode error: %v", err)
+    }
+    if len(resp.Data) != 2 || resp.Data[0].GIF.ID != "b2" || resp.Data[1].GIF.Title != "gif a1" {
+        t.Errorf("expected [b2 a1] with snapshots; got %+v", resp.Data)
+    }
+
+    // 4) Remove one; removing it again is a 404.
+    if w := serveJSON(router, http.MethodDelete, "/api/favorites/a1", ""); w.Code != http.StatusNoContent {
+        t.Errorf("expected status 204; got %d", w.Code)
+    }
+    if w := serveJSON(router, http.MethodDelete, "/api/favorites/a1", ""); w.Code != http.StatusNotFound {
+        t.Errorf("expected status 404; got %d", w.Code)
+    }
+}
+
+// TestAddFavorite_Rejects verifies the body checks and the rating policy.
+func TestAddFavorite_Rejects(t *testing.T) {
+    stub := &stubProvider{gif: utils.Gif{ID: "r1", Rating: "r"}}
+    router := newFavoritesRouter(t, stub, RatingPolicy{Deployment: RatingLimits{Max: "pg"}})
+
+    cases := []struct {
+        name   string
+        body   string
+        ctype  string
+        status int
+    }{
+        {"not json", `id=r1`, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+        {"unknown field", `{"id":"r1","url":"http://evil"}`, "application/json", http.StatusBadRequest},
+        {"invalid id", `{"id":"../etc"}`, "application/json", http.StatusBadRequest},
+        {"rating above max", `{"id":"r1"}`, "application/json", http.StatusForbidden},
+    }
+    for _, tc := range cases {
+        req := httptest.NewRequest(http.MethodPost, "/api/favorites", strings.NewReader(tc.body))
+        req.Header.Set("Content-Type", tc.ctype)
+        w := httptest.NewRecorder()
+        router.ServeHTTP(w, req)
+        if w.Code != tc.status {
+            t.Errorf("%s: expected status %d; got %d", tc.name, tc.status, w.Code)
+        }
+    }
+}
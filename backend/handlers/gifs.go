@@ -1,53 +1,112 @@
 package handlers
 
 import (
+    "context"                         // SearchHistory method signature
     "encoding/json"                   // JSON encoding for responses
     "net/http"                        // HTTP request/response types
-    "os"                              // for reading environment variables
+    "net/url"                         // canonical page queries for cursors
     "strconv"                         // for converting strings to integers
+    "time"                            // for the Age header
 
-    "github.com/adrian/gif-backend/utils" // our internal utils for fetching GIF data
+    "github.com/adrian/gif-backend/models" // provider-neutral response schema
+    "github.com/adrian/gif-backend/utils"  // our internal utils for fetching GIF data
 )
 
-// GetTrending handles GET requests to /api/trending.
-// It reads pagination parameters, calls the utils.FetchTrending function,
-// and writes a JSON response containing the trending GIFs.
-func GetTrending(w http.ResponseWriter, r *http.Request) {
-    // 1) Parse optional query parameters "limit" and "page"
-    //    Default to 12 items per page and page 1 if not provided.
-    limit := r.URL.Query().Get("limit")
-    page  := r.URL.Query().Get("page")
-    if limit == "" {
-        limit = "12"
-    }
-    if page == "" {
-        page = "1"
+// GifHandler serves the GIF endpoints under /api.
+// It holds the utils.Provider used to fetch GIFs, so the source (Giphy, a
+// caching wrapper, a test stub, ...) is chosen by whoever constructs it,
+// the content rating policy applied to every request, the codec that
+// signs pagination cursors, and where signed-in users' searches are recorded.
+type GifHandler struct {
+    provider utils.Provider // upstream source of GIF data
+    ratings  RatingPolicy   // default/maximum content ratings
+    cursors  *CursorCodec   // signs and verifies pagination cursors
+    history  SearchHistory  // records searches; nil records nothing
+}
+
+// SearchHistory records the searches of signed-in users. storage.Store
+// satisfies it.
+type SearchHistory interface {
+    AddSearch(ctx context.Context, userID, query string) error
+}
+
+// NewGifHandler returns a GifHandler that fetches GIFs from provider under
+// the given rating policy. The zero RatingPolicy defaults to and caps at
+// "g". A nil cursors gets a codec with a random key; a nil history
+// keeps no search history.
+func NewGifHandler(provider utils.Provider, ratings RatingPolicy, cursors *CursorCodec, history SearchHistory) *GifHandler {
+    if cursors == nil {
+        cursors = NewCursorCodec(nil)
     }
+    return &GifHandler{provider: provider, ratings: ratings, cursors: cursors, history: history}
+}
 
-    // 2) Convert limit and page from strings to integers.
-    //    We ignore errors here, falling back to defaults above.
-    limitInt, _ := strconv.Atoi(limit)
-    pageInt,  _ := strconv.Atoi(page)
-    
-    // 3) Check that the GIPHY_API_KEY environment variable is set.
-    //    If it's missing, we cannot call Giphy, so return a 500 error.
-    if os.Getenv("GIPHY_API_KEY") == "" {
-        http.Error(w, "Server misconfigured: missing GIPHY_API_KEY", http.StatusInternalServerError)
+// GetTrending handles GET requests to /api/trending.
+// It reads pagination parameters (or a cursor), asks the provider for
+// trending GIFs, and writes a JSON response containing the trending GIFs.
+func (h *GifHandler) GetTrending(w http.ResponseWriter, r *http.Request) {
+    // 1) Parse and validate the query parameters; every invalid one is
+    //    reported together in a single 400. A cursor stands in for them all.
+    params := parseQuery(r)
+    params.cursor(h.cursors, "trending")
+    limitInt, offset := params.pagination()  // page size and zero-based offset
+    mediaType := params.mediaType()          // GIFs (default) or stickers
+    rating := params.rating(h.ratings)       // effective content rating
+    region := params.region()                // optional country code
+    if !params.valid(w) {
         return
     }
 
-    // 4) Call the typed FetchTrending utility with our pagination values.
-    //    This returns a GiphyResponse struct or an error.
-    respData, err := utils.FetchTrending(limitInt, pageInt)
+    // 2) Ask the provider for trending GIFs with our pagination values.
+    //    The request context is passed along so a disconnected client cancels the call,
+    //    and carries a CacheInfo the cache layer fills in.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    respData, err := h.provider.Trending(ctx, utils.TrendingParams{
+        Type:   mediaType,
+        Rating: rating,
+        Region: region,
+        Limit:  limitInt,
+        Offset: offset,
+    })
     if err != nil {
-        // If fetching from Giphy fails, return a 500 error to the client.
-        http.Error(w, "Failed to fetch trending GIFs", http.StatusInternalServerError)
+        // Map the provider error (rate limit, auth, outage, ...) to a response.
+        writeProviderError(w, r, err, "Failed to fetch trending GIFs", "")
         return
     }
 
-    // 5) On success, set the Content-Type header to application/json.
+    // 3) On success, set the Content-Type and cache headers.
     w.Header().Set("Content-Type", "application/json")
-    // 6) Encode the GiphyResponse struct directly to the HTTP response body.
-    //    json.NewEncoder(w) writes the JSON and a trailing newline.
-    json.NewEncoder(w).Encode(respData)
+    writeCacheHeaders(w, cacheInfo)
+    // 4) Map the provider response into our versioned schema, echo the
+    //    rating and region, link the neighbouring pages, and encode it.
+    //    json.NewEncoder(w) writes a trailing newline.
+    resp := models.FromGiphyResponse(respData, limitInt)
+    resp.Meta.Rating = rating
+    resp.Meta.Region = region
+    page := url.Values{"type": {string(mediaType)}, "rating": {rating}, "limit": {strconv.Itoa(limitInt)}}
+    if region != "" {
+        page.Set("region", region)
+    }
+    h.paginate(w, r, "trending", page, offset, &resp)
+    json.NewEncoder(w).Encode(resp)
+}
+
+// writeCacheHeaders reports how the cache layer answered: X-Cache carries
+// HIT/MISS/STALE, Age the seconds since the data was fetched upstream, and
+// stale responses get the matching Warning (110 stale, 111 revalidation failed).
+func writeCacheHeaders(w http.ResponseWriter, info *utils.CacheInfo) {
+    // No cache in front of the provider: nothing to report.
+    if info.Status == "" {
+        return
+    }
+
+    w.Header().Set("X-Cache", info.Status)
+    w.Header().Set("Age", strconv.Itoa(int(info.Age/time.Second)))
+    if info.Status == utils.CacheStale {
+        if info.RevalidationFailed {
+            w.Header().Set("Warning", `111 - "Revalidation Failed"`)
+        } else {
+            w.Header().Set("Warning", `110 - "Response is Stale"`)
+        }
+    }
 }
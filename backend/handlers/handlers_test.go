@@ -1,12 +1,77 @@
 package handlers
 
 import (
+    "context"            // Provider methods take a request context
+    "encoding/json"      // to decode JSON response bodies
     "net/http"           // for HTTP status codes and method constants
     "net/http/httptest"  // to create fake Request and ResponseRecorder
     "strings"            // for simple substring checks in response bodies
     "testing"            // the Go testing framework
+
+    "github.com/adrian/gif-backend/utils"                    // Provider interface and GIF types
+    "github.com/gorilla/mux"                                 // to populate path variables
+    "github.com/prometheus/client_golang/prometheus/testutil" // reading request metrics
 )
 
+// stubProvider is an in-memory utils.Provider used to exercise the handlers
+// without any network access. Each field is returned from the matching method.
+type stubProvider struct {
+    trending      utils.GiphyResponse
+    search        utils.GiphyResponse
+    gif           utils.Gif
+    gifs          []utils.Gif
+    tags          []utils.Tag
+    categories    []utils.Category
+    subcategories []utils.Category
+    err           error
+    lastTrending  utils.TrendingParams   // records the params of the last Trending call
+    lastSearch    utils.SearchParams     // records the params of the last Search call
+    lastTranslate utils.TranslateParams  // records the params of the last Translate call
+}
+
+func (s *stubProvider) Trending(ctx context.Context, p utils.TrendingParams) (utils.GiphyResponse, error) {
+    s.lastTrending = p
+    return s.trending, s.err
+}
+
+func (s *stubProvider) Search(ctx context.Context, p utils.SearchParams) (utils.GiphyResponse, error) {
+    s.lastSearch = p
+    return s.search, s.err
+}
+
+func (s *stubProvider) GetByID(ctx context.Context, id string) (utils.Gif, error) {
+    return s.gif, s.err
+}
+
+func (s *stubProvider) GetByIDs(ctx context.Context, ids []string) ([]utils.Gif, error) {
+    return s.gifs, s.err
+}
+
+func (s *stubProvider) Random(ctx context.Context, p utils.RandomParams) (utils.Gif, error) {
+    return s.gif, s.err
+}
+
+func (s *stubProvider) Translate(ctx context.Context, p utils.TranslateParams) (utils.Gif, error) {
+    s.lastTranslate = p
+    return s.gif, s.err
+}
+
+func (s *stubProvider) Autocomplete(ctx context.Context, p utils.AutocompleteParams) ([]utils.Tag, error) {
+    return s.tags, s.err
+}
+
+func (s *stubProvider) RelatedTerms(ctx context.Context, term string) ([]utils.Tag, error) {
+    return s.tags, s.err
+}
+
+func (s *stubProvider) Categories(ctx context.Context) ([]utils.Category, error) {
+    return s.categories, s.err
+}
+
+func (s *stubProvider) Subcategories(ctx context.Context, category string) ([]utils.Category, error) {
+    return s.subcategories, s.err
+}
+
 // TestHealthCheck verifies that the HealthCheck handler returns a 200 status
 // and the exact JSON payload {"status":"ok"}.
 func TestHealthCheck(t *testing.T) {
@@ -46,8 +111,8 @@ func TestSearchGIFs_MissingQuery(t *testing.T) {
     req := httptest.NewRequest(http.MethodGet, "/api/search", nil)
     w := httptest.NewRecorder()
 
-    // Invoke the SearchGIFs handler.
-    SearchGIFs(w, req)
+    // Invoke the SearchGIFs handler; the provider must not be reached.
+    NewGifHandler(&stubProvider{}, RatingPolicy{}, nil, nil).SearchGIFs(w, req)
 
     resp := w.Result()
     defer resp.Body.Close()
@@ -62,4 +127,328 @@ func TestSearchGIFs_MissingQuery(t *testing.T) {
     if !strings.Contains(body, "Query param 'q' is required") {
         t.Errorf("expected error about missing q; got %q", body)
     }
-}
\ No newline at end of file
+}
+
+// TestGetTrending_UsesProvider verifies that GetTrending passes pagination to
+// the injected provider and encodes whatever it returns.
+func TestGetTrending_UsesProvider(t *testing.T) {
+    // Prepare a stub provider returning a single GIF.
+    stub := &stubProvider{trending: utils.GiphyResponse{
+        Data: []utils.Gif{{ID: "abc123", Title: "Stub GIF"}},
+    }}
+
+    req := httptest.NewRequest(http.MethodGet, "/api/trending?limit=5&page=2", nil)
+    w := httptest.NewRecorder()
+
+    NewGifHandler(stub, RatingPolicy{}, nil, nil).GetTrending(w, req)
+
+    // 1) Expect a 200 OK with the stubbed GIF in the body.
+    if w.Code != http.StatusOK {
+        t.Fatalf("expected status 200 OK; got %d", w.Code)
+    }
+    if !strings.Contains(w.Body.String(), `"id":"abc123"`) {
+        t.Errorf("expected stubbed GIF in body; got %q", w.Body.String())
+    }
+
+    // 2) Check the pagination values reached the provider.
+    if stub.lastTrending.Limit != 5 || stub.lastTrending.Offset != 5 {
+        t.Errorf("unexpected params passed to provider: %+v", stub.lastTrending)
+    }
+}
+
+// TestGetTrending_MediaType verifies that "type" selects stickers and that
+// unknown types are rejected before reaching the provider.
+func TestGetTrending_MediaType(t *testing.T) {
+    stub := &stubProvider{}
+    h := NewGifHandler(stub, RatingPolicy{}, nil, nil)
+
+    // 1) type=stickers is passed through.
+    w := httptest.NewRecorder()
+    h.GetTrending(w, httptest.NewRequest(http.MethodGet, "/api/trending?type=stickers", nil))
+    if w.Code != http.StatusOK || stub.lastTrending.Type != utils.MediaStickers {
+        t.Errorf("expected 200 with stickers; got %d, %+v", w.Code, stub.lastTrending)
+    }
+
+    // 2) Anything else is a 400.
+    w = httptest.NewRecorder()
+    h.GetTrending(w, httptest.NewRequest(http.MethodGet, "/api/trending?type=videos", nil))
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("expected status 400; got %d", w.Code)
+    }
+}
+
+// TestLocaleParams verifies that search languages and trending regions are
+// validated, normalized, passed to the provider and echoed in "meta".
+func TestLocaleParams(t *testing.T) {
+    stub := &stubProvider{}
+    h := NewGifHandler(stub, RatingPolicy{}, nil, nil)
+
+    // 1) Unsupported language and malformed region are rejected.
+    w := httptest.NewRecorder()
+    h.SearchGIFs(w, httptest.NewRequest(http.MethodGet, "/api/search?q=cats&lang=xx", nil))
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("expected status 400 for lang=xx; got %d", w.Code)
+    }
+    w = httptest.NewRecorder()
+    h.GetTrending(w, httptest.NewRequest(http.MethodGet, "/api/trending?region=USA", nil))
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("expected status 400 for region=USA; got %d", w.Code)
+    }
+
+    // 2) Valid values are canonicalized, forwarded and echoed.
+    var body struct {
+        Meta struct {
+            Lang   string `json:"lang"`
+            Region string `json:"region"`
+        } `json:"meta"`
+    }
+    w = httptest.NewRecorder()
+    h.SearchGIFs(w, httptest.NewRequest(http.MethodGet, "/api/search?q=cats&lang=zh-cn", nil))
+    json.NewDecoder(w.Body).Decode(&body)
+    if stub.lastSearch.Lang != "zh-CN" || body.Meta.Lang != "zh-CN" {
+        t.Errorf("expected lang zh-CN; got provider %q, meta %q", stub.lastSearch.Lang, body.Meta.Lang)
+    }
+    w = httptest.NewRecorder()
+    h.GetTrending(w, httptest.NewRequest(http.MethodGet, "/api/trending?region=de", nil))
+    json.NewDecoder(w.Body).Decode(&body)
+    if stub.lastTrending.Region != "DE" || body.Meta.Region != "DE" {
+        t.Errorf("expected region DE; got provider %q, meta %q", stub.lastTrending.Region, body.Meta.Region)
+    }
+}
+
+// TestGetTrending_UpstreamErrors verifies that typed upstream failures are
+// mapped to distinct HTTP statuses rather than a generic 500.
+func TestGetTrending_UpstreamErrors(t *testing.T) {
+    cases := []struct {
+        name   string
+        status int // status Giphy responded with
+        want   int // status we expect to return
+    }{
+        {"rate limited", http.StatusTooManyRequests, http.StatusTooManyRequests},
+        {"bad api key", http.StatusUnauthorized, http.StatusBadGateway},
+        {"giphy outage", http.StatusInternalServerError, http.StatusServiceUnavailable},
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            stub := &stubProvider{err: &utils.UpstreamError{StatusCode: tc.status}}
+            req := httptest.NewRequest(http.MethodGet, "/api/trending", nil)
+            w := httptest.NewRecorder()
+
+            NewGifHandler(stub, RatingPolicy{}, nil, nil).GetTrending(w, req)
+
+            if w.Code != tc.want {
+                t.Errorf("upstream %d: expected %d; got %d", tc.status, tc.want, w.Code)
+            }
+        })
+    }
+}
+
+// fixedBreaker is a BreakerStater that always reports the same state.
+type fixedBreaker utils.BreakerState
+
+func (b fixedBreaker) State() utils.BreakerState { return utils.BreakerState(b) }
+
+// TestReadinessCheck verifies that /ready reports the circuit breaker state
+// and flags an open circuit as degraded.
+func TestReadinessCheck(t *testing.T) {
+    req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+    w := httptest.NewRecorder()
+
+    ReadinessCheck(fixedBreaker(utils.BreakerOpen))(w, req)
+
+    if w.Code != http.StatusOK {
+        t.Fatalf("expected status 200 OK; got %d", w.Code)
+    }
+    expected := `{"status":"degraded","upstream_circuit":"open"}`
+    if strings.TrimSpace(w.Body.String()) != expected {
+        t.Errorf("unexpected body:\n got %q\nwant %q", w.Body.String(), expected)
+    }
+}
+
+// TestGetGIFs_PartialFailure verifies that a batch lookup returns the GIFs
+// it could fetch in request order and reports the rest per ID: unknown IDs
+// as 404, IDs lost to an upstream failure with the mapped upstream status.
+func TestGetGIFs_PartialFailure(t *testing.T) {
+    // 1) "a" is served; the upstream call for the others failed with a 503.
+    stub := &stubProvider{
+        gifs: []utils.Gif{{ID: "a", Title: "A", Rating: "g"}},
+        err:  &utils.PartialError{Err: &utils.UpstreamError{StatusCode: http.StatusServiceUnavailable}},
+    }
+    req := httptest.NewRequest(http.MethodGet, "/api/gifs?ids=b,a,b", nil)
+    w := httptest.NewRecorder()
+
+    NewGifHandler(stub, RatingPolicy{}, nil, nil).GetGIFs(w, req)
+
+    // 2) The batch itself succeeds...
+    if w.Code != http.StatusOK {
+        t.Fatalf("expected status 200 OK; got %d", w.Code)
+    }
+    var body struct {
+        Data   []struct{ ID string } `json:"data"`
+        Errors []struct {
+            ID     string `json:"id"`
+            Status int    `json:"status"`
+        } `json:"errors"`
+    }
+    if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+        t.Fatalf("decode error: %v", err)
+    }
+
+    // 3) ...with "a" in the data and the de-duplicated "b" reported as 503.
+    if len(body.Data) != 1 || body.Data[0].ID != "a" {
+        t.Errorf("unexpected data: %+v", body.Data)
+    }
+    if len(body.Errors) != 1 || body.Errors[0].ID != "b" || body.Errors[0].Status != http.StatusServiceUnavailable {
+        t.Errorf("unexpected errors: %+v", body.Errors)
+    }
+}
+
+// TestTranslate verifies that Translate validates the phrase and weirdness,
+// passes them to the provider, and returns the single GIF in our schema.
+func TestTranslate(t *testing.T) {
+    stub := &stubProvider{gif: utils.Gif{ID: "xyz", Title: "Hello"}}
+    h := NewGifHandler(stub, RatingPolicy{}, nil, nil)
+
+    // 1) Missing phrase and out-of-range weirdness are rejected up front.
+    for _, target := range []string{"/api/translate", "/api/translate?q=hi&weirdness=11", "/api/translate?q=hi&weirdness=x"} {
+        w := httptest.NewRecorder()
+        h.Translate(w, httptest.NewRequest(http.MethodGet, target, nil))
+        if w.Code != http.StatusBadRequest {
+            t.Errorf("%s: expected status 400; got %d", target, w.Code)
+        }
+    }
+
+    // 2) A valid request reaches the provider and returns the GIF.
+    w := httptest.NewRecorder()
+    h.Translate(w, httptest.NewRequest(http.MethodGet, "/api/translate?q=hello&weirdness=7", nil))
+    if w.Code != http.StatusOK {
+        t.Fatalf("expected status 200 OK; got %d", w.Code)
+    }
+    if stub.lastTranslate.Phrase != "hello" || stub.lastTranslate.Weirdness != 7 {
+        t.Errorf("unexpected provider params: %+v", stub.lastTranslate)
+    }
+    var body struct {
+        Data struct{ ID string } `json:"data"`
+    }
+    if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Data.ID != "xyz" {
+        t.Errorf("unexpected body (err %v): %+v", err, body)
+    }
+}
+
+// TestAutocomplete verifies that suggestions are returned as a plain list of
+// terms, cacheable by the browser, and that a bad limit is rejected.
+func TestAutocomplete(t *testing.T) {
+    stub := &stubProvider{tags: []utils.Tag{{Name: "cats"}, {Name: "cat memes"}}}
+    h := NewGifHandler(stub, RatingPolicy{}, nil, nil)
+
+    // 1) Out-of-range limit.
+    w := httptest.NewRecorder()
+    h.Autocomplete(w, httptest.NewRequest(http.MethodGet, "/api/autocomplete?q=ca&limit=500", nil))
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("expected status 400; got %d", w.Code)
+    }
+
+    // 2) A valid request lists the terms in order.
+    w = httptest.NewRecorder()
+    h.Autocomplete(w, httptest.NewRequest(http.MethodGet, "/api/autocomplete?q=ca", nil))
+    if w.Code != http.StatusOK || w.Header().Get("Cache-Control") == "" {
+        t.Fatalf("expected cacheable 200; got %d, %q", w.Code, w.Header().Get("Cache-Control"))
+    }
+    var body struct {
+        Query string   `json:"query"`
+        Data  []string `json:"data"`
+    }
+    if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+        t.Fatalf("decode error: %v", err)
+    }
+    if body.Query != "ca" || strings.Join(body.Data, ",") != "cats,cat memes" {
+        t.Errorf("unexpected body: %+v", body)
+    }
+}
+
+// TestGetCategory verifies that a category is looked up by slug and returned
+// with its subcategories' GIFs, leaving out those above the maximum rating,
+// and that unknown slugs, ours or Giphy's, are a 404 for the category.
+func TestGetCategory(t *testing.T) {
+    stub := &stubProvider{
+        categories:    []utils.Category{{Name: "Animals", NameEncoded: "animals"}},
+        subcategories: []utils.Category{
+            {Name: "cats", NameEncoded: "cats", Gif: &utils.Gif{ID: "c1", Rating: "g"}},
+            {Name: "horror", NameEncoded: "horror", Gif: &utils.Gif{ID: "h1", Rating: "r"}},
+        },
+    }
+    router := mux.NewRouter()
+    router.HandleFunc("/api/categories/{name}", NewGifHandler(stub, RatingPolicy{}, nil, nil).GetCategory)
+
+    // 1) Unknown category.
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/categories/plants", nil))
+    if w.Code != http.StatusNotFound {
+        t.Errorf("expected status 404; got %d", w.Code)
+    }
+
+    // 2) Known category, matched case-insensitively.
+    w = httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/categories/Animals", nil))
+    if w.Code != http.StatusOK {
+        t.Fatalf("expected status 200 OK; got %d", w.Code)
+    }
+    var body struct {
+        Data struct {
+            Name          string `json:"name"`
+            Subcategories []struct {
+                Slug string               `json:"slug"`
+                GIF  *struct{ ID string } `json:"gif"`
+            } `json:"subcategories"`
+        } `json:"data"`
+    }
+    if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+        t.Fatalf("decode error: %v", err)
+    }
+    subs := body.Data.Subcategories
+    if body.Data.Name != "Animals" || len(subs) != 2 || subs[0].Slug != "cats" || subs[0].GIF == nil || subs[0].GIF.ID != "c1" {
+        t.Errorf("unexpected body: %+v", body)
+    }
+    if len(subs) == 2 && subs[1].GIF != nil {
+        t.Errorf("expected the r-rated GIF to be left out; got %+v", subs[1].GIF)
+    }
+
+    // 3) An upstream 404 is reported as a missing category, not a missing GIF.
+    stub.err = &utils.UpstreamError{StatusCode: http.StatusNotFound}
+    w = httptest.NewRecorder()
+    router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/categories/animals", nil))
+    var problem Problem
+    json.NewDecoder(w.Body).Decode(&problem)
+    if w.Code != http.StatusNotFound || problem.Detail != "Category not found" {
+        t.Errorf("expected a 404 for the category; got %d %q", w.Code, problem.Detail)
+    }
+}
+
+// TestLoggingAndMetrics_RouteLabel verifies that requests are counted under
+// their route's template, so different IDs share one series, and that
+// unmatched paths share a constant label.
+func TestLoggingAndMetrics_RouteLabel(t *testing.T) {
+    r := mux.NewRouter()
+    r.Use(LoggingAndMetricsMiddleware)
+    r.HandleFunc("/test/metrics/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+    // 1) Two IDs add one series, counted twice.
+    series := testutil.CollectAndCount(httpRequestsTotal)
+    for _, id := range []string{"a1", "b2"} {
+        r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/metrics/"+id, nil))
+    }
+    if got := testutil.CollectAndCount(httpRequestsTotal) - series; got != 1 {
+        t.Errorf("expected 1 new series for two IDs; got %d", got)
+    }
+    if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("GET", "/test/metrics/{id}", "OK")); got != 2 {
+        t.Errorf("expected 2 requests under the route template; got %v", got)
+    }
+
+    // 2) Without a matched route the label is constant.
+    req := httptest.NewRequest(http.MethodGet, "/no/such/path", nil)
+    LoggingAndMetricsMiddleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+    if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("GET", "unmatched", "Not Found")); got < 1 {
+        t.Errorf("expected the request under \"unmatched\"; got %v", got)
+    }
+}
@@ -3,6 +3,8 @@ package handlers
 import (
     "encoding/json" // encoding Go values to JSON
     "net/http" // HTTP request/response types
+
+    "github.com/adrian/gif-backend/utils" // circuit breaker state
 )
 
 // HealthCheck responds with a simple JSON payload to indicate service health.
@@ -15,4 +17,34 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
     //    NewEncoder(w) wraps our ResponseWriter so json.Encoder writes directly to the HTTP response.
     //    Encode handles marshalling and adding a trailing newline.
     json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
+
+// BreakerStater is implemented by utils.BreakerProvider; readiness only
+// needs to know the circuit state, not the whole provider.
+type BreakerStater interface {
+    State() utils.BreakerState
+}
+
+// ReadinessCheck returns a handler for /ready that reports the upstream
+// circuit breaker state alongside the usual status. The probe stays 200
+// while the circuit is open, because cached data can still be served;
+// status switches to "degraded" so dashboards and humans can tell.
+func ReadinessCheck(breaker BreakerStater) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        // 1. Read the current circuit state.
+        state := breaker.State()
+
+        // 2. Anything but closed means upstream calls are being rejected or probed.
+        status := "ok"
+        if state != utils.BreakerClosed {
+            status = "degraded"
+        }
+
+        // 3. Encode {"status":..., "upstream_circuit":...}.
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(map[string]string{
+            "status":           status,
+            "upstream_circuit": state.String(),
+        })
+    }
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+    "encoding/json" // JSON encoding for responses
+    "errors"        // to detect partial batch results
+    "net/http"      // HTTP request/response types
+    "regexp"        // GIF ID validation
+
+    "github.com/adrian/gif-backend/models" // provider-neutral response schema
+    "github.com/adrian/gif-backend/utils"  // provider error types
+    "github.com/gorilla/mux"               // path variables
+)
+
+// maxBatchIDs caps how many IDs one /api/gifs?ids= request may ask for.
+const maxBatchIDs = 50
+
+// ratingForbidden is reported for GIFs rated above the caller's maximum.
+const ratingForbidden = "GIF rating exceeds the maximum allowed"
+
+// gifIDPattern matches the GIF IDs we accept. Giphy IDs are short
+// alphanumeric strings; anything else is rejected before going upstream.
+var gifIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// GetGIF handles GET /api/gifs/{id}. It returns one GIF so clients can
+// refresh stored favorites whose URLs have rotated. Sticker IDs work too;
+// the response's "type" says which it is.
+func (h *GifHandler) GetGIF(w http.ResponseWriter, r *http.Request) {
+    // 1) Validate the ID from the path.
+    id := mux.Vars(r)["id"]
+    if !gifIDPattern.MatchString(id) {
+        writeError(w, r, http.StatusBadRequest, "Invalid GIF id")
+        return
+    }
+
+    // 2) Ask the provider (through the cache) for the GIF.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    gif, err := h.provider.GetByID(ctx, id)
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to fetch GIF", "GIF not found")
+        return
+    }
+    if !h.ratings.allows(r, gif.Rating) {
+        // Above the caller's maximum rating: the policy applies to lookups too.
+        writeError(w, r, http.StatusForbidden, ratingForbidden)
+        return
+    }
+
+    // 3) Encode it in our schema.
+    w.Header().Set("Content-Type", "application/json")
+    writeCacheHeaders(w, cacheInfo)
+    json.NewEncoder(w).Encode(models.NewItemResponse(models.FromGiphy(gif)))
+}
+
+// GetGIFs handles GET /api/gifs?ids=a,b,c. Found GIFs are returned in
+// request order; IDs that are unknown, or couldn't be fetched because the
+// upstream failed, are listed under "errors" with a per-ID status, so one
+// bad ID doesn't fail the whole batch.
+func (h *GifHandler) GetGIFs(w http.ResponseWriter, r *http.Request) {
+    // 1) Parse, de-duplicate and validate the ID list.
+    params := parseQuery(r)
+    ids := params.ids("ids", maxBatchIDs)
+    if !params.valid(w) {
+        return
+    }
+
+    // 2) Fetch them in one provider call. A *PartialError still carries usable results.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    gifs, err := h.provider.GetByIDs(ctx, ids)
+    var partial *utils.PartialError
+    if err != nil && !errors.As(err, &partial) {
+        writeProviderError(w, r, err, "Failed to fetch GIFs", "GIF not found")
+        return
+    }
+
+    // 3) Reassemble in request order, reporting each missing ID.
+    byID := make(map[string]utils.Gif, len(gifs))
+    for _, g := range gifs {
+        byID[g.ID] = g
+    }
+    resp := models.BatchResponse{Version: models.SchemaVersion, Data: make([]models.GIF, 0, len(ids))}
+    for _, id := range ids {
+        if g, ok := byID[id]; ok {
+            if h.ratings.allows(r, g.Rating) {
+                resp.Data = append(resp.Data, models.FromGiphy(g))
+            } else {
+                resp.Errors = append(resp.Errors, models.ItemError{ID: id, Status: http.StatusForbidden, Message: ratingForbidden})
+            }
+            continue
+        }
+        itemErr := models.ItemError{ID: id, Status: http.StatusNotFound, Message: "GIF not found"}
+        if partial != nil {
+            // Not necessarily unknown: the upstream call for it failed.
+            f := classifyProviderError(partial.Err, "Failed to fetch GIF", "GIF not found")
+            itemErr.Status, itemErr.Message = f.status, f.message
+        }
+        resp.Errors = append(resp.Errors, itemErr)
+    }
+
+    // 4) Encode the batch; per-item failures don't change the overall status.
+    w.Header().Set("Content-Type", "application/json")
+    writeCacheHeaders(w, cacheInfo)
+    json.NewEncoder(w).Encode(resp)
+}
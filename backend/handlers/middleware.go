@@ -4,6 +4,8 @@ import (
     "net/http"   // HTTP types for handlers
     "time"       // for measuring request duration
 
+    // Gorilla mux for the matched route's template
+    "github.com/gorilla/mux"
     // Prometheus client libraries for metrics
     "github.com/prometheus/client_golang/prometheus"
     "github.com/prometheus/client_golang/prometheus/promhttp"
@@ -15,7 +17,7 @@ import (
 // shared across all requests.
 
 // httpRequestsTotal counts the number of HTTP requests received,
-// labeled by HTTP method, path, and response status.
+// labeled by HTTP method, route path template, and response status.
 var httpRequestsTotal = prometheus.NewCounterVec(
     prometheus.CounterOpts{
         Name: "http_requests_total",
@@ -25,7 +27,7 @@ var httpRequestsTotal = prometheus.NewCounterVec(
 )
 
 // httpRequestDuration tracks the duration of HTTP requests in seconds,
-// labeled by HTTP method and path.
+// labeled by HTTP method and route path template.
 var httpRequestDuration = prometheus.NewHistogramVec(
     prometheus.HistogramOpts{
         Name:    "http_request_duration_seconds",
@@ -63,23 +65,38 @@ func LoggingAndMetricsMiddleware(next http.Handler) http.Handler {
         // 4) After handler finishes, compute request duration
         duration := time.Since(start).Seconds()
 
-        // 5) Extract method, path, and captured status
+        // 5) Extract method, path, and captured status. Metrics use the
+        //    route template instead of the path, so IDs in paths can't
+        //    create a series per value.
         method, path, status := r.Method, r.URL.Path, lrw.statusCode
+        route := routeLabel(r)
 
         // 6) Log structured entry via Logrus
         logrus.WithFields(logrus.Fields{
-            "method":   method,
-            "path":     path,
-            "status":   status,
-            "duration": duration,
+            "method":     method,
+            "path":       path,
+            "status":     status,
+            "duration":   duration,
+            "request_id": RequestIDFrom(r.Context()),
         }).Info("handled request")
 
         // 7) Update Prometheus metrics
-        httpRequestsTotal.WithLabelValues(method, path, http.StatusText(status)).Inc()
-        httpRequestDuration.WithLabelValues(method, path).Observe(duration)
+        httpRequestsTotal.WithLabelValues(method, route, http.StatusText(status)).Inc()
+        httpRequestDuration.WithLabelValues(method, route).Observe(duration)
     })
 }
 
+// routeLabel returns the path template of the route that matched r, such
+// as "/api/gifs/{id}", or "unmatched" when no route did.
+func routeLabel(r *http.Request) string {
+    if route := mux.CurrentRoute(r); route != nil {
+        if template, err := route.GetPathTemplate(); err == nil {
+            return template
+        }
+    }
+    return "unmatched"
+}
+
 // loggingResponseWriter embeds http.ResponseWriter and intercepts
 // WriteHeader calls to record the status code.
 type loggingResponseWriter struct {
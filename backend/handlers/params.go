@@ -0,0 +1,228 @@
+package handlers
+
+import (
+    "fmt"          // for formatting reasons
+    "net/http"     // request and response types
+    "net/url"      // query values
+    "strconv"      // integer parameters
+    "strings"      // trimming and splitting
+    "unicode"      // control character checks
+    "unicode/utf8" // length and encoding checks
+
+    "github.com/adrian/gif-backend/utils" // media types, languages and regions
+)
+
+// Query parameter bounds shared by every endpoint.
+const (
+    // defaultPageLimit is the page size when "limit" is omitted.
+    defaultPageLimit = 12
+
+    // maxPageLimit is the largest page size a client may ask for.
+    maxPageLimit = 50
+
+    // maxOffset is the deepest result Giphy will page to; pages beyond it are rejected.
+    maxOffset = 4999
+
+    // maxTermLength caps search terms, phrases and tags, in characters.
+    maxTermLength = 50
+)
+
+// FieldError describes one invalid query parameter.
+type FieldError struct {
+    // Name is the query parameter.
+    Name string `json:"name"`
+
+    // Reason explains what is wrong, e.g. "must be an integer from 1 to 50".
+    Reason string `json:"reason"`
+}
+
+// queryParams reads and validates a request's query parameters. Each
+// accessor returns a usable value (the default when invalid) and records a
+// FieldError, so a handler parses everything first and then calls valid once.
+type queryParams struct {
+    r          *http.Request
+    values     url.Values
+    errs       []FieldError
+    fromCursor bool // values came from a verified cursor
+}
+
+// parseQuery starts validating r's query parameters.
+func parseQuery(r *http.Request) *queryParams {
+    return &queryParams{r: r, values: r.URL.Query()}
+}
+
+// invalid records a problem with the named parameter.
+func (p *queryParams) invalid(name, reason string) {
+    p.errs = append(p.errs, FieldError{Name: name, Reason: reason})
+}
+
+// valid reports whether every parameter parsed so far was valid. If not, it
+// writes a 400 problem document whose invalid_params lists every field that
+// failed, so clients can fix them all at once, and returns false.
+func (p *queryParams) valid(w http.ResponseWriter) bool {
+    if len(p.errs) == 0 {
+        return true
+    }
+
+    // Human-readable summary, one sentence per field.
+    details := make([]string, len(p.errs))
+    for i, e := range p.errs {
+        details[i] = fmt.Sprintf("Query param '%s' %s", e.Name, e.Reason)
+    }
+
+    writeProblem(w, p.r, Problem{
+        Status:        http.StatusBadRequest,
+        Detail:        strings.Join(details, "; "),
+        InvalidParams: p.errs,
+    })
+    return false
+}
+
+// cursor replaces the parameters with those of a "cursor" parameter, if
+// present, after verifying it was issued by codec for endpoint. It must be
+// called before any other accessor. A cursor can't be combined with "page".
+func (p *queryParams) cursor(codec *CursorCodec, endpoint string) {
+    raw := p.values.Get("cursor")
+    if raw == "" {
+        return
+    }
+    if p.values.Has("page") {
+        p.invalid("page", "cannot be combined with cursor")
+    }
+    values, ok := codec.decode(endpoint, raw)
+    if !ok {
+        p.invalid("cursor", "is invalid or was not issued for this endpoint")
+        return
+    }
+    p.values, p.fromCursor = values, true
+}
+
+// text returns a free-text parameter (search term, phrase, tag), trimmed.
+// It must be valid UTF-8 without control characters and at most
+// maxTermLength characters long; required terms must be non-empty.
+func (p *queryParams) text(name string, required bool) string {
+    s := strings.TrimSpace(p.values.Get(name))
+    if s == "" {
+        if required {
+            p.invalid(name, "is required")
+        }
+        return ""
+    }
+    if reason := checkText(s, maxTermLength); reason != "" {
+        p.invalid(name, reason)
+        return ""
+    }
+    return s
+}
+
+// checkText returns why s is not acceptable free text, or "" if it is: it
+// must be valid UTF-8 without control characters and at most maxLen
+// characters long.
+func checkText(s string, maxLen int) string {
+    switch {
+    case !utf8.ValidString(s):
+        return "must be valid UTF-8"
+    case utf8.RuneCountInString(s) > maxLen:
+        return fmt.Sprintf("must be at most %d characters", maxLen)
+    }
+    for _, c := range s {
+        if unicode.IsControl(c) {
+            return "must not contain control characters"
+        }
+    }
+    return ""
+}
+
+// intRange returns an optional integer parameter within [min, max], or def
+// when it is omitted.
+func (p *queryParams) intRange(name string, def, min, max int) int {
+    raw := p.values.Get(name)
+    if raw == "" {
+        return def
+    }
+    n, err := strconv.Atoi(raw)
+    if err != nil || n < min || n > max {
+        p.invalid(name, fmt.Sprintf("must be an integer from %d to %d", min, max))
+        return def
+    }
+    return n
+}
+
+// pagination returns the "limit" parameter and the zero-based offset of the
+// page: a cursor's "offset", or the one the 1-based "page" starts at. The
+// page may not start beyond maxOffset.
+func (p *queryParams) pagination() (limit, offset int) {
+    limit = p.intRange("limit", defaultPageLimit, 1, maxPageLimit)
+    if p.fromCursor {
+        return limit, p.intRange("offset", 0, 0, maxOffset)
+    }
+    page := p.intRange("page", 1, 1, maxOffset+1)
+    if offset = (page - 1) * limit; offset > maxOffset {
+        p.invalid("page", fmt.Sprintf("must not start beyond result %d (at most page %d for limit %d)", maxOffset, maxOffset/limit+1, limit))
+        offset = 0
+    }
+    return limit, offset
+}
+
+// mediaType returns the optional "type" parameter: GIFs (default) or stickers.
+func (p *queryParams) mediaType() utils.MediaType {
+    t, ok := utils.ParseMediaType(p.values.Get("type"))
+    if !ok {
+        p.invalid("type", "must be 'gifs' or 'stickers'")
+        return utils.MediaGIFs
+    }
+    return t
+}
+
+// lang returns the canonical "lang" parameter, defaulting to English.
+func (p *queryParams) lang() string {
+    lang, ok := utils.ParseLanguage(p.values.Get("lang"))
+    if !ok {
+        p.invalid("lang", "is not a supported language")
+        return utils.DefaultLanguage
+    }
+    return lang
+}
+
+// region returns the optional "region" country code, upper-cased.
+func (p *queryParams) region() string {
+    region, ok := utils.ParseRegion(p.values.Get("region"))
+    if !ok {
+        p.invalid("region", "must be a two-letter ISO 3166-1 country code")
+    }
+    return region
+}
+
+// rating returns the effective content rating under policy. A rating
+// carried by a cursor is checked against the caller's own maximum.
+func (p *queryParams) rating(policy RatingPolicy) string {
+    rating, err := policy.resolve(p.r, p.values.Get("rating"))
+    if err != nil {
+        p.invalid("rating", err.Error())
+    }
+    return rating
+}
+
+// ids returns the required comma-separated ID list in name, de-duplicated
+// in request order. Each ID must match gifIDPattern and there may be at most max.
+func (p *queryParams) ids(name string, max int) []string {
+    var ids []string
+    seen := make(map[string]bool)
+    for _, id := range strings.Split(p.values.Get(name), ",") {
+        id = strings.TrimSpace(id)
+        if id == "" || seen[id] {
+            continue
+        }
+        if !gifIDPattern.MatchString(id) {
+            p.invalid(name, "contains an invalid id: "+id)
+            return nil
+        }
+        seen[id] = true
+        ids = append(ids, id)
+    }
+    if len(ids) == 0 || len(ids) > max {
+        p.invalid(name, fmt.Sprintf("must list 1 to %d ids", max))
+        return nil
+    }
+    return ids
+}
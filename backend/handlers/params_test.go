@@ -0,0 +1,78 @@
+package handlers
+
+import (
+    "encoding/json"     // to decode the problem document
+    "net/http"          // for HTTP status codes and method constants
+    "net/http/httptest" // to create fake Requests and ResponseRecorders
+    "net/url"           // to build query strings
+    "strings"           // to build an over-long term
+    "testing"           // the Go testing framework
+)
+
+// TestQueryParams_ProblemDocument verifies that every invalid parameter is
+// reported in one application/problem+json 400, and that the provider is
+// never called.
+func TestQueryParams_ProblemDocument(t *testing.T) {
+    stub := &stubProvider{}
+    h := NewGifHandler(stub, RatingPolicy{}, nil, nil)
+
+    // 1) Missing q, non-numeric limit and a zero page in one request.
+    w := httptest.NewRecorder()
+    h.SearchGIFs(w, httptest.NewRequest(http.MethodGet, "/api/search?limit=abc&page=0", nil))
+    if w.Code != http.StatusBadRequest {
+        t.Fatalf("expected status 400; got %d", w.Code)
+    }
+    if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+        t.Errorf("expected problem+json; got %q", ct)
+    }
+    var problem struct {
+        Status        int `json:"status"`
+        InvalidParams []struct {
+            Name string `json:"name"`
+        } `json:"invalid_params"`
+    }
+    if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+        t.Fatalf("decode error: %v", err)
+    }
+    var names []string
+    for _, p := range problem.InvalidParams {
+        names = append(names, p.Name)
+    }
+    if problem.Status != http.StatusBadRequest || strings.Join(names, ",") != "q,limit,page" {
+        t.Errorf("expected q, limit and page to be reported; got %v", names)
+    }
+    if stub.lastSearch.Query != "" {
+        t.Error("provider must not be called for an invalid request")
+    }
+}
+
+// TestQueryParams_Bounds verifies the individual rules: page depth, term
+// length and characters, and the limit maximum.
+func TestQueryParams_Bounds(t *testing.T) {
+    cases := []struct {
+        name  string
+        query url.Values
+        field string // expected invalid field, or "" when valid
+    }{
+        {"valid", url.Values{"q": {"cats"}, "limit": {"50"}, "page": {"100"}}, ""},
+        {"limit over max", url.Values{"q": {"cats"}, "limit": {"51"}}, "limit"},
+        {"page beyond offset", url.Values{"q": {"cats"}, "limit": {"50"}, "page": {"101"}}, "page"},
+        {"term too long", url.Values{"q": {strings.Repeat("a", maxTermLength+1)}}, "q"},
+        {"control character", url.Values{"q": {"cats\x00dogs"}}, "q"},
+    }
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodGet, "/api/search?"+tc.query.Encode(), nil)
+            params := parseQuery(req)
+            params.text("q", true)
+            params.pagination()
+
+            switch {
+            case tc.field == "" && len(params.errs) != 0:
+                t.Errorf("expected no errors; got %+v", params.errs)
+            case tc.field != "" && (len(params.errs) != 1 || params.errs[0].Name != tc.field):
+                t.Errorf("expected one error for %s; got %+v", tc.field, params.errs)
+            }
+        })
+    }
+}
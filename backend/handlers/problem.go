@@ -0,0 +1,78 @@
+package handlers
+
+import (
+    "encoding/json" // encoding the problem document
+    "net/http"      // status codes and response writer
+)
+
+// Problem is an RFC 7807 problem document, the body of every error
+// response this service sends (Content-Type application/problem+json).
+type Problem struct {
+    // Type identifies the problem type; "about:blank" means the status
+    // code says it all.
+    Type string `json:"type"`
+
+    // Title is a short summary: the HTTP status text.
+    Title string `json:"title"`
+
+    // Status repeats the HTTP status code.
+    Status int `json:"status"`
+
+    // Detail explains this occurrence in human-readable terms.
+    Detail string `json:"detail,omitempty"`
+
+    // Instance is the request path the problem occurred on.
+    Instance string `json:"instance,omitempty"`
+
+    // RequestID matches the X-Request-ID response header and our logs.
+    RequestID string `json:"request_id,omitempty"`
+
+    // Cause is a machine-readable code for errors caused upstream or by our
+    // upstream protections (e.g. "upstream_rate_limited", "circuit_open").
+    Cause string `json:"cause,omitempty"`
+
+    // UpstreamStatus is the HTTP status Giphy answered with, when it did.
+    UpstreamStatus int `json:"upstream_status,omitempty"`
+
+    // InvalidParams lists every invalid query parameter or body field of a 400.
+    InvalidParams []FieldError `json:"invalid_params,omitempty"`
+}
+
+// writeProblem writes p as the response to r. Type, Title, Instance and
+// RequestID are filled in when left empty; Status must be set.
+func writeProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+    if p.Type == "" {
+        p.Type = "about:blank"
+    }
+    if p.Title == "" {
+        p.Title = http.StatusText(p.Status)
+    }
+    if p.Instance == "" {
+        p.Instance = r.URL.Path
+    }
+    if p.RequestID == "" {
+        p.RequestID = RequestIDFrom(r.Context())
+    }
+
+    w.Header().Set("Content-Type", "application/problem+json")
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+    w.WriteHeader(p.Status)
+    json.NewEncoder(w).Encode(p)
+}
+
+// writeError writes a problem with just a status and detail message, the
+// problem+json replacement for http.Error.
+func writeError(w http.ResponseWriter, r *http.Request, status int, detail string) {
+    writeProblem(w, r, Problem{Status: status, Detail: detail})
+}
+
+// NotFound answers requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+    writeError(w, r, http.StatusNotFound, "No such endpoint")
+}
+
+// MethodNotAllowed answers requests whose path matches a route but whose
+// method doesn't.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+    writeError(w, r, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed here")
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+    "encoding/json"     // to decode problem documents
+    "net/http"          // for HTTP status codes and handler types
+    "net/http/httptest" // to create fake Requests and ResponseRecorders
+    "testing"           // the Go testing framework
+
+    "github.com/adrian/gif-backend/utils" // upstream error types
+)
+
+// decodeProblem decodes w's body as a problem document, checking the
+// Content-Type on the way.
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+    t.Helper()
+    if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+        t.Fatalf("expected application/problem+json; got %q", ct)
+    }
+    var p Problem
+    if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+        t.Fatalf("decode error: %v", err)
+    }
+    return p
+}
+
+// TestProblem_ProviderError verifies that an upstream failure becomes a
+// problem document carrying the cause, Giphy's status and the request ID.
+func TestProblem_ProviderError(t *testing.T) {
+    stub := &stubProvider{err: &utils.UpstreamError{StatusCode: http.StatusBadGateway}}
+    h := RequestIDMiddleware(http.HandlerFunc(NewGifHandler(stub, RatingPolicy{}, nil, nil).GetTrending))
+
+    req := httptest.NewRequest(http.MethodGet, "/api/trending", nil)
+    req.Header.Set("X-Request-ID", "req-123")
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, req)
+
+    p := decodeProblem(t, w)
+    if p.Status != http.StatusServiceUnavailable || p.Title != "Service Unavailable" || p.Instance != "/api/trending" {
+        t.Errorf("unexpected problem: %+v", p)
+    }
+    if p.Cause != causeUpstreamUnavailable || p.UpstreamStatus != http.StatusBadGateway {
+        t.Errorf("expected upstream cause and status; got %q / %d", p.Cause, p.UpstreamStatus)
+    }
+    if p.RequestID != "req-123" || w.Header().Get("X-Request-ID") != "req-123" {
+        t.Errorf("expected the caller's request ID to be echoed; got %q / %q", p.RequestID, w.Header().Get("X-Request-ID"))
+    }
+}
+
+// TestProblem_Recovery verifies that a panic is answered with the same
+// problem shape, and that a malformed X-Request-ID is replaced.
+func TestProblem_Recovery(t *testing.T) {
+    panicky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        panic("boom")
+    })
+    h := RequestIDMiddleware(RecoveryMiddleware(panicky))
+
+    req := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+    req.Header.Set("X-Request-ID", "bad id\n")
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, req)
+
+    p := decodeProblem(t, w)
+    if p.Status != http.StatusInternalServerError {
+        t.Errorf("expected status 500; got %d", p.Status)
+    }
+    if p.RequestID == "" || p.RequestID == "bad id\n" || p.RequestID != w.Header().Get("X-Request-ID") {
+        t.Errorf("expected a fresh request ID; got %q", p.RequestID)
+    }
+}
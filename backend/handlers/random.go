@@ -0,0 +1,85 @@
+package handlers
+
+import (
+    "encoding/json" // JSON encoding for responses
+    "net/http"      // HTTP request/response types
+
+    "github.com/adrian/gif-backend/models" // provider-neutral response schema
+    "github.com/adrian/gif-backend/utils"  // provider parameter types
+)
+
+// Translate weirdness: 0 is the most literal match, maxWeirdness the most
+// surprising; defaultWeirdness is used when the client doesn't pick one.
+const (
+    defaultWeirdness = 0
+    maxWeirdness     = 10
+)
+
+// GetRandom handles GET /api/random. It returns one random GIF (or sticker,
+// with type=stickers), optionally restricted by "tag", at the rating the
+// policy allows.
+func (h *GifHandler) GetRandom(w http.ResponseWriter, r *http.Request) {
+    // 1) Read and validate the optional filters.
+    params := parseQuery(r)
+    tag := params.text("tag", false)   // restrict to GIFs with this tag
+    mediaType := params.mediaType()    // GIFs or stickers
+    rating := params.rating(h.ratings) // effective content rating
+    if !params.valid(w) {
+        return
+    }
+
+    // 2) Ask the provider for a random GIF. Random picks bypass the cache.
+    gif, err := h.provider.Random(r.Context(), utils.RandomParams{
+        Type:   mediaType,
+        Tag:    tag,
+        Rating: rating,
+    })
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to fetch a random GIF", "")
+        return
+    }
+
+    // 3) Encode the single GIF in our schema, echoing the rating. Random
+    //    responses must not be reused.
+    resp := models.NewItemResponse(models.FromGiphy(gif))
+    resp.Meta.Rating = rating
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", "no-store")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// Translate handles GET /api/translate?q=…&weirdness=0-10. It turns a word
+// or phrase into the single GIF that best matches it, for integrations
+// (like chat) that want one answer rather than a page of results.
+func (h *GifHandler) Translate(w http.ResponseWriter, r *http.Request) {
+    // 1) Parse and validate the query parameters: the phrase is required,
+    //    weirdness is optional from 0 to 10.
+    params := parseQuery(r)
+    q := params.text("q", true)        // phrase to translate
+    mediaType := params.mediaType()    // GIFs (default) or stickers
+    rating := params.rating(h.ratings) // effective content rating
+    weirdness := params.intRange("weirdness", defaultWeirdness, 0, maxWeirdness)
+    if !params.valid(w) {
+        return
+    }
+
+    // 2) Ask the provider (through the cache) for the best match.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    gif, err := h.provider.Translate(ctx, utils.TranslateParams{
+        Type:      mediaType,
+        Phrase:    q,
+        Rating:    rating,
+        Weirdness: weirdness,
+    })
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to translate phrase", "")
+        return
+    }
+
+    // 3) Encode the single GIF in our schema, echoing the rating.
+    resp := models.NewItemResponse(models.FromGiphy(gif))
+    resp.Meta.Rating = rating
+    w.Header().Set("Content-Type", "application/json")
+    writeCacheHeaders(w, cacheInfo)
+    json.NewEncoder(w).Encode(resp)
+}
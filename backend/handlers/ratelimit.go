@@ -0,0 +1,247 @@
+package handlers
+
+import (
+    "fmt"      // error messages
+    "net"      // client IP parsing and CIDR matching
+    "net/http" // HTTP types for middleware
+    "strconv"  // header values
+    "strings"  // header parsing
+    "sync"     // buckets are shared by concurrent requests
+    "time"     // refill and idle bookkeeping
+
+    "github.com/adrian/gif-backend/utils" // TokenBucket
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// httpRateLimitedTotal counts requests rejected by the inbound rate limiter,
+// labeled by the limiter's name (usually the route).
+var httpRateLimitedTotal = prometheus.NewCounterVec(
+    prometheus.CounterOpts{
+        Name: "http_rate_limited_total",
+        Help: "Count of HTTP requests rejected by the per-client rate limiter",
+    },
+    []string{"limiter"},
+)
+
+// init registers the rate limiter metric with Prometheus’s default registry.
+func init() {
+    prometheus.MustRegister(httpRateLimitedTotal)
+}
+
+// RateLimitConfig configures one inbound RateLimiter.
+type RateLimitConfig struct {
+    // Name labels metrics and logs, e.g. "search".
+    Name string
+
+    // Limit is the number of requests a client may make per Per.
+    Limit int
+
+    // Per is the period Limit applies to.
+    Per time.Duration
+
+    // Burst is how many requests a client may make back to back. Defaults to Limit.
+    Burst int
+
+    // TrustedProxies lists proxies whose X-Forwarded-For header we believe.
+    // Requests from anywhere else are keyed by their RemoteAddr.
+    TrustedProxies []*net.IPNet
+
+    // ByIP keys clients by IP address even when they are signed in. Routes
+    // that create accounts need it, or each new account would bring a
+    // fresh budget for creating the next.
+    ByIP bool
+}
+
+// RateLimiter limits requests per client, identified by the signed-in user
+// when AuthMiddleware verified one and by client IP otherwise.
+// Each route can have its own RateLimiter with its own budget.
+type RateLimiter struct {
+    cfg RateLimitConfig
+
+    mu        sync.Mutex
+    clients   map[string]*clientBucket // client key -> bucket
+    lastSweep time.Time                // when idle clients were last dropped
+}
+
+// clientBucket is one client's bucket and when it was last used.
+type clientBucket struct {
+    bucket   *utils.TokenBucket
+    lastSeen time.Time
+}
+
+// NewRateLimiter returns a RateLimiter for cfg.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+    if cfg.Burst <= 0 {
+        cfg.Burst = cfg.Limit
+    }
+    return &RateLimiter{
+        cfg:       cfg,
+        clients:   make(map[string]*clientBucket),
+        lastSweep: time.Now(),
+    }
+}
+
+// Middleware wraps next so every request spends one token of its client's
+// budget. Responses carry RateLimit-Limit/-Remaining/-Reset headers; when
+// the budget is spent the client gets a 429 with Retry-After.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // 1) Find (or create) this client's bucket and try to spend a token.
+        bucket := l.bucketFor(l.clientKey(r))
+        ok, wait := bucket.Allow()
+        remaining, untilFull := bucket.Status()
+
+        // 2) Always advertise the policy and what's left of it.
+        w.Header().Set("RateLimit-Limit", strconv.Itoa(l.cfg.Burst))
+        w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+        w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(untilFull)))
+
+        // 3) Out of budget: reject with a hint of when to come back.
+        if !ok {
+            httpRateLimitedTotal.WithLabelValues(l.cfg.Name).Inc()
+            setRetryAfter(w, wait)
+            writeProblem(w, r, Problem{
+                Status: http.StatusTooManyRequests,
+                Detail: "Rate limit exceeded, try again later",
+                Cause:  "rate_limited",
+            })
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// bucketFor returns the bucket for key, creating it on first use and
+// occasionally dropping buckets of clients that have gone quiet.
+func (l *RateLimiter) bucketFor(key string) *utils.TokenBucket {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    now := time.Now()
+    l.sweep(now)
+
+    c, ok := l.clients[key]
+    if !ok {
+        c = &clientBucket{bucket: utils.NewTokenBucket(l.cfg.Limit, l.cfg.Per, l.cfg.Burst)}
+        l.clients[key] = c
+    }
+    c.lastSeen = now
+    return c.bucket
+}
+
+// sweep drops clients idle for longer than one period; their bucket would
+// be full again anyway. It runs at most once per period. Caller holds l.mu.
+func (l *RateLimiter) sweep(now time.Time) {
+    if now.Sub(l.lastSweep) < l.cfg.Per {
+        return
+    }
+    for key, c := range l.clients {
+        if now.Sub(c.lastSeen) > l.cfg.Per {
+            delete(l.clients, key)
+        }
+    }
+    l.lastSweep = now
+}
+
+// clientKey identifies the caller: the user AuthMiddleware signed the
+// request in as, otherwise its IP address as resolved through trusted
+// proxies, and always the IP with ByIP. Unverified Authorization or
+// X-API-Key values are ignored, or sending a new one with every request
+// would dodge the limit.
+func (l *RateLimiter) clientKey(r *http.Request) string {
+    if user, ok := UserFrom(r.Context()); ok && !l.cfg.ByIP {
+        return "user:" + user.ID
+    }
+    return "ip:" + clientIP(r, l.cfg.TrustedProxies)
+}
+
+// requestToken extracts an API token from "Authorization: Bearer <token>"
+// or the X-API-Key header.
+func requestToken(r *http.Request) string {
+    if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+        return strings.TrimSpace(auth[7:])
+    }
+    return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// clientIP returns the originating client IP. X-Forwarded-For is honored
+// only when the direct peer is a trusted proxy; it is then walked from the
+// right, skipping further trusted proxies, so a client cannot spoof its
+// address by prepending entries.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+    // 1) The direct peer is the answer unless it's one of our proxies.
+    peer, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        peer = r.RemoteAddr
+    }
+    if !isTrusted(peer, trusted) {
+        return peer
+    }
+
+    // 2) Walk X-Forwarded-For right to left; the first untrusted hop is the client.
+    hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+    for i := len(hops) - 1; i >= 0; i-- {
+        hop := strings.TrimSpace(hops[i])
+        if hop == "" {
+            continue
+        }
+        if !isTrusted(hop, trusted) {
+            return hop
+        }
+        peer = hop
+    }
+
+    // 3) Every hop was a trusted proxy: use the left-most one we saw.
+    return peer
+}
+
+// isTrusted reports whether ip falls inside one of the trusted networks.
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return false
+    }
+    for _, n := range trusted {
+        if n.Contains(parsed) {
+            return true
+        }
+    }
+    return false
+}
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs or bare IPs
+// (e.g. "10.0.0.0/8, 127.0.0.1") into networks for RateLimitConfig.
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+    var nets []*net.IPNet
+    for _, item := range strings.Split(list, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        // A bare IP is a single-address network.
+        if !strings.Contains(item, "/") {
+            ip := net.ParseIP(item)
+            if ip == nil {
+                return nil, fmt.Errorf("invalid trusted proxy %q", item)
+            }
+            bits := 8 * len(ip.To16())
+            if ip.To4() != nil {
+                ip, bits = ip.To4(), 32
+            }
+            nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+            continue
+        }
+        _, n, err := net.ParseCIDR(item)
+        if err != nil {
+            return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+        }
+        nets = append(nets, n)
+    }
+    return nets, nil
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+    return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+    "net/http"          // for HTTP status codes and handler types
+    "net/http/httptest" // to create fake Requests and ResponseRecorders
+    "testing"           // the Go testing framework
+    "time"              // rate limit periods
+
+    "github.com/adrian/gif-backend/models"  // signed-in users
+    "github.com/adrian/gif-backend/storage" // their tokens
+)
+
+// TestRateLimiter_PerClient verifies that each client gets its own budget,
+// and that the 429 carries Retry-After and RateLimit-* headers.
+func TestRateLimiter_PerClient(t *testing.T) {
+    limiter := NewRateLimiter(RateLimitConfig{Name: "test", Limit: 2, Per: time.Minute})
+    ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+    h := limiter.Middleware(ok)
+
+    // call issues one request from addr and returns the recorder.
+    call := func(addr string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/api/search?q=x", nil)
+        req.RemoteAddr = addr
+        w := httptest.NewRecorder()
+        h.ServeHTTP(w, req)
+        return w
+    }
+
+    // 1) Client A spends its two tokens, then is limited.
+    call("192.0.2.1:1234")
+    if w := call("192.0.2.1:1234"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" {
+        t.Fatalf("expected 200 with 0 remaining; got %d / %q", w.Code, w.Header().Get("RateLimit-Remaining"))
+    }
+    w := call("192.0.2.1:1234")
+    if w.Code != http.StatusTooManyRequests {
+        t.Fatalf("expected 429; got %d", w.Code)
+    }
+    if w.Header().Get("Retry-After") == "" || w.Header().Get("RateLimit-Limit") != "2" {
+        t.Errorf("missing rate limit headers: %v", w.Header())
+    }
+
+    // 2) Client B is unaffected.
+    if w := call("192.0.2.2:1234"); w.Code != http.StatusOK {
+        t.Errorf("expected other client to be allowed; got %d", w.Code)
+    }
+
+    // 3) An unverified token doesn't buy client A a fresh budget...
+    req := httptest.NewRequest(http.MethodGet, "/api/search?q=x", nil)
+    req.RemoteAddr = "192.0.2.1:1234"
+    req.Header.Set("Authorization", "Bearer made-up")
+    w = httptest.NewRecorder()
+    h.ServeHTTP(w, req)
+    if w.Code != http.StatusTooManyRequests {
+        t.Errorf("expected an unverified token to share the IP's budget; got %d", w.Code)
+    }
+
+    // 4) ...but a signed-in user has their own.
+    req = req.WithContext(withAuth(req.Context(), models.User{ID: "u1"}, storage.Token{}))
+    w = httptest.NewRecorder()
+    h.ServeHTTP(w, req)
+    if w.Code != http.StatusOK {
+        t.Errorf("expected a signed-in user to have their own budget; got %d", w.Code)
+    }
+
+    // 5) Keyed by IP, signing in doesn't buy a fresh budget either.
+    byIP := NewRateLimiter(RateLimitConfig{Name: "signup", Limit: 1, Per: time.Minute, ByIP: true}).Middleware(ok)
+    byIP.ServeHTTP(httptest.NewRecorder(), req)
+    req = req.WithContext(withAuth(req.Context(), models.User{ID: "u2"}, storage.Token{}))
+    w = httptest.NewRecorder()
+    byIP.ServeHTTP(w, req)
+    if w.Code != http.StatusTooManyRequests {
+        t.Errorf("expected another user from the same IP to share its budget; got %d", w.Code)
+    }
+}
+
+// TestClientIP_TrustedProxies verifies that X-Forwarded-For is honored only
+// when the peer is a trusted proxy, and cannot be spoofed by prepending hops.
+func TestClientIP_TrustedProxies(t *testing.T) {
+    trusted, err := ParseTrustedProxies("10.0.0.0/8, 127.0.0.1")
+    if err != nil {
+        t.Fatalf("ParseTrustedProxies: %v", err)
+    }
+
+    cases := []struct {
+        name, remote, xff, want string
+    }{
+        {"untrusted peer ignores header", "203.0.113.9:80", "198.51.100.1", "203.0.113.9"},
+        {"trusted peer uses header", "10.1.2.3:80", "198.51.100.1", "198.51.100.1"},
+        {"spoofed left-most hop ignored", "127.0.0.1:80", "1.2.3.4, 198.51.100.1, 10.0.0.5", "198.51.100.1"},
+    }
+    for _, tc := range cases {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        req.RemoteAddr = tc.remote
+        req.Header.Set("X-Forwarded-For", tc.xff)
+        if got := clientIP(req, trusted); got != tc.want {
+            t.Errorf("%s: expected %s; got %s", tc.name, tc.want, got)
+        }
+    }
+}
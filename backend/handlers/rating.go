@@ -0,0 +1,130 @@
+package handlers
+
+import (
+    "fmt"      // for formatting policy errors
+    "net/http" // to identify the calling client
+    "strings"  // for parsing the per-client list
+
+    "github.com/adrian/gif-backend/models" // the rating enumeration
+)
+
+// RatingLimits is the default and maximum content rating for a deployment
+// or a single API client. Empty fields fall back to models.RatingG, so
+// anything above it must be allowed explicitly.
+type RatingLimits struct {
+    // Default is used when a request doesn't ask for a rating.
+    Default string
+
+    // Max is the highest rating a request may ask for.
+    Max string
+}
+
+// RatingPolicy decides the effective content rating of each request.
+// Clients identified by an API token (see requestToken) get their own
+// limits; everyone else gets the deployment's.
+type RatingPolicy struct {
+    // Deployment applies to requests without a listed client token.
+    Deployment RatingLimits
+
+    // Clients maps API tokens to their own limits, replacing Deployment.
+    Clients map[string]RatingLimits
+}
+
+// Validate checks that every rating in the policy is known and that no
+// default exceeds its maximum.
+func (p RatingPolicy) Validate() error {
+    if err := p.Deployment.validate(); err != nil {
+        return fmt.Errorf("deployment: %w", err)
+    }
+    for token, limits := range p.Clients {
+        if err := limits.validate(); err != nil {
+            return fmt.Errorf("client %s…: %w", token[:min(4, len(token))], err)
+        }
+    }
+    return nil
+}
+
+// limitsFor returns the default and maximum rating for the caller of r.
+func (p RatingPolicy) limitsFor(r *http.Request) (def, max string) {
+    if l, ok := p.Clients[requestToken(r)]; ok {
+        return l.defaults()
+    }
+    return p.Deployment.defaults()
+}
+
+// resolve returns the effective rating for the caller of r: raw, the
+// requested rating, if present and allowed, otherwise the caller's default.
+// A rating that is unknown, or above the caller's maximum, is an error whose
+// message is the reason reported for the field.
+func (p RatingPolicy) resolve(r *http.Request, raw string) (string, error) {
+    // 1) No rating requested: use the default.
+    def, max := p.limitsFor(r)
+    if raw == "" {
+        return def, nil
+    }
+
+    // 2) Otherwise it must be a known rating within the maximum.
+    rating, ok := models.ParseRating(raw)
+    if !ok {
+        return "", fmt.Errorf("must be one of %s", strings.Join(models.Ratings, ", "))
+    }
+    if !models.RatingAtMost(rating, max) {
+        return "", fmt.Errorf("may be at most '%s'", max)
+    }
+    return rating, nil
+}
+
+// allows reports whether the caller of r may see a GIF rated rating.
+func (p RatingPolicy) allows(r *http.Request, rating string) bool {
+    _, max := p.limitsFor(r)
+    return models.RatingAtMost(rating, max)
+}
+
+// defaults returns the limits with empty fields filled in.
+func (l RatingLimits) defaults() (def, max string) {
+    def, max = l.Default, l.Max
+    if def == "" {
+        def = models.RatingG
+    }
+    if max == "" {
+        max = models.RatingG
+    }
+    return def, max
+}
+
+// validate checks one set of limits.
+func (l RatingLimits) validate() error {
+    def, max := l.defaults()
+    if _, ok := models.ParseRating(def); !ok {
+        return fmt.Errorf("unknown default rating %q", def)
+    }
+    if _, ok := models.ParseRating(max); !ok {
+        return fmt.Errorf("unknown maximum rating %q", max)
+    }
+    if !models.RatingAtMost(def, max) {
+        return fmt.Errorf("default rating %q exceeds maximum %q", def, max)
+    }
+    return nil
+}
+
+// ParseClientRatings parses per-client limits from a comma-separated list
+// of token:default:max entries (e.g. "abc123:pg:pg-13, def456:g:r"), for
+// RatingPolicy.Clients.
+func ParseClientRatings(list string) (map[string]RatingLimits, error) {
+    clients := make(map[string]RatingLimits)
+    for _, item := range strings.Split(list, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        parts := strings.Split(item, ":")
+        if len(parts) != 3 || parts[0] == "" {
+            return nil, fmt.Errorf("invalid client rating entry %q: want token:default:max", item)
+        }
+        clients[parts[0]] = RatingLimits{
+            Default: strings.ToLower(parts[1]),
+            Max:     strings.ToLower(parts[2]),
+        }
+    }
+    return clients, nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+    "encoding/json"     // to decode the echoed rating
+    "net/http"          // for HTTP status codes and method constants
+    "net/http/httptest" // to create fake Requests and ResponseRecorders
+    "testing"           // the Go testing framework
+
+    "github.com/adrian/gif-backend/utils" // Provider parameter and GIF types
+)
+
+// TestRatingPolicy verifies defaults, validation and per-client limits: the
+// effective rating reaches the provider and is echoed in "meta.rating".
+func TestRatingPolicy(t *testing.T) {
+    clients, err := ParseClientRatings("partner-key:pg:r")
+    if err != nil {
+        t.Fatalf("ParseClientRatings error: %v", err)
+    }
+    policy := RatingPolicy{Deployment: RatingLimits{Default: "g", Max: "pg"}, Clients: clients}
+    if err := policy.Validate(); err != nil {
+        t.Fatalf("Validate error: %v", err)
+    }
+    stub := &stubProvider{}
+    h := NewGifHandler(stub, policy, nil, nil)
+
+    // search issues one search with the given rating param and API key.
+    search := func(rating, key string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodGet, "/api/search?q=cats&rating="+rating, nil)
+        if key != "" {
+            req.Header.Set("X-API-Key", key)
+        }
+        w := httptest.NewRecorder()
+        h.SearchGIFs(w, req)
+        return w
+    }
+
+    // 1) No rating: the deployment default applies and is echoed.
+    w := search("", "")
+    var body struct {
+        Meta struct{ Rating string } `json:"meta"`
+    }
+    json.NewDecoder(w.Body).Decode(&body)
+    if stub.lastSearch.Rating != "g" || body.Meta.Rating != "g" {
+        t.Errorf("expected default rating g; got provider %q, meta %q", stub.lastSearch.Rating, body.Meta.Rating)
+    }
+
+    // 2) Unknown ratings and ratings above the maximum are rejected.
+    for _, rating := range []string{"x", "r"} {
+        if w := search(rating, ""); w.Code != http.StatusBadRequest {
+            t.Errorf("rating=%s: expected status 400; got %d", rating, w.Code)
+        }
+    }
+
+    // 3) A listed client gets its own default and maximum.
+    search("", "partner-key")
+    if stub.lastSearch.Rating != "pg" {
+        t.Errorf("expected client default pg; got %q", stub.lastSearch.Rating)
+    }
+    if w := search("R", "partner-key"); w.Code != http.StatusOK || stub.lastSearch.Rating != "r" {
+        t.Errorf("expected client to be allowed r; got %d, %q", w.Code, stub.lastSearch.Rating)
+    }
+
+    // 4) A default above the maximum is a configuration error.
+    bad := RatingPolicy{Deployment: RatingLimits{Default: "r", Max: "pg"}}
+    if err := bad.Validate(); err == nil {
+        t.Error("expected Validate to reject default above maximum")
+    }
+}
+
+// TestRatingPolicy_Lookup verifies that lookups by ID also respect the
+// caller's maximum rating.
+func TestRatingPolicy_Lookup(t *testing.T) {
+    stub := &stubProvider{gifs: []utils.Gif{{ID: "a", Rating: "g"}, {ID: "b", Rating: "r"}}}
+    h := NewGifHandler(stub, RatingPolicy{Deployment: RatingLimits{Max: "pg-13"}}, nil, nil)
+
+    w := httptest.NewRecorder()
+    h.GetGIFs(w, httptest.NewRequest(http.MethodGet, "/api/gifs?ids=a,b", nil))
+    var body struct {
+        Data   []struct{ ID string } `json:"data"`
+        Errors []struct {
+            ID     string `json:"id"`
+            Status int    `json:"status"`
+        } `json:"errors"`
+    }
+    if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+        t.Fatalf("decode error: %v", err)
+    }
+    if len(body.Data) != 1 || body.Data[0].ID != "a" {
+        t.Errorf("expected only GIF a; got %+v", body.Data)
+    }
+    if len(body.Errors) != 1 || body.Errors[0].ID != "b" || body.Errors[0].Status != http.StatusForbidden {
+        t.Errorf("expected 403 for GIF b; got %+v", body.Errors)
+    }
+}
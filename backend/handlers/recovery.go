@@ -1,7 +1,6 @@
 package handlers
 
 import (
-    "encoding/json"       // JSON encoding for error responses
     "net/http"            // HTTP types for handlers and status codes
 
     "github.com/sirupsen/logrus" // Structured logging
@@ -15,16 +14,15 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
         // Defer a function that will run if a panic occurs below.
         defer func() {
             if rec := recover(); rec != nil {
-                // 1) Log the panic value for diagnosis.
-                logrus.WithField("panic", rec).Error("panic recovered in handler")
+                // 1) Log the panic value for diagnosis, with the request ID
+                //    the client will see.
+                logrus.WithFields(logrus.Fields{
+                    "panic":      rec,
+                    "request_id": RequestIDFrom(r.Context()),
+                }).Error("panic recovered in handler")
 
-                // 2) Prepare a JSON error response for the client.
-                w.Header().Set("Content-Type", "application/json")
-                w.WriteHeader(http.StatusInternalServerError)
-                // 3) Encode a simple {"error":"internal server error"} payload.
-                json.NewEncoder(w).Encode(map[string]string{
-                    "error": "internal server error",
-                })
+                // 2) Answer with the same problem document as every other error.
+                writeError(w, r, http.StatusInternalServerError, "Internal server error")
             }
         }()
         // Call the next handler in the chain. If it panics, our defer will catch it.
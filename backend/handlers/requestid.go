@@ -0,0 +1,49 @@
+package handlers
+
+import (
+    "context"      // carrying the ID through the request
+    "crypto/rand"  // generating IDs
+    "encoding/hex" // formatting IDs
+    "net/http"     // middleware types
+    "regexp"       // validating client-supplied IDs
+)
+
+// requestIDKey is the context key for the request ID.
+type requestIDKey struct{}
+
+// requestIDPattern matches the client-supplied X-Request-ID values we
+// adopt; anything else is replaced so IDs are safe to log and echo.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestIDMiddleware gives every request an ID: the caller's X-Request-ID
+// if it is well-formed (so IDs from a proxy carry through), otherwise a new
+// random one. The ID is echoed in the X-Request-ID response header, stored
+// in the context for logs and problem documents, and must be registered
+// before every other middleware so they all see it.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // 1) Adopt or generate the ID.
+        id := r.Header.Get("X-Request-ID")
+        if !requestIDPattern.MatchString(id) {
+            id = newRequestID()
+        }
+
+        // 2) Echo it and pass it down.
+        w.Header().Set("X-Request-ID", id)
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+    })
+}
+
+// RequestIDFrom returns the request ID stored by RequestIDMiddleware, or ""
+// outside of it.
+func RequestIDFrom(ctx context.Context) string {
+    id, _ := ctx.Value(requestIDKey{}).(string)
+    return id
+}
+
+// newRequestID returns 16 random hex characters.
+func newRequestID() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
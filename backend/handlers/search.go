@@ -3,56 +3,83 @@ package handlers
 import (
     "encoding/json"                   // for encoding Go values to JSON
     "net/http"                        // for HTTP request and response types
-    "os"                              // to access environment variables
-    "strconv"                         // for converting strings to integers
+    "net/url"                         // canonical page queries for cursors
+    "strconv"                         // for formatting the limit
 
-    "github.com/adrian/gif-backend/utils" // our internal package for Giphy API calls
+    "github.com/adrian/gif-backend/models" // provider-neutral response schema
+    "github.com/adrian/gif-backend/utils"  // our internal package for provider parameter types
+    "github.com/sirupsen/logrus"           // logging history failures
 )
 
-// SearchGIFs handles GET /api/search requests. It reads query parameters,
-// calls the Giphy search utility, and returns a JSON payload of GIFs.
-func SearchGIFs(w http.ResponseWriter, r *http.Request) {
-    // 1) Extract query parameters from the URL
-    q := r.URL.Query().Get("q")         // search term (required)
-    limit := r.URL.Query().Get("limit") // number of items per page
-    page := r.URL.Query().Get("page")   // pagination page number
-    rating := r.URL.Query().Get("rating") // content rating filter (optional)
-
-    // 2) Validate required parameters
-    if q == "" {
-        // If 'q' is missing, return 400 Bad Request with an error message
-        http.Error(w, "Query param 'q' is required", http.StatusBadRequest)
+// SearchGIFs handles GET /api/search requests. It reads query parameters
+// (or a cursor), asks the provider to search, and returns a JSON payload of GIFs.
+func (h *GifHandler) SearchGIFs(w http.ResponseWriter, r *http.Request) {
+    // 1) Parse and validate every query parameter, then report all
+    //    invalid ones together in a single 400. A cursor stands in for them all.
+    params := parseQuery(r)
+    params.cursor(h.cursors, "search")
+    q := params.text("q", true)               // search term (required)
+    limitInt, offset := params.pagination()   // page size and zero-based offset
+    mediaType := params.mediaType()           // GIFs (default) or stickers
+    rating := params.rating(h.ratings)        // effective content rating
+    lang := params.lang()                     // language of the query
+    if !params.valid(w) {
         return
     }
 
-    // 3) Provide default values if optional params are omitted
-    if limit == "" {
-        limit = "12"
-    }
-    if page == "" {
-        page = "1"
+    // 2) Ask the provider to search, which returns a GiphyResponse struct.
+    //    The context carries a CacheInfo the cache layer fills in.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    result, err := h.provider.Search(ctx, utils.SearchParams{
+        Type:   mediaType,
+        Query:  q,
+        Rating: rating,
+        Lang:   lang,
+        Limit:  limitInt,
+        Offset: offset,
+    })
+    if err != nil {
+        // Map the provider error (rate limit, auth, outage, ...) to a response.
+        writeProviderError(w, r, err, "Failed to fetch search results", "")
+        return
     }
 
-    // 4) Convert limit and page to integers, ignoring errors (defaults apply)
-    limitInt, _ := strconv.Atoi(limit)
-    pageInt, _ := strconv.Atoi(page)
+    // 3) Write the successful JSON response
+    w.Header().Set("Content-Type", "application/json") // tell the client it’s JSON
+    writeCacheHeaders(w, cacheInfo)                      // X-Cache / Age / Warning
+    // Map the provider response into our versioned schema, echo the
+    // effective rating and language, link the neighbouring pages, and encode it
+    resp := models.FromGiphyResponse(result, limitInt)
+    resp.Meta.Rating = rating
+    resp.Meta.Lang = lang
+    page := url.Values{
+        "q":      {q},
+        "type":   {string(mediaType)},
+        "rating": {rating},
+        "lang":   {lang},
+        "limit":  {strconv.Itoa(limitInt)},
+    }
+    h.paginate(w, r, "search", page, offset, &resp)
+    json.NewEncoder(w).Encode(resp)
 
-    // 5) Ensure the GIPHY_API_KEY is set in the environment
-    if os.Getenv("GIPHY_API_KEY") == "" {
-        http.Error(w, "Server misconfigured: missing GIPHY_API_KEY", http.StatusInternalServerError)
-        return
+    // 4) Remember the search for a signed-in user. Only a fresh search
+    //    counts, not paging through its results.
+    if offset == 0 && !params.fromCursor {
+        h.recordSearch(r, q)
     }
+}
 
-    // 6) Call the typed SearchGIFs utility, which returns a GiphyResponse struct
-    result, err := utils.SearchGIFs(q, rating, limitInt, pageInt)
-    if err != nil {
-        // If the Giphy API call fails, return 500 Internal Server Error
-        http.Error(w, "Failed to fetch search results", http.StatusInternalServerError)
+// recordSearch adds q to the signed-in user's search history, if there is
+// one. Failures are logged; they never fail the search.
+func (h *GifHandler) recordSearch(r *http.Request, q string) {
+    user, ok := UserFrom(r.Context())
+    if !ok || h.history == nil {
         return
     }
-
-    // 7) Write the successful JSON response
-    w.Header().Set("Content-Type", "application/json") // tell the client it’s JSON
-    // Encode the GiphyResponse directly to the HTTP response body
-    json.NewEncoder(w).Encode(result)
+    if err := h.history.AddSearch(r.Context(), user.ID, q); err != nil {
+        logrus.WithFields(logrus.Fields{
+            "error":      err,
+            "request_id": RequestIDFrom(r.Context()),
+        }).Warn("cannot record search history")
+    }
 }
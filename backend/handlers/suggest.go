@@ -0,0 +1,72 @@
+package handlers
+
+import (
+    "encoding/json" // JSON encoding for responses
+    "net/http"      // HTTP request/response types
+
+    "github.com/adrian/gif-backend/models" // provider-neutral response schema
+    "github.com/adrian/gif-backend/utils"  // provider parameter types
+)
+
+// Autocomplete limits: suggestions are shown in a short dropdown.
+const (
+    defaultSuggestLimit = 5
+    maxSuggestLimit     = 20
+)
+
+// suggestMaxAge lets browsers reuse suggestions while the user types and
+// deletes, so a debounced search box sends even fewer requests.
+const suggestMaxAge = "public, max-age=60"
+
+// Autocomplete handles GET /api/autocomplete?q=…&limit=…. It suggests
+// search terms completing what the user has typed so far.
+func (h *GifHandler) Autocomplete(w http.ResponseWriter, r *http.Request) {
+    // 1) The partial term is required; limit is optional, from 1 to maxSuggestLimit.
+    params := parseQuery(r)
+    q := params.text("q", true)
+    limit := params.intRange("limit", defaultSuggestLimit, 1, maxSuggestLimit)
+    if !params.valid(w) {
+        return
+    }
+
+    // 2) Ask the provider (through the short-TTL cache) for suggestions.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    tags, err := h.provider.Autocomplete(ctx, utils.AutocompleteParams{Query: q, Limit: limit})
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to fetch suggestions", "")
+        return
+    }
+
+    // 3) Encode the terms.
+    writeTerms(w, cacheInfo, models.FromGiphyTags(q, tags))
+}
+
+// RelatedTerms handles GET /api/related?term=…. It returns search terms
+// related to a term, for "try also" suggestions next to results.
+func (h *GifHandler) RelatedTerms(w http.ResponseWriter, r *http.Request) {
+    // 1) The term is required.
+    params := parseQuery(r)
+    term := params.text("term", true)
+    if !params.valid(w) {
+        return
+    }
+
+    // 2) Ask the provider (through the short-TTL cache) for related terms.
+    ctx, cacheInfo := utils.WithCacheInfo(r.Context())
+    tags, err := h.provider.RelatedTerms(ctx, term)
+    if err != nil {
+        writeProviderError(w, r, err, "Failed to fetch related terms", "")
+        return
+    }
+
+    // 3) Encode the terms.
+    writeTerms(w, cacheInfo, models.FromGiphyTags(term, tags))
+}
+
+// writeTerms writes a successful suggestions response.
+func writeTerms(w http.ResponseWriter, info *utils.CacheInfo, resp models.TermsResponse) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Header().Set("Cache-Control", suggestMaxAge)
+    writeCacheHeaders(w, info)
+    json.NewEncoder(w).Encode(resp)
+}
@@ -1,11 +1,20 @@
 package main
 
 import (
+    "context"                       // legacy data at startup, shutdown deadline
+    "errors"                        // recognizing a missing legacy owner or a closed server
     "log"                           // standard logging (used briefly for fallback)
+    "net"                           // trusted proxy networks
     "net/http"                      // HTTP server and handler types
     "os"                            // for reading environment variables
+    "os/signal"                     // shutting down on SIGINT/SIGTERM
+    "syscall"                       // SIGTERM
+    "time"                          // default durations for settings
 
     "github.com/adrian/gif-backend/handlers" // our HTTP handlers and middleware
+    "github.com/adrian/gif-backend/models"   // content rating names
+    "github.com/adrian/gif-backend/storage"  // persisted users and their data
+    "github.com/adrian/gif-backend/utils"    // GIF providers (Giphy client)
     "github.com/gorilla/mux"                // request router
     "github.com/joho/godotenv"              // loads .env files into environment
     "github.com/sirupsen/logrus"            // structured, leveled logging
@@ -20,7 +29,8 @@ func main() {
 
     // 2) Ensure the GIPHY_API_KEY is set; this is critical for our service to work.
     //    If missing, we fatally exit (no sense running without an API key).
-    if os.Getenv("GIPHY_API_KEY") == "" {
+    apiKey := os.Getenv("GIPHY_API_KEY")
+    if apiKey == "" {
         logrus.Fatal("GIPHY_API_KEY is not set")
     }
 
@@ -34,10 +44,18 @@ func main() {
     //    handlers.ExposeMetricsHandler returns the promhttp.Handler.
     r.Handle("/metrics", handlers.ExposeMetricsHandler())
 
-    // 6) Register our panic-recovery middleware first.
-    //    It will catch any panics in downstream handlers, log them, and return a 500 response.
+    // 6) Tag every request with an ID first, so logs and error documents from
+    //    every later middleware carry it. Then register our panic-recovery
+    //    middleware: it catches panics in downstream handlers, logs them, and
+    //    returns a 500 problem document.
+    r.Use(handlers.RequestIDMiddleware)
     r.Use(handlers.RecoveryMiddleware)
 
+    //    Unmatched routes get problem documents too. Router middleware doesn't
+    //    run for them, so they get their request ID directly.
+    r.NotFoundHandler = handlers.RequestIDMiddleware(http.HandlerFunc(handlers.NotFound))
+    r.MethodNotAllowedHandler = handlers.RequestIDMiddleware(http.HandlerFunc(handlers.MethodNotAllowed))
+
     // 7) CORS middleware: allow ONLY our React frontend origin to make requests.
     //    We use both the built-in Mux CORSMethodMiddleware and our custom handler.
     r.Use(mux.CORSMethodMiddleware(r))
@@ -46,36 +64,217 @@ func main() {
     // 8) Logging & metrics middleware: logs each request and updates Prometheus counters.
     r.Use(handlers.LoggingAndMetricsMiddleware)
 
-    // 9) Health and readiness probes: simple JSON endpoints for uptime checks.
+    // 9) Build the GIF provider and inject it into the API handlers.
+    //    Swapping the source, or wrapping it, happens here and nowhere else.
+    //    The Giphy client owns its API key, HTTP client, timeouts, retry policy
+    //    and request budget.
+    giphy := utils.NewGiphyClient(utils.GiphyConfig{
+        APIKey:    apiKey,
+        BaseURL:   envString("GIPHY_BASE_URL", utils.DefaultGiphyBaseURL),
+        Timeout:   envDuration("GIPHY_TIMEOUT", utils.DefaultGiphyTimeout),
+        UserAgent: envString("GIPHY_USER_AGENT", utils.DefaultUserAgent),
+        Retry: utils.RetryPolicy{
+            MaxAttempts: envInt("GIPHY_RETRY_MAX_ATTEMPTS", utils.DefaultRetryMaxAttempts),
+            BaseDelay:   envDuration("GIPHY_RETRY_BASE_DELAY", utils.DefaultRetryBaseDelay),
+            MaxDelay:    envDuration("GIPHY_RETRY_MAX_DELAY", utils.DefaultRetryMaxDelay),
+        },
+        // Client-side budget for the API key (e.g. 100 per 1h on a beta key).
+        RateLimit: utils.RateLimitConfig{
+            Limit:   envInt("GIPHY_RATE_LIMIT", 0),
+            Per:     envDuration("GIPHY_RATE_LIMIT_PERIOD", time.Hour),
+            Burst:   envInt("GIPHY_RATE_LIMIT_BURST", 0),
+            MaxWait: envDuration("GIPHY_RATE_LIMIT_MAX_WAIT", 0),
+        },
+    })
+
+    //    Guard it with a circuit breaker so a Giphy outage fails fast.
+    breaker := utils.NewBreakerProvider(giphy, utils.BreakerConfig{
+        FailureThreshold: envInt("BREAKER_FAILURE_THRESHOLD", utils.DefaultBreakerFailureThreshold),
+        CoolDown:         envDuration("BREAKER_COOL_DOWN", utils.DefaultBreakerCoolDown),
+        HalfOpenProbes:   envInt("BREAKER_HALF_OPEN_PROBES", utils.DefaultBreakerHalfOpenProbes),
+    })
+
+    //    Wrap it in an in-memory LRU cache so repeated trending/search
+    //    requests don't each spend upstream quota. Expired entries are still
+    //    served while refreshing, or when Giphy is failing.
+    //    While the circuit is open the cache answers with stale data when it can.
+    //    Search suggestions get their own, shorter TTL; categories a longer one.
+    suggestTTL := envDuration("CACHE_SUGGEST_TTL", utils.DefaultSuggestTTL)
+    categoriesTTL := envDuration("CACHE_CATEGORIES_TTL", utils.DefaultCategoriesTTL)
+    provider := utils.NewCachedProvider(breaker, utils.CacheConfig{
+        TTL:                  envDuration("CACHE_TTL", utils.DefaultCacheTTL),
+        MaxEntries:           envInt("CACHE_MAX_ENTRIES", utils.DefaultCacheMaxEntries),
+        MaxBytes:             int64(envInt("CACHE_MAX_BYTES", utils.DefaultCacheMaxBytes)),
+        StaleWhileRevalidate: envDuration("CACHE_STALE_WHILE_REVALIDATE", utils.DefaultStaleWhileRevalidate),
+        StaleIfError:         envDuration("CACHE_STALE_IF_ERROR", utils.DefaultStaleIfError),
+        EndpointTTL: map[string]time.Duration{
+            "autocomplete":  suggestTTL,
+            "related":       suggestTTL,
+            "categories":    categoriesTTL,
+            "subcategories": categoriesTTL,
+        },
+    })
+
+    //    The rating policy sets each request's default and maximum content
+    //    rating, deployment-wide or per API client token.
+    clientRatings, err := handlers.ParseClientRatings(os.Getenv("RATING_CLIENTS"))
+    if err != nil {
+        logrus.WithError(err).Fatal("invalid RATING_CLIENTS")
+    }
+    ratings := handlers.RatingPolicy{
+        Deployment: handlers.RatingLimits{
+            Default: envString("RATING_DEFAULT", models.RatingG),
+            Max:     envString("RATING_MAX", models.RatingG),
+        },
+        Clients: clientRatings,
+    }
+    if err := ratings.Validate(); err != nil {
+        logrus.WithError(err).Fatal("invalid rating policy")
+    }
+    //    Pagination cursors are signed with CURSOR_SECRET. Without one, a
+    //    random key is used and cursors don't survive restarts or cross replicas.
+    cursorSecret := os.Getenv("CURSOR_SECRET")
+    if cursorSecret == "" {
+        logrus.Warn("CURSOR_SECRET is not set, using a random key for pagination cursors")
+    }
+
+    //    Users, their tokens, favorites and collections are persisted to a
+    //    JSON file, search history to a second one next to it. Favorites and
+    //    collections hold GIF snapshots, so they render even while Giphy is down.
+    store, err := storage.OpenFileStore(envString("STORAGE_FILE", "data/store.json"))
+    if err != nil {
+        logrus.WithError(err).Fatal("cannot open storage")
+    }
+    //    A file from before accounts existed keeps its favorites and
+    //    collections aside until STORAGE_LEGACY_OWNER names who gets them.
+    if owner := os.Getenv("STORAGE_LEGACY_OWNER"); owner != "" {
+        adopted, err := store.AdoptLegacy(context.Background(), owner)
+        switch {
+        case errors.Is(err, storage.ErrNotFound):
+            logrus.Warnf("STORAGE_LEGACY_OWNER %q has no account yet, legacy favorites stay unassigned", owner)
+        case err != nil:
+            logrus.WithError(err).Fatal("cannot assign legacy favorites")
+        case adopted:
+            logrus.Infof("legacy favorites and collections assigned to %q", owner)
+        }
+    } else if store.HasLegacyData() {
+        logrus.Warn("legacy favorites and collections are unassigned, set STORAGE_LEGACY_OWNER to give them to a user")
+    }
+    gifs := handlers.NewGifHandler(provider, ratings, handlers.NewCursorCodec([]byte(cursorSecret)), store)
+    favorites := handlers.NewFavoritesHandler(store, provider, ratings)
+    collections := handlers.NewCollectionsHandler(store, provider, ratings)
+    accounts := handlers.NewAccountHandler(store, handlers.AccountConfig{
+        AllowSignup:   envBool("ALLOW_SIGNUP", false),
+        AllowGuests:   envBool("ALLOW_GUESTS", false),
+        SessionTTL:    envDuration("SESSION_TTL", handlers.DefaultSessionTTL),
+        SecureCookies: envBool("SESSION_COOKIE_SECURE", false),
+    })
+
+    //    Sign requests in from an API token or session cookie. This runs
+    //    before any route, so every handler can tell who is asking.
+    r.Use(handlers.AuthMiddleware(store))
+
+    // 10) Health and readiness probes: simple JSON endpoints for uptime checks.
+    //     /ready also reports the upstream circuit breaker state.
     r.HandleFunc("/health", handlers.HealthCheck).Methods("GET")
-    r.HandleFunc("/ready", handlers.HealthCheck).Methods("GET")
+    r.HandleFunc("/ready", handlers.ReadinessCheck(breaker)).Methods("GET")
 
-    // 10) API routes are grouped under /api prefix.
+    // 11) Per-client inbound rate limits. Clients are keyed by signed-in user or IP;
+    //     X-Forwarded-For is only believed from TRUSTED_PROXIES.
+    trustedProxies, err := handlers.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+    if err != nil {
+        logrus.WithError(err).Fatal("invalid TRUSTED_PROXIES")
+    }
+    limits := routeLimits{
+        per:            envDuration("RATE_LIMIT_PERIOD", time.Minute),
+        trustedProxies: trustedProxies,
+    }
+
+    // 12) API routes are grouped under /api prefix, each with its own budget.
     api := r.PathPrefix("/api").Subrouter()
-    api.HandleFunc("/trending", handlers.GetTrending).Methods("GET")
-    api.HandleFunc("/search", handlers.SearchGIFs).Methods("GET")
+    api.Handle("/trending", limits.wrap("trending", envInt("RATE_LIMIT_TRENDING", 120), gifs.GetTrending)).Methods("GET")
+    api.Handle("/search", limits.wrap("search", envInt("RATE_LIMIT_SEARCH", 60), gifs.SearchGIFs)).Methods("GET")
+    api.Handle("/gifs", limits.wrap("gifs", envInt("RATE_LIMIT_GIFS", 120), gifs.GetGIFs)).Methods("GET")
+    api.Handle("/gifs/{id}", limits.wrap("gif", envInt("RATE_LIMIT_GIFS", 120), gifs.GetGIF)).Methods("GET")
+    api.Handle("/random", limits.wrap("random", envInt("RATE_LIMIT_RANDOM", 60), gifs.GetRandom)).Methods("GET")
+    api.Handle("/translate", limits.wrap("translate", envInt("RATE_LIMIT_TRANSLATE", 60), gifs.Translate)).Methods("GET")
+    api.Handle("/autocomplete", limits.wrap("autocomplete", envInt("RATE_LIMIT_SUGGEST", 600), gifs.Autocomplete)).Methods("GET")
+    api.Handle("/related", limits.wrap("related", envInt("RATE_LIMIT_SUGGEST", 600), gifs.RelatedTerms)).Methods("GET")
+    api.Handle("/categories", limits.wrap("categories", envInt("RATE_LIMIT_CATEGORIES", 120), gifs.GetCategories)).Methods("GET")
+    api.Handle("/categories/{name}", limits.wrap("category", envInt("RATE_LIMIT_CATEGORIES", 120), gifs.GetCategory)).Methods("GET")
 
-    // 11) Determine the port to listen on. Default to 5050 if PORT env var is missing.
-    port := os.Getenv("PORT")
-    if port == "" {
-        port = "5050"
-    }
+    //     Favorites and collections change state, so their routes also match
+    //     OPTIONS for CORS preflights.
+    favoritesLimit := envInt("RATE_LIMIT_FAVORITES", 120)
+    api.Handle("/favorites", limits.wrap("favorites", favoritesLimit, favorites.ListFavorites)).Methods("GET", "OPTIONS")
+    api.Handle("/favorites", limits.wrap("favorite_add", favoritesLimit, favorites.AddFavorite)).Methods("POST")
+    api.Handle("/favorites/order", limits.wrap("favorites_order", favoritesLimit, favorites.ReorderFavorites)).Methods("PUT", "OPTIONS")
+    api.Handle("/favorites/{id}", limits.wrap("favorite_remove", favoritesLimit, favorites.RemoveFavorite)).Methods("DELETE", "OPTIONS")
+    collectionsLimit := envInt("RATE_LIMIT_COLLECTIONS", 120)
+    api.Handle("/collections", limits.wrap("collections", collectionsLimit, collections.ListCollections)).Methods("GET", "OPTIONS")
+    api.Handle("/collections", limits.wrap("collection_create", collectionsLimit, collections.CreateCollection)).Methods("POST")
+    api.Handle("/collections/{id}", limits.wrap("collection", collectionsLimit, collections.GetCollection)).Methods("GET", "OPTIONS")
+    api.Handle("/collections/{id}", limits.wrap("collection_update", collectionsLimit, collections.UpdateCollection)).Methods("PATCH")
+    api.Handle("/collections/{id}", limits.wrap("collection_delete", collectionsLimit, collections.DeleteCollection)).Methods("DELETE")
+    api.Handle("/collections/{id}/gifs", limits.wrap("collection_add", collectionsLimit, collections.AddGIF)).Methods("POST", "OPTIONS")
+    api.Handle("/collections/{id}/gifs/{gif}", limits.wrap("collection_move", collectionsLimit, collections.MoveGIF)).Methods("PATCH", "OPTIONS")
+    api.Handle("/collections/{id}/gifs/{gif}", limits.wrap("collection_remove", collectionsLimit, collections.RemoveGIF)).Methods("DELETE")
+
+    //     Accounts: sign-up and guests share a tighter budget, kept per IP.
+    accountLimit := envInt("RATE_LIMIT_ACCOUNT", 60)
+    signupLimit := envInt("RATE_LIMIT_SIGNUP", 5)
+    api.Handle("/users", limits.wrapByIP("signup", signupLimit, accounts.Signup)).Methods("POST", "OPTIONS")
+    api.Handle("/guests", limits.wrapByIP("guest", signupLimit, accounts.CreateGuest)).Methods("POST", "OPTIONS")
+    api.Handle("/me", limits.wrap("me", accountLimit, accounts.Me)).Methods("GET", "OPTIONS")
+    api.Handle("/session", limits.wrap("session_create", accountLimit, accounts.CreateSession)).Methods("POST", "OPTIONS")
+    api.Handle("/session", limits.wrap("session_delete", accountLimit, accounts.DeleteSession)).Methods("DELETE")
+    api.Handle("/tokens", limits.wrap("tokens", accountLimit, accounts.ListTokens)).Methods("GET", "OPTIONS")
+    api.Handle("/tokens", limits.wrap("token_create", accountLimit, accounts.CreateToken)).Methods("POST")
+    api.Handle("/tokens/{id}", limits.wrap("token_revoke", accountLimit, accounts.RevokeToken)).Methods("DELETE", "OPTIONS")
+    api.Handle("/history", limits.wrap("history", accountLimit, accounts.History)).Methods("GET", "OPTIONS")
+    api.Handle("/history", limits.wrap("history_clear", accountLimit, accounts.ClearHistory)).Methods("DELETE")
+
+    // 13) Determine the port to listen on. Default to 5050 if PORT env var is missing.
+    port := envString("PORT", "5050")
 
-    // 12) Log an info message indicating where the server is available.
+    // 14) Log an info message indicating where the server is available.
     logrus.Infof("🚀 Backend running on http://localhost:%s", port)
 
-    // 13) Start the HTTP server. If it fails, log.Fatal will exit the process.
-    log.Fatal(http.ListenAndServe(":"+port, r))
+    // 15) Start the HTTP server. If it fails, log.Fatal will exit the process.
+    srv := &http.Server{Addr: ":" + port, Handler: r}
+    go func() {
+        if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+            log.Fatal(err)
+        }
+    }()
+
+    // 16) On SIGINT/SIGTERM, let in-flight requests finish, then write the
+    //     search history still held in memory.
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    <-ctx.Done()
+    logrus.Info("shutting down")
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+    if err := srv.Shutdown(shutdownCtx); err != nil {
+        logrus.WithError(err).Warn("requests still running at shutdown")
+    }
+    if err := store.Close(); err != nil {
+        logrus.WithError(err).Error("cannot write search history")
+    }
 }
 
 // corsMiddleware sets CORS headers to allow cross-origin requests from our React app.
-// It permits only GET and OPTIONS methods and the Content-Type header.
+// It permits the methods our routes use, the headers our clients send, and
+// credentials, so the browser includes the session cookie.
 // For OPTIONS preflight requests, it returns immediately without calling the next handler.
 func corsMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-        w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-        w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+        w.Header().Set("Access-Control-Allow-Credentials", "true") // session cookies
+        w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID")
+        w.Header().Set("Access-Control-Expose-Headers", "Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, X-Cache, X-Request-ID, Link")
         if r.Method == "OPTIONS" {
             // Preflight request: respond with headers only
             return
@@ -84,3 +283,30 @@ func corsMiddleware(next http.Handler) http.Handler {
         next.ServeHTTP(w, r)
     })
 }
+
+// routeLimits holds the settings shared by every per-route rate limiter.
+type routeLimits struct {
+    per            time.Duration // period each route's limit applies to
+    trustedProxies []*net.IPNet  // proxies whose X-Forwarded-For we believe
+}
+
+// wrap returns h behind a per-client rate limiter named name that allows
+// limit requests per period. A limit of 0 or less leaves h unlimited.
+func (rl routeLimits) wrap(name string, limit int, h http.HandlerFunc) http.Handler {
+    return rl.limit(handlers.RateLimitConfig{Name: name, Limit: limit}, h)
+}
+
+// wrapByIP is wrap for routes that create accounts: clients are keyed by
+// IP even when signed in, so a new account can't reset the budget.
+func (rl routeLimits) wrapByIP(name string, limit int, h http.HandlerFunc) http.Handler {
+    return rl.limit(handlers.RateLimitConfig{Name: name, Limit: limit, ByIP: true}, h)
+}
+
+// limit returns h behind a rate limiter configured by cfg and the shared settings.
+func (rl routeLimits) limit(cfg handlers.RateLimitConfig, h http.HandlerFunc) http.Handler {
+    if cfg.Limit <= 0 {
+        return h
+    }
+    cfg.Per, cfg.TrustedProxies = rl.per, rl.trustedProxies
+    return handlers.NewRateLimiter(cfg).Middleware(h)
+}
@@ -0,0 +1,29 @@
+package models
+
+// Category is one node of the category tree clients can browse.
+type Category struct {
+    // Name is the display name ("Animals").
+    Name string `json:"name"`
+
+    // Slug identifies the category in /api/categories/{slug}.
+    Slug string `json:"slug"`
+
+    // GIF is a representative GIF for the category, if the provider has one.
+    GIF *GIF `json:"gif,omitempty"`
+
+    // Subcategories lists the child categories. In the category list they
+    // carry names only; a single category's subcategories include a GIF each.
+    Subcategories []Category `json:"subcategories,omitempty"`
+}
+
+// CategoriesResponse is the envelope for GET /api/categories.
+type CategoriesResponse struct {
+    Version string     `json:"version"`
+    Data    []Category `json:"data"`
+}
+
+// CategoryResponse is the envelope for GET /api/categories/{slug}.
+type CategoryResponse struct {
+    Version string   `json:"version"`
+    Data    Category `json:"data"`
+}
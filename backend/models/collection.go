@@ -0,0 +1,57 @@
+package models
+
+import "time" // collection and item timestamps
+
+// Collection is a named, ordered set of GIFs, e.g. "reactions".
+type Collection struct {
+    // ID is the collection's opaque identifier.
+    ID string `json:"id"`
+
+    // Name is unique among collections, ignoring case.
+    Name string `json:"name"`
+
+    // Description is optional free text.
+    Description string `json:"description,omitempty"`
+
+    // GIFCount is the number of GIFs in the collection.
+    GIFCount int `json:"gif_count"`
+
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CollectionItem is one GIF in a collection. GIF is a snapshot taken when it
+// was added, like a Favorite's.
+type CollectionItem struct {
+    GIF GIF `json:"gif"`
+
+    // AddedAt is when the GIF was added to the collection.
+    AddedAt time.Time `json:"added_at"`
+}
+
+// CollectionsResponse is the envelope for the list of collections.
+type CollectionsResponse struct {
+    Version string       `json:"version"`
+    Data    []Collection `json:"data"`
+}
+
+// CollectionResponse is the envelope for a single collection.
+type CollectionResponse struct {
+    Version string     `json:"version"`
+    Data    Collection `json:"data"`
+}
+
+// CollectionPageResponse is the envelope for a collection with one page of
+// its GIFs, in order.
+type CollectionPageResponse struct {
+    Version    string           `json:"version"`
+    Data       Collection       `json:"data"`
+    Items      []CollectionItem `json:"items"`
+    Pagination Pagination       `json:"pagination"`
+}
+
+// CollectionItemResponse is the envelope for one GIF added to a collection.
+type CollectionItemResponse struct {
+    Version string         `json:"version"`
+    Data    CollectionItem `json:"data"`
+}
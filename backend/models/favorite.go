@@ -0,0 +1,25 @@
+package models
+
+import "time" // when a favorite was added
+
+// Favorite is one GIF a user has saved. GIF is a snapshot taken when it was
+// added, so favorites still render when the provider is unavailable.
+type Favorite struct {
+    GIF GIF `json:"gif"`
+
+    // AddedAt is when the GIF was favorited.
+    AddedAt time.Time `json:"added_at"`
+}
+
+// FavoritesResponse is the envelope for the list of favorites, in the
+// user's order.
+type FavoritesResponse struct {
+    Version string     `json:"version"`
+    Data    []Favorite `json:"data"`
+}
+
+// FavoriteResponse is the envelope for a single favorite.
+type FavoriteResponse struct {
+    Version string   `json:"version"`
+    Data    Favorite `json:"data"`
+}
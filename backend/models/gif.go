@@ -0,0 +1,158 @@
+// Package models defines the provider-neutral JSON schema our API returns.
+// Handlers never encode upstream (Giphy) types directly; each provider maps
+// its own responses into these types, so the frontend is insulated from
+// upstream schema changes.
+package models
+
+// SchemaVersion is reported in every response envelope. Bump it when a
+// field is removed or changes meaning; adding fields does not require it.
+const SchemaVersion = "1"
+
+// Well-known rendition names. Providers should fill at least RenditionDefault;
+// the others are filled when the provider has an equivalent.
+const (
+    RenditionDefault  = "fixed_height"   // grid-sized animated rendition (~200px high)
+    RenditionOriginal = "original"       // full-size original
+    RenditionStill    = "original_still" // first frame of the original
+    RenditionPreview  = "preview_gif"    // tiny animated preview
+)
+
+// Item types reported in GIF.Type.
+const (
+    TypeGIF     = "gif"     // a regular GIF
+    TypeSticker = "sticker" // a sticker (transparent background)
+)
+
+// GIF is one GIF (or sticker) in our API.
+type GIF struct {
+    // ID is the provider's identifier, unique within Source.
+    ID string `json:"id"`
+
+    // Title is the human-readable title.
+    Title string `json:"title"`
+
+    // Type is TypeGIF or TypeSticker.
+    Type string `json:"type"`
+
+    // Source names the provider the GIF came from, e.g. "giphy".
+    Source string `json:"source"`
+
+    // Rating is the content rating ("g", "pg", "pg-13", "r"), if known.
+    Rating string `json:"rating,omitempty"`
+
+    // PageURL links to the GIF's page on the provider's site.
+    PageURL string `json:"page_url,omitempty"`
+
+    // Renditions holds the available sizes/formats keyed by name;
+    // see the Rendition* constants for the names clients can rely on.
+    Renditions map[string]Rendition `json:"renditions"`
+
+    // Attribution credits the uploader, when known.
+    Attribution *Attribution `json:"attribution,omitempty"`
+}
+
+// Rendition is one size/format variant of a GIF.
+type Rendition struct {
+    // URL is the animated GIF (or still image) URL; empty for video-only renditions.
+    URL string `json:"url,omitempty"`
+
+    // MP4 and WebP are alternative encodings of the same rendition, if available.
+    MP4  string `json:"mp4,omitempty"`
+    WebP string `json:"webp,omitempty"`
+
+    // Width and Height are pixel dimensions; Size is the GIF size in bytes.
+    Width  int `json:"width,omitempty"`
+    Height int `json:"height,omitempty"`
+    Size   int `json:"size,omitempty"`
+
+    // Frames is the number of animation frames (0 for stills or when unknown).
+    Frames int `json:"frames,omitempty"`
+}
+
+// Attribution credits whoever uploaded or created a GIF.
+type Attribution struct {
+    Username    string `json:"username,omitempty"`
+    DisplayName string `json:"display_name,omitempty"`
+    ProfileURL  string `json:"profile_url,omitempty"`
+    AvatarURL   string `json:"avatar_url,omitempty"`
+
+    // SourceURL is where the GIF was originally found.
+    SourceURL string `json:"source_url,omitempty"`
+}
+
+// Pagination describes where a page sits in the full result set.
+type Pagination struct {
+    // Offset is the zero-based index of the first item in this page.
+    Offset int `json:"offset"`
+
+    // Limit is the requested page size.
+    Limit int `json:"limit"`
+
+    // Count is the number of items actually returned.
+    Count int `json:"count"`
+
+    // TotalCount is the total number of matching items, if the provider knows it.
+    TotalCount int `json:"total_count"`
+
+    // HasMore reports whether another page exists after this one.
+    HasMore bool `json:"has_more"`
+
+    // NextCursor and PrevCursor are opaque tokens for the neighbouring
+    // pages, passed back as ?cursor=; omitted when there is no such page.
+    NextCursor string `json:"next_cursor,omitempty"`
+    PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// Meta echoes the effective request settings a list was produced with,
+// after defaults and normalization. Unset fields are omitted.
+type Meta struct {
+    // Rating is the content rating filter that was applied.
+    Rating string `json:"rating,omitempty"`
+
+    // Lang is the language the search query was interpreted in.
+    Lang string `json:"lang,omitempty"`
+
+    // Region is the country code trends were localized for.
+    Region string `json:"region,omitempty"`
+}
+
+// ListResponse is the envelope for endpoints returning a page of GIFs.
+type ListResponse struct {
+    Version    string     `json:"version"`
+    Data       []GIF      `json:"data"`
+    Pagination Pagination `json:"pagination"`
+    Meta       Meta       `json:"meta,omitzero"`
+}
+
+// ItemResponse is the envelope for endpoints returning a single GIF.
+type ItemResponse struct {
+    Version string `json:"version"`
+    Data    GIF    `json:"data"`
+    Meta    Meta   `json:"meta,omitzero"`
+}
+
+// NewItemResponse wraps g in a versioned envelope.
+func NewItemResponse(g GIF) ItemResponse {
+    return ItemResponse{Version: SchemaVersion, Data: g}
+}
+
+// BatchResponse is the envelope for batch lookups. GIFs that were found are
+// in Data (in request order); every requested ID that could not be returned
+// has an entry in Errors explaining why.
+type BatchResponse struct {
+    Version string      `json:"version"`
+    Data    []GIF       `json:"data"`
+    Errors  []ItemError `json:"errors,omitempty"`
+}
+
+// ItemError reports why one item of a batch is missing.
+type ItemError struct {
+    // ID is the requested identifier.
+    ID string `json:"id"`
+
+    // Status is the HTTP status a single lookup of ID would have returned.
+    Status int `json:"status"`
+
+    // Message is a human-readable explanation.
+    Message string `json:"message"`
+}
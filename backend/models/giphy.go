@@ -0,0 +1,112 @@
+package models
+
+import (
+    "github.com/adrian/gif-backend/utils" // Giphy response types
+)
+
+// SourceGiphy is the Source value for GIFs mapped from Giphy.
+const SourceGiphy = "giphy"
+
+// FromGiphy maps one Giphy GIF into our schema.
+func FromGiphy(g utils.Gif) GIF {
+    // 1) Copy every rendition Giphy provided, converting its numeric fields.
+    renditions := make(map[string]Rendition)
+    for name, img := range g.Images.ByName() {
+        renditions[name] = Rendition{
+            URL:    img.URL,
+            MP4:    img.MP4,
+            WebP:   img.WebP,
+            Width:  int(img.Width),
+            Height: int(img.Height),
+            Size:   int(img.Size),
+            Frames: int(img.Frames),
+        }
+    }
+
+    // 2) Build the attribution from the user profile, or the bare username.
+    var attribution *Attribution
+    if g.User != nil || g.Username != "" || g.Source != "" {
+        attribution = &Attribution{Username: g.Username, SourceURL: g.Source}
+        if g.User != nil {
+            attribution.Username = g.User.Username
+            attribution.DisplayName = g.User.DisplayName
+            attribution.ProfileURL = g.User.ProfileURL
+            attribution.AvatarURL = g.User.AvatarURL
+        }
+    }
+
+    // 3) Giphy tags stickers explicitly; anything else is a regular GIF.
+    itemType := TypeGIF
+    if g.Type == TypeSticker {
+        itemType = TypeSticker
+    }
+
+    return GIF{
+        ID:          g.ID,
+        Title:       g.Title,
+        Type:        itemType,
+        Source:      SourceGiphy,
+        Rating:      g.Rating,
+        PageURL:     g.URL,
+        Renditions:  renditions,
+        Attribution: attribution,
+    }
+}
+
+// FromGiphyResponse maps a page of Giphy results into a ListResponse.
+// limit is the page size that was requested, used to compute HasMore.
+func FromGiphyResponse(r utils.GiphyResponse, limit int) ListResponse {
+    // 1) Map every GIF; always return an array, never null.
+    data := make([]GIF, 0, len(r.Data))
+    for _, g := range r.Data {
+        data = append(data, FromGiphy(g))
+    }
+
+    // 2) There are more results if this page doesn't reach the total.
+    p := r.Pagination
+    return ListResponse{
+        Version: SchemaVersion,
+        Data:    data,
+        Pagination: Pagination{
+            Offset:     p.Offset,
+            Limit:      limit,
+            Count:      len(data),
+            TotalCount: p.TotalCount,
+            HasMore:    p.Offset+len(data) < p.TotalCount,
+        },
+    }
+}
+
+// FromGiphyTags maps Giphy's tag suggestions for query into a TermsResponse.
+func FromGiphyTags(query string, tags []utils.Tag) TermsResponse {
+    // Always return an array, never null.
+    terms := make([]string, 0, len(tags))
+    for _, t := range tags {
+        terms = append(terms, t.Name)
+    }
+    return TermsResponse{Version: SchemaVersion, Query: query, Data: terms}
+}
+
+// FromGiphyCategory maps one Giphy category, including any subcategories
+// and representative GIF it carries.
+func FromGiphyCategory(c utils.Category) Category {
+    out := Category{Name: c.Name, Slug: c.NameEncoded}
+    if c.Gif != nil {
+        g := FromGiphy(*c.Gif)
+        out.GIF = &g
+    }
+    for _, sub := range c.Subcategories {
+        out.Subcategories = append(out.Subcategories, FromGiphyCategory(sub))
+    }
+    return out
+}
+
+// FromGiphyCategories maps Giphy's category list into a CategoriesResponse.
+func FromGiphyCategories(cats []utils.Category) CategoriesResponse {
+    // Always return an array, never null.
+    data := make([]Category, 0, len(cats))
+    for _, c := range cats {
+        data = append(data, FromGiphyCategory(c))
+    }
+    return CategoriesResponse{Version: SchemaVersion, Data: data}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+    "testing" // Go’s testing framework
+
+    "github.com/adrian/gif-backend/utils" // Giphy response types
+)
+
+// TestFromGiphyResponse verifies that a Giphy page is mapped into our
+// versioned schema, with renditions, attribution and pagination filled in.
+func TestFromGiphyResponse(t *testing.T) {
+    // 1) A one-GIF Giphy page out of 30 results.
+    upstream := utils.GiphyResponse{
+        Data: []utils.Gif{{
+            ID:     "abc123",
+            Title:  "Test GIF",
+            Rating: "pg",
+            URL:    "https://giphy.com/gifs/abc123",
+            Images: utils.Images{
+                FixedHeight: utils.GifImage{URL: "https://example.com/fh.gif", Width: 356, Height: 200},
+                PreviewWebP: utils.GifImage{WebP: "https://example.com/p.webp"},
+            },
+            User: &utils.GiphyUser{Username: "someone", DisplayName: "Some One"},
+        }},
+        Pagination: utils.Pagination{TotalCount: 30, Count: 1, Offset: 10},
+    }
+
+    got := FromGiphyResponse(upstream, 1)
+
+    // 2) Envelope and GIF fields.
+    if got.Version != SchemaVersion || len(got.Data) != 1 {
+        t.Fatalf("unexpected envelope: %+v", got)
+    }
+    gif := got.Data[0]
+    if gif.ID != "abc123" || gif.Type != TypeGIF || gif.Source != SourceGiphy || gif.Rating != "pg" || gif.PageURL == "" {
+        t.Errorf("unexpected GIF fields: %+v", gif)
+    }
+
+    // 3) Only the renditions Giphy provided are present.
+    if r := gif.Renditions[RenditionDefault]; r.URL != "https://example.com/fh.gif" || r.Width != 356 {
+        t.Errorf("unexpected default rendition: %+v", r)
+    }
+    if _, ok := gif.Renditions[RenditionOriginal]; ok || len(gif.Renditions) != 2 {
+        t.Errorf("expected exactly 2 renditions; got %v", gif.Renditions)
+    }
+
+    // 4) Attribution and pagination.
+    if gif.Attribution == nil || gif.Attribution.DisplayName != "Some One" {
+        t.Errorf("unexpected attribution: %+v", gif.Attribution)
+    }
+    if p := got.Pagination; p.Offset != 10 || p.Count != 1 || !p.HasMore {
+        t.Errorf("unexpected pagination: %+v", p)
+    }
+}
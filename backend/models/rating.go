@@ -0,0 +1,53 @@
+package models
+
+import (
+    "strings" // case-insensitive matching
+)
+
+// Content ratings, from most to least restrictive.
+const (
+    RatingG    = "g"
+    RatingPG   = "pg"
+    RatingPG13 = "pg-13"
+    RatingR    = "r"
+)
+
+// Ratings lists every content rating our API accepts, in increasing order.
+var Ratings = []string{RatingG, RatingPG, RatingPG13, RatingR}
+
+// ParseRating validates a rating and returns its canonical (lower-case)
+// spelling.
+func ParseRating(s string) (string, bool) {
+    s = strings.ToLower(strings.TrimSpace(s))
+    for _, r := range Ratings {
+        if s == r {
+            return r, true
+        }
+    }
+    return "", false
+}
+
+// RatingAtMost reports whether rating is no higher than max. Giphy's "y"
+// (made for young audiences) counts as RatingG. Unrated (empty) and unknown
+// ratings are treated as the highest, so they are only allowed under RatingR.
+func RatingAtMost(rating, max string) bool {
+    return ratingRank(rating) <= ratingRank(max)
+}
+
+// ratingRank returns the position of rating in Ratings: "y" ranks with "g",
+// and empty or unknown values take the highest position.
+func ratingRank(rating string) int {
+    r, ok := ParseRating(rating)
+    switch {
+    case strings.EqualFold(strings.TrimSpace(rating), "y"):
+        return 0
+    case !ok:
+        return len(Ratings) - 1
+    }
+    for i, known := range Ratings {
+        if r == known {
+            return i
+        }
+    }
+    return len(Ratings) - 1
+}
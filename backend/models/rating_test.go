@@ -0,0 +1,24 @@
+package models
+
+import "testing" // Go’s testing framework
+
+// TestRatingAtMost verifies the rating order, including Giphy's "y" and
+// unrated GIFs.
+func TestRatingAtMost(t *testing.T) {
+    for _, tc := range []struct {
+        rating, max string
+        want        bool
+    }{
+        {"g", "g", true},
+        {"PG", "pg-13", true},
+        {"r", "pg-13", false},
+        {"y", "g", true},       // Giphy's young-audience rating ranks with g
+        {"", "pg-13", false},   // unrated: only under r
+        {"", "r", true},
+        {"nc-17", "pg", false}, // unknown: only under r
+    } {
+        if got := RatingAtMost(tc.rating, tc.max); got != tc.want {
+            t.Errorf("RatingAtMost(%q, %q) = %v; want %v", tc.rating, tc.max, got, tc.want)
+        }
+    }
+}
@@ -0,0 +1,13 @@
+package models
+
+// TermsResponse is the envelope for endpoints returning search-term
+// suggestions (autocomplete and related terms).
+type TermsResponse struct {
+    Version string `json:"version"`
+
+    // Query is the term the suggestions were made for.
+    Query string `json:"query"`
+
+    // Data lists the suggested terms, best first.
+    Data []string `json:"data"`
+}
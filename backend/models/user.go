@@ -0,0 +1,66 @@
+package models
+
+import "time" // account, token and history timestamps
+
+// User is an account that owns favorites, collections and search history.
+type User struct {
+    // ID is the user's opaque identifier.
+    ID string `json:"id"`
+
+    // Name is unique among users, ignoring case.
+    Name string `json:"name"`
+
+    // Guest marks an account created for an anonymous browser. It lives
+    // only as long as its sessions and can't hold API tokens.
+    Guest bool `json:"guest,omitempty"`
+
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// APIToken describes one of a user's API tokens. The secret itself is only
+// ever shown once, when the token is created.
+type APIToken struct {
+    ID    string `json:"id"`
+    Label string `json:"label,omitempty"`
+
+    CreatedAt time.Time `json:"created_at"`
+
+    // ExpiresAt is when the token stops working; zero means never.
+    ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// HistoryEntry is one search a user made.
+type HistoryEntry struct {
+    Query      string    `json:"query"`
+    SearchedAt time.Time `json:"searched_at"`
+}
+
+// UserResponse is the envelope for the signed-in user.
+type UserResponse struct {
+    Version string `json:"version"`
+    Data    User   `json:"data"`
+}
+
+// NewTokenResponse is the envelope for a newly created API token. Token is
+// the secret to send as "Authorization: Bearer <token>"; it can't be
+// retrieved again.
+type NewTokenResponse struct {
+    Version string   `json:"version"`
+    Data    APIToken `json:"data"`
+    Token   string   `json:"token"`
+
+    // User is set when the token was issued together with a new account.
+    User *User `json:"user,omitempty"`
+}
+
+// TokensResponse is the envelope for a user's API tokens.
+type TokensResponse struct {
+    Version string     `json:"version"`
+    Data    []APIToken `json:"data"`
+}
+
+// HistoryResponse is the envelope for a user's search history, newest first.
+type HistoryResponse struct {
+    Version string         `json:"version"`
+    Data    []HistoryEntry `json:"data"`
+}
@@ -0,0 +1,269 @@
+package storage
+
+import (
+    "context" // Store method signatures
+    "slices"  // copying, searching and inserting items
+    "strings" // case-insensitive name comparison
+    "time"    // collection and item timestamps
+
+    "github.com/adrian/gif-backend/models" // collections and GIF snapshots
+)
+
+// collectionData is a collection as a FileStore keeps it: the metadata
+// plus its GIFs in order. GIFCount is derived from Items when read.
+type collectionData struct {
+    models.Collection
+    Items []models.CollectionItem `json:"items"`
+}
+
+// summary returns the collection's metadata with an up-to-date GIFCount.
+func (c collectionData) summary() models.Collection {
+    col := c.Collection
+    col.GIFCount = len(c.Items)
+    return col
+}
+
+// Collections returns the metadata of every collection the user owns.
+func (s *FileStore) Collections(ctx context.Context, userID string) ([]models.Collection, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    u, err := s.user(userID)
+    if err != nil {
+        return nil, err
+    }
+    cols := make([]models.Collection, len(u.Collections))
+    for i, c := range u.Collections {
+        cols[i] = c.summary()
+    }
+    return cols, nil
+}
+
+// CreateCollection appends a new, empty collection.
+func (s *FileStore) CreateCollection(ctx context.Context, userID, name, description string) (models.Collection, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) Enforce the limit and unique names.
+    u, err := s.user(userID)
+    if err != nil {
+        return models.Collection{}, err
+    }
+    if len(u.Collections) >= MaxCollections {
+        return models.Collection{}, &LimitError{What: "collections", Max: MaxCollections}
+    }
+    if nameTaken(u.Collections, name, "") {
+        return models.Collection{}, ErrExists
+    }
+
+    // 2) Append it to a copy and persist.
+    now := time.Now().UTC()
+    c := collectionData{Collection: models.Collection{
+        ID:          newID(),
+        Name:        name,
+        Description: description,
+        CreatedAt:   now,
+        UpdatedAt:   now,
+    }}
+    next, nu := s.edit(userID)
+    nu.Collections = append(nu.Collections, c)
+    if err := s.save(next); err != nil {
+        return models.Collection{}, err
+    }
+    return c.summary(), nil
+}
+
+// Collection returns a collection and one page of its GIFs.
+func (s *FileStore) Collection(ctx context.Context, userID, id string, offset, limit int) (models.Collection, []models.CollectionItem, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    u, err := s.user(userID)
+    if err != nil {
+        return models.Collection{}, nil, err
+    }
+    i := collectionIndex(u.Collections, id)
+    if i < 0 {
+        return models.Collection{}, nil, ErrNotFound
+    }
+    c := u.Collections[i]
+    start := min(max(offset, 0), len(c.Items))
+    end := min(start+max(limit, 0), len(c.Items))
+    return c.summary(), slices.Clone(c.Items[start:end]), nil
+}
+
+// UpdateCollection applies update to a collection.
+func (s *FileStore) UpdateCollection(ctx context.Context, userID, id string, update CollectionUpdate) (models.Collection, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) Find it; a new name must still be unique.
+    u, err := s.user(userID)
+    if err != nil {
+        return models.Collection{}, err
+    }
+    i := collectionIndex(u.Collections, id)
+    if i < 0 {
+        return models.Collection{}, ErrNotFound
+    }
+    if update.Name != nil && nameTaken(u.Collections, *update.Name, id) {
+        return models.Collection{}, ErrExists
+    }
+
+    // 2) Change a copy and persist.
+    next, nu := s.edit(userID)
+    c := &nu.Collections[i]
+    if update.Name != nil {
+        c.Name = *update.Name
+    }
+    if update.Description != nil {
+        c.Description = *update.Description
+    }
+    c.UpdatedAt = time.Now().UTC()
+    if err := s.save(next); err != nil {
+        return models.Collection{}, err
+    }
+    return c.summary(), nil
+}
+
+// DeleteCollection removes a collection.
+func (s *FileStore) DeleteCollection(ctx context.Context, userID, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    u, err := s.user(userID)
+    if err != nil {
+        return err
+    }
+    i := collectionIndex(u.Collections, id)
+    if i < 0 {
+        return ErrNotFound
+    }
+    next, nu := s.edit(userID)
+    nu.Collections = slices.Delete(nu.Collections, i, i+1)
+    return s.save(next)
+}
+
+// AddToCollection inserts gif into a collection unless it is already there.
+func (s *FileStore) AddToCollection(ctx context.Context, userID, id string, gif models.GIF, position int) (models.CollectionItem, bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) Find the collection; adding a GIF it holds is idempotent.
+    u, err := s.user(userID)
+    if err != nil {
+        return models.CollectionItem{}, false, err
+    }
+    i := collectionIndex(u.Collections, id)
+    if i < 0 {
+        return models.CollectionItem{}, false, ErrNotFound
+    }
+    items := u.Collections[i].Items
+    if j := itemIndex(items, gif.ID); j >= 0 {
+        return items[j], false, nil
+    }
+    if len(items) >= MaxCollectionGIFs {
+        return models.CollectionItem{}, false, &LimitError{What: "GIFs per collection", Max: MaxCollectionGIFs}
+    }
+
+    // 2) Insert into a copy and persist.
+    item := models.CollectionItem{GIF: gif, AddedAt: time.Now().UTC()}
+    next, nu := s.edit(userID)
+    c := &nu.Collections[i]
+    c.Items = insertItem(c.Items, position, item)
+    c.UpdatedAt = item.AddedAt
+    if err := s.save(next); err != nil {
+        return models.CollectionItem{}, false, err
+    }
+    return item, true, nil
+}
+
+// RemoveFromCollection removes a GIF from a collection.
+func (s *FileStore) RemoveFromCollection(ctx context.Context, userID, id, gifID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    u, err := s.user(userID)
+    if err != nil {
+        return err
+    }
+    i := collectionIndex(u.Collections, id)
+    if i < 0 {
+        return ErrNotFound
+    }
+    j := itemIndex(u.Collections[i].Items, gifID)
+    if j < 0 {
+        return ErrNotFound
+    }
+    next, nu := s.edit(userID)
+    c := &nu.Collections[i]
+    c.Items = slices.Delete(c.Items, j, j+1)
+    c.UpdatedAt = time.Now().UTC()
+    return s.save(next)
+}
+
+// MoveInCollection moves a GIF within a collection or into another one.
+func (s *FileStore) MoveInCollection(ctx context.Context, userID, id, gifID, toID string, position int) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) Find the GIF and the target collection, both the user's.
+    u, err := s.user(userID)
+    if err != nil {
+        return err
+    }
+    from, to := collectionIndex(u.Collections, id), collectionIndex(u.Collections, toID)
+    if from < 0 || to < 0 {
+        return ErrNotFound
+    }
+    j := itemIndex(u.Collections[from].Items, gifID)
+    if j < 0 {
+        return ErrNotFound
+    }
+    if from != to {
+        target := u.Collections[to].Items
+        if itemIndex(target, gifID) >= 0 {
+            return ErrExists
+        }
+        if len(target) >= MaxCollectionGIFs {
+            return &LimitError{What: "GIFs per collection", Max: MaxCollectionGIFs}
+        }
+    }
+
+    // 2) Take it out of the source and insert it into the target, in a copy.
+    now := time.Now().UTC()
+    next, nu := s.edit(userID)
+    src, dst := &nu.Collections[from], &nu.Collections[to]
+    item := src.Items[j]
+    src.Items = slices.Delete(src.Items, j, j+1)
+    dst.Items = insertItem(dst.Items, position, item)
+    src.UpdatedAt, dst.UpdatedAt = now, now
+    return s.save(next)
+}
+
+// collectionIndex returns the position of collection id in cols, or -1.
+func collectionIndex(cols []collectionData, id string) int {
+    return slices.IndexFunc(cols, func(c collectionData) bool { return c.ID == id })
+}
+
+// nameTaken reports whether a collection in cols other than exceptID is
+// called name, ignoring case.
+func nameTaken(cols []collectionData, name, exceptID string) bool {
+    return slices.ContainsFunc(cols, func(c collectionData) bool {
+        return c.ID != exceptID && strings.EqualFold(c.Name, name)
+    })
+}
+
+// itemIndex returns the position of GIF gifID in items, or -1.
+func itemIndex(items []models.CollectionItem, gifID string) int {
+    return slices.IndexFunc(items, func(it models.CollectionItem) bool { return it.GIF.ID == gifID })
+}
+
+// insertItem inserts item at position, or at the end when position is
+// negative or past it.
+func insertItem(items []models.CollectionItem, position int, item models.CollectionItem) []models.CollectionItem {
+    if position < 0 || position > len(items) {
+        position = len(items)
+    }
+    return slices.Insert(items, position, item)
+}
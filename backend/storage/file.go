@@ -0,0 +1,584 @@
+package storage
+
+import (
+    "context"       // Store method signatures
+    "crypto/rand"   // user, token and collection IDs
+    "encoding/hex"  // user, token and collection IDs
+    "encoding/json" // the on-disk format
+    "errors"        // distinguishing a missing file
+    "fmt"           // wrapping errors with the file path
+    "io/fs"         // fs.ErrNotExist
+    "os"            // reading, writing and renaming files
+    "path/filepath" // the directory for temporary files
+    "slices"        // copying and searching users' data
+    "strings"       // case-insensitive name comparison
+    "sync"          // guards the in-memory state
+    "time"          // timestamps and token expiry
+
+    "github.com/adrian/gif-backend/models" // the users and GIF snapshots we store
+)
+
+// fileVersion is the format version written to the data file. Version 1
+// had a single, global set of favorites and collections.
+const fileVersion = 2
+
+// fileData is the JSON document a FileStore keeps on disk.
+type fileData struct {
+    Version int        `json:"version"`
+    Users   []userData `json:"users"`
+    Tokens  []Token    `json:"tokens"`
+
+    // Favorites and Collections hold a version 1 file's data, which
+    // belonged to nobody, until AdoptLegacy gives them to a user.
+    Favorites   []models.Favorite `json:"favorites,omitempty"`
+    Collections []collectionData  `json:"collections,omitempty"`
+}
+
+// userData is an account and everything it owns, apart from its search
+// history, which lives in the history file.
+type userData struct {
+    models.User
+    Favorites   []models.Favorite `json:"favorites"`
+    Collections []collectionData  `json:"collections"`
+
+    // History is only read, from files written before the history had a
+    // file of its own; it moves there on open.
+    History []models.HistoryEntry `json:"history,omitempty"`
+}
+
+// FileStore is a Store that keeps everything in memory and persists it to
+// a JSON file. Every change rewrites the file through a temporary file and
+// a rename, so a crash never leaves it half-written. Search history is
+// kept in a second file, next to the first, that is written in the
+// background a few seconds after a search; Close writes what is pending.
+// It suits a single backend instance; replicas would each have their own files.
+type FileStore struct {
+    path    string      // the JSON data file
+    history *historyLog // search history, written on its own schedule
+
+    mu      sync.Mutex
+    data    fileData       // current state; replaced, never mutated in place, on change
+    users   map[string]int // user ID -> index in data.Users
+    byToken map[string]int // token hash -> index in data.Tokens
+}
+
+// Compile-time check that FileStore satisfies Store.
+var _ Store = (*FileStore)(nil)
+
+// OpenFileStore loads the data file at path, and the history file next to
+// it, creating their directory if needed. A missing file is an empty
+// store; it is written on the first change. Call Close when done.
+func OpenFileStore(path string) (*FileStore, error) {
+    // 1) Make sure the directory exists, so the first save can't fail on it.
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return nil, fmt.Errorf("storage: create directory for %s: %w", path, err)
+    }
+
+    // 2) Load the existing files, if any.
+    history, err := openHistoryLog(strings.TrimSuffix(path, filepath.Ext(path)) + ".history.json")
+    if err != nil {
+        return nil, err
+    }
+    s := &FileStore{path: path, history: history, data: fileData{Version: fileVersion}}
+    raw, err := os.ReadFile(path)
+    switch {
+    case errors.Is(err, fs.ErrNotExist):
+        s.index()
+        return s, nil
+    case err != nil:
+        return nil, fmt.Errorf("storage: read %s: %w", path, err)
+    }
+    if err := json.Unmarshal(raw, &s.data); err != nil {
+        return nil, fmt.Errorf("storage: decode %s: %w", path, err)
+    }
+    if s.data.Version > fileVersion {
+        return nil, fmt.Errorf("storage: %s has version %d, newer than supported %d", path, s.data.Version, fileVersion)
+    }
+
+    // 3) Older versions load as-is: their data waits in the legacy fields,
+    //    and history kept with the users moves to the history file.
+    //    Guests whose sessions ran out while we were down are dropped; the
+    //    next save makes that permanent.
+    s.data.Version = fileVersion
+    for i := range s.data.Users {
+        s.history.set(s.data.Users[i].ID, s.data.Users[i].History)
+        s.data.Users[i].History = nil
+    }
+    var gone []string
+    s.data, gone = pruneGuests(s.data, time.Now())
+    s.history.drop(gone...)
+    s.index()
+    return s, nil
+}
+
+// Close writes any search history still waiting to be written. The store
+// keeps working, but later searches are only written by another Close.
+func (s *FileStore) Close() error {
+    return s.history.close()
+}
+
+// CreateUser adds a registered account.
+func (s *FileStore) CreateUser(ctx context.Context, name string) (models.User, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) Enforce the limit and unique names.
+    if countUsers(s.data.Users, false) >= MaxUsers {
+        return models.User{}, &LimitError{What: "users", Max: MaxUsers}
+    }
+    if slices.ContainsFunc(s.data.Users, func(u userData) bool { return strings.EqualFold(u.Name, name) }) {
+        return models.User{}, ErrExists
+    }
+
+    // 2) Append the user to a copy and persist.
+    u := userData{User: models.User{ID: newID(), Name: name, CreatedAt: time.Now().UTC()}}
+    next := s.data
+    next.Users = append(slices.Clone(s.data.Users), u)
+    if err := s.save(next); err != nil {
+        return models.User{}, err
+    }
+    return u.User, nil
+}
+
+// CreateGuest adds a guest account and its session, first deleting the
+// guests that have no session left.
+func (s *FileStore) CreateGuest(ctx context.Context, name string, session Token) (models.User, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) Make room, then enforce the limit and unique names.
+    next, gone := pruneGuests(s.data, time.Now())
+    if countUsers(next.Users, true) >= MaxGuests {
+        return models.User{}, &LimitError{What: "guests", Max: MaxGuests}
+    }
+    if slices.ContainsFunc(next.Users, func(u userData) bool { return strings.EqualFold(u.Name, name) }) {
+        return models.User{}, ErrExists
+    }
+
+    // 2) Add the guest with its session and persist.
+    u := userData{User: models.User{ID: newID(), Name: name, Guest: true, CreatedAt: time.Now().UTC()}}
+    session.UserID = u.ID
+    next.Users = append(next.Users, u)
+    next.Tokens = append(next.Tokens, session)
+    if err := s.save(next); err != nil {
+        return models.User{}, err
+    }
+    s.history.drop(gone...)
+    return u.User, nil
+}
+
+// HasLegacyData reports whether a version 1 file's favorites or collections
+// are still waiting for AdoptLegacy.
+func (s *FileStore) HasLegacyData() bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return len(s.data.Favorites) > 0 || len(s.data.Collections) > 0
+}
+
+// AdoptLegacy gives a version 1 file's favorites and collections to the
+// registered user called name, adding them after the user's own; favorites the user
+// already has are skipped. It reports whether there was anything to adopt.
+// A missing user is ErrNotFound, a collection name the user already uses
+// ErrExists, and nothing is adopted unless everything fits the limits.
+func (s *FileStore) AdoptLegacy(ctx context.Context, name string) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) Find the owner; with nothing to adopt there is nothing to do.
+    i := slices.IndexFunc(s.data.Users, func(u userData) bool { return !u.Guest && strings.EqualFold(u.Name, name) })
+    if i < 0 {
+        return false, ErrNotFound
+    }
+    if len(s.data.Favorites) == 0 && len(s.data.Collections) == 0 {
+        return false, nil
+    }
+
+    // 2) Merge into a copy of the owner's data, within the limits.
+    next, nu := s.edit(s.data.Users[i].ID)
+    for _, f := range s.data.Favorites {
+        if favoriteIndex(nu.Favorites, f.GIF.ID) < 0 {
+            nu.Favorites = append(nu.Favorites, f)
+        }
+    }
+    if len(nu.Favorites) > MaxFavorites {
+        return false, &LimitError{What: "favorites", Max: MaxFavorites}
+    }
+    for _, c := range s.data.Collections {
+        if slices.ContainsFunc(nu.Collections, func(o collectionData) bool { return strings.EqualFold(o.Name, c.Name) }) {
+            return false, ErrExists
+        }
+        nu.Collections = append(nu.Collections, c)
+    }
+    if len(nu.Collections) > MaxCollections {
+        return false, &LimitError{What: "collections", Max: MaxCollections}
+    }
+
+    // 3) The data now has an owner; persist.
+    next.Favorites, next.Collections = nil, nil
+    if err := s.save(next); err != nil {
+        return false, err
+    }
+    return true, nil
+}
+
+// AddToken stores token, first dropping the expired ones. A new session
+// beyond MaxSessions replaces the user's oldest.
+func (s *FileStore) AddToken(ctx context.Context, token Token) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, ok := s.users[token.UserID]; !ok {
+        return ErrNotFound
+    }
+
+    // 1) Keep every token that is still valid, counting the user's tokens
+    //    of the new one's kind. Tokens are kept oldest first.
+    now := time.Now()
+    tokens := make([]Token, 0, len(s.data.Tokens)+1)
+    oldest, owned := -1, 0
+    for _, t := range s.data.Tokens {
+        if expired(t, now) {
+            continue
+        }
+        if t.UserID == token.UserID && t.Session == token.Session {
+            if oldest < 0 {
+                oldest = len(tokens)
+            }
+            owned++
+        }
+        tokens = append(tokens, t)
+    }
+
+    // 2) API tokens stop at the limit; sessions make room.
+    switch {
+    case !token.Session && owned >= MaxTokens:
+        return &LimitError{What: "tokens", Max: MaxTokens}
+    case token.Session && owned >= MaxSessions:
+        tokens = slices.Delete(tokens, oldest, oldest+1)
+    }
+
+    // 3) Add the new one and persist.
+    next := s.data
+    next.Tokens = append(tokens, token)
+    return s.save(next)
+}
+
+// RenewToken sets a new expiry on one of the user's tokens.
+func (s *FileStore) RenewToken(ctx context.Context, userID, tokenID string, expiresAt time.Time) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    i := slices.IndexFunc(s.data.Tokens, func(t Token) bool { return t.ID == tokenID && t.UserID == userID })
+    if i < 0 {
+        return ErrNotFound
+    }
+    next := s.data
+    next.Tokens = slices.Clone(s.data.Tokens)
+    next.Tokens[i].ExpiresAt = expiresAt
+    return s.save(next)
+}
+
+// UserByToken looks a token up by its hash.
+func (s *FileStore) UserByToken(ctx context.Context, hash string) (models.User, Token, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    i, ok := s.byToken[hash]
+    if !ok || expired(s.data.Tokens[i], time.Now()) {
+        return models.User{}, Token{}, ErrNotFound
+    }
+    t := s.data.Tokens[i]
+    u, ok := s.users[t.UserID]
+    if !ok {
+        return models.User{}, Token{}, ErrNotFound
+    }
+    return s.data.Users[u].User, t, nil
+}
+
+// Tokens returns the user's unexpired API tokens.
+func (s *FileStore) Tokens(ctx context.Context, userID string) ([]Token, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    now := time.Now()
+    var tokens []Token
+    for _, t := range s.data.Tokens {
+        if t.UserID == userID && !t.Session && !expired(t, now) {
+            tokens = append(tokens, t)
+        }
+    }
+    return tokens, nil
+}
+
+// RevokeToken deletes one of the user's tokens.
+func (s *FileStore) RevokeToken(ctx context.Context, userID, tokenID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    i := slices.IndexFunc(s.data.Tokens, func(t Token) bool { return t.ID == tokenID && t.UserID == userID })
+    if i < 0 {
+        return ErrNotFound
+    }
+    next := s.data
+    next.Tokens = slices.Delete(slices.Clone(s.data.Tokens), i, i+1)
+    return s.save(next)
+}
+
+// Favorites returns a copy of the user's favorites in order.
+func (s *FileStore) Favorites(ctx context.Context, userID string) ([]models.Favorite, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    u, err := s.user(userID)
+    if err != nil {
+        return nil, err
+    }
+    return slices.Clone(u.Favorites), nil
+}
+
+// AddFavorite appends gif unless it is already a favorite.
+func (s *FileStore) AddFavorite(ctx context.Context, userID string, gif models.GIF) (models.Favorite, bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) Already there: adding is idempotent.
+    u, err := s.user(userID)
+    if err != nil {
+        return models.Favorite{}, false, err
+    }
+    if i := favoriteIndex(u.Favorites, gif.ID); i >= 0 {
+        return u.Favorites[i], false, nil
+    }
+    if len(u.Favorites) >= MaxFavorites {
+        return models.Favorite{}, false, &LimitError{What: "favorites", Max: MaxFavorites}
+    }
+
+    // 2) Append to a copy and persist it before making it current.
+    fav := models.Favorite{GIF: gif, AddedAt: time.Now().UTC()}
+    next, nu := s.edit(userID)
+    nu.Favorites = append(nu.Favorites, fav)
+    if err := s.save(next); err != nil {
+        return models.Favorite{}, false, err
+    }
+    return fav, true, nil
+}
+
+// RemoveFavorite removes the favorite for GIF id.
+func (s *FileStore) RemoveFavorite(ctx context.Context, userID, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    u, err := s.user(userID)
+    if err != nil {
+        return err
+    }
+    i := favoriteIndex(u.Favorites, id)
+    if i < 0 {
+        return ErrNotFound
+    }
+    next, nu := s.edit(userID)
+    nu.Favorites = slices.Delete(nu.Favorites, i, i+1)
+    return s.save(next)
+}
+
+// ReorderFavorites puts the favorites in the order of ids.
+func (s *FileStore) ReorderFavorites(ctx context.Context, userID string, ids []string) ([]models.Favorite, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    // 1) ids must be a permutation of the current favorites.
+    u, err := s.user(userID)
+    if err != nil {
+        return nil, err
+    }
+    if len(ids) != len(u.Favorites) {
+        return nil, ErrOrderMismatch
+    }
+    reordered := make([]models.Favorite, 0, len(ids))
+    used := make([]bool, len(u.Favorites))
+    for _, id := range ids {
+        i := favoriteIndex(u.Favorites, id)
+        if i < 0 || used[i] {
+            return nil, ErrOrderMismatch
+        }
+        used[i] = true
+        reordered = append(reordered, u.Favorites[i])
+    }
+
+    // 2) Persist the new order.
+    next, nu := s.edit(userID)
+    nu.Favorites = reordered
+    if err := s.save(next); err != nil {
+        return nil, err
+    }
+    return slices.Clone(reordered), nil
+}
+
+// AddSearch puts query at the top of the user's history. Repeating a
+// search, in any case, moves it up rather than adding a duplicate. Only
+// memory changes here; the history file is written in the background.
+func (s *FileStore) AddSearch(ctx context.Context, userID, query string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, err := s.user(userID); err != nil {
+        return err
+    }
+    s.history.add(userID, query)
+    return nil
+}
+
+// History returns a copy of the user's recent searches.
+func (s *FileStore) History(ctx context.Context, userID string) ([]models.HistoryEntry, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, err := s.user(userID); err != nil {
+        return nil, err
+    }
+    return s.history.get(userID), nil
+}
+
+// ClearHistory empties the user's history.
+func (s *FileStore) ClearHistory(ctx context.Context, userID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, err := s.user(userID); err != nil {
+        return err
+    }
+    s.history.drop(userID)
+    return nil
+}
+
+// user returns the current data of userID, or ErrNotFound. The result must
+// not be modified. The caller must hold s.mu.
+func (s *FileStore) user(userID string) (*userData, error) {
+    i, ok := s.users[userID]
+    if !ok {
+        return nil, ErrNotFound
+    }
+    return &s.data.Users[i], nil
+}
+
+// edit returns a copy of the state in which userID's data, which must
+// exist, can be changed through the returned pointer without affecting the
+// current state. The caller must hold s.mu.
+func (s *FileStore) edit(userID string) (fileData, *userData) {
+    next := s.data
+    next.Users = slices.Clone(s.data.Users)
+    u := &next.Users[s.users[userID]]
+    u.Favorites = slices.Clone(u.Favorites)
+    u.Collections = slices.Clone(u.Collections)
+    for i := range u.Collections {
+        u.Collections[i].Items = slices.Clone(u.Collections[i].Items)
+    }
+    return next, u
+}
+
+// index rebuilds the lookup maps from s.data. The caller must hold s.mu.
+func (s *FileStore) index() {
+    s.users = make(map[string]int, len(s.data.Users))
+    for i, u := range s.data.Users {
+        s.users[u.ID] = i
+    }
+    s.byToken = make(map[string]int, len(s.data.Tokens))
+    for i, t := range s.data.Tokens {
+        s.byToken[t.Hash] = i
+    }
+}
+
+// save writes data to the file and, once that succeeded, makes it the
+// current state. The caller must hold s.mu.
+func (s *FileStore) save(data fileData) error {
+    raw, err := json.MarshalIndent(data, "", "  ")
+    if err != nil {
+        return fmt.Errorf("storage: encode: %w", err)
+    }
+    if err := writeFile(s.path, raw); err != nil {
+        return err
+    }
+    s.data = data
+    s.index()
+    return nil
+}
+
+// writeFile replaces the file at path with raw, through a synced temporary
+// file and a rename, so the file is never seen half-written.
+func writeFile(path string, raw []byte) error {
+    // 1) Write to a temporary file next to the real one.
+    tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+    if err != nil {
+        return fmt.Errorf("storage: create temporary file: %w", err)
+    }
+    defer os.Remove(tmp.Name()) // no-op once renamed
+    if _, err := tmp.Write(raw); err != nil {
+        tmp.Close()
+        return fmt.Errorf("storage: write %s: %w", tmp.Name(), err)
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return fmt.Errorf("storage: sync %s: %w", tmp.Name(), err)
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("storage: close %s: %w", tmp.Name(), err)
+    }
+
+    // 2) Atomically replace the file.
+    if err := os.Rename(tmp.Name(), path); err != nil {
+        return fmt.Errorf("storage: replace %s: %w", path, err)
+    }
+    return nil
+}
+
+// pruneGuests returns data without the guests that have no unexpired
+// token left, and without those guests' tokens, and the IDs of the guests
+// it dropped. Its Users and Tokens are fresh copies, safe to append to.
+func pruneGuests(data fileData, now time.Time) (fileData, []string) {
+    live := make(map[string]bool)
+    for _, t := range data.Tokens {
+        if !expired(t, now) {
+            live[t.UserID] = true
+        }
+    }
+    gone := make(map[string]bool)
+    var ids []string
+    data.Users = slices.DeleteFunc(slices.Clone(data.Users), func(u userData) bool {
+        if u.Guest && !live[u.ID] {
+            gone[u.ID] = true
+            ids = append(ids, u.ID)
+        }
+        return gone[u.ID]
+    })
+    data.Tokens = slices.DeleteFunc(slices.Clone(data.Tokens), func(t Token) bool { return gone[t.UserID] })
+    return data, ids
+}
+
+// countUsers returns how many of users are guests, or registered accounts.
+func countUsers(users []userData, guests bool) int {
+    n := 0
+    for _, u := range users {
+        if u.Guest == guests {
+            n++
+        }
+    }
+    return n
+}
+
+// favoriteIndex returns the position of GIF id in favs, or -1.
+func favoriteIndex(favs []models.Favorite, id string) int {
+    return slices.IndexFunc(favs, func(f models.Favorite) bool { return f.GIF.ID == id })
+}
+
+// expired reports whether t has stopped working at now.
+func expired(t Token, now time.Time) bool {
+    return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// newID returns a random 16-character hex ID.
+func newID() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
@@ -0,0 +1,411 @@
+package storage
+
+import (
+    "context"       // Store methods take a context
+    "errors"        // errors.Is on sentinel errors
+    "fmt"           // token IDs
+    "os"            // writing a version 1 file
+    "path/filepath" // data file inside the test's temp dir
+    "strings"       // comparing ID lists
+    "testing"       // the Go testing framework
+    "time"          // token expiry
+
+    "github.com/adrian/gif-backend/models" // GIF snapshots
+)
+
+// favoriteIDs returns the GIF IDs of favs in order.
+func favoriteIDs(favs []models.Favorite) []string {
+    ids := make([]string, len(favs))
+    for i, f := range favs {
+        ids[i] = f.GIF.ID
+    }
+    return ids
+}
+
+// TestFileStore_Favorites verifies adding (idempotently), reordering and
+// removing favorites, and that every change survives reopening the file.
+func TestFileStore_Favorites(t *testing.T) {
+    ctx := context.Background()
+    path := filepath.Join(t.TempDir(), "data", "store.json")
+    store, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    user, err := store.CreateUser(ctx, "alice")
+    if err != nil {
+        t.Fatalf("create user error: %v", err)
+    }
+
+    // 1) Add three GIFs; adding one again returns the existing entry.
+    for _, id := range []string{"a", "b", "c"} {
+        if _, created, err := store.AddFavorite(ctx, user.ID, models.GIF{ID: id, Title: "gif " + id}); err != nil || !created {
+            t.Fatalf("add %s: created=%v err=%v", id, created, err)
+        }
+    }
+    if _, created, _ := store.AddFavorite(ctx, user.ID, models.GIF{ID: "a"}); created {
+        t.Error("expected adding an existing favorite to be a no-op")
+    }
+
+    // 2) Reorders must list every favorite exactly once.
+    for _, ids := range [][]string{{"a", "b"}, {"a", "a", "b"}, {"a", "b", "x"}} {
+        if _, err := store.ReorderFavorites(ctx, user.ID, ids); !errors.Is(err, ErrOrderMismatch) {
+            t.Errorf("reorder %v: expected ErrOrderMismatch; got %v", ids, err)
+        }
+    }
+    if _, err := store.ReorderFavorites(ctx, user.ID, []string{"c", "a", "b"}); err != nil {
+        t.Fatalf("reorder error: %v", err)
+    }
+
+    // 3) Remove one, and a missing one is ErrNotFound.
+    if err := store.RemoveFavorite(ctx, user.ID, "a"); err != nil {
+        t.Fatalf("remove error: %v", err)
+    }
+    if err := store.RemoveFavorite(ctx, user.ID, "a"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected ErrNotFound; got %v", err)
+    }
+
+    // 4) A fresh store on the same file sees the same favorites and snapshots.
+    reopened, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("reopen error: %v", err)
+    }
+    favs, _ := reopened.Favorites(ctx, user.ID)
+    if got := favoriteIDs(favs); len(got) != 2 || got[0] != "c" || got[1] != "b" {
+        t.Errorf("expected [c b] after reopening; got %v", got)
+    }
+    if favs[0].GIF.Title != "gif c" || favs[0].AddedAt.IsZero() {
+        t.Errorf("expected the snapshot and timestamp to persist; got %+v", favs[0])
+    }
+}
+
+// itemIDs returns the GIF IDs of items in order.
+func itemIDs(items []models.CollectionItem) []string {
+    ids := make([]string, len(items))
+    for i, it := range items {
+        ids[i] = it.GIF.ID
+    }
+    return ids
+}
+
+// TestFileStore_Collections verifies creating, renaming and deleting
+// collections, and adding, paging and moving their GIFs.
+func TestFileStore_Collections(t *testing.T) {
+    ctx := context.Background()
+    path := filepath.Join(t.TempDir(), "store.json")
+    store, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    user, err := store.CreateUser(ctx, "alice")
+    if err != nil {
+        t.Fatalf("create user error: %v", err)
+    }
+
+    // 1) Names are unique, ignoring case.
+    reactions, err := store.CreateCollection(ctx, user.ID, "Reactions", "for chat")
+    if err != nil {
+        t.Fatalf("create error: %v", err)
+    }
+    deploys, _ := store.CreateCollection(ctx, user.ID, "Deploy fails", "")
+    if _, err := store.CreateCollection(ctx, user.ID, "reactions", ""); !errors.Is(err, ErrExists) {
+        t.Errorf("expected ErrExists for a duplicate name; got %v", err)
+    }
+    name := "DEPLOY FAILS"
+    if _, err := store.UpdateCollection(ctx, user.ID, reactions.ID, CollectionUpdate{Name: &name}); !errors.Is(err, ErrExists) {
+        t.Errorf("expected ErrExists when renaming onto another name; got %v", err)
+    }
+
+    // 2) Positions: append, insert at the front, then move within and across.
+    store.AddToCollection(ctx, user.ID, reactions.ID, models.GIF{ID: "a"}, -1)
+    store.AddToCollection(ctx, user.ID, reactions.ID, models.GIF{ID: "b"}, -1)
+    store.AddToCollection(ctx, user.ID, reactions.ID, models.GIF{ID: "c"}, 0)
+    if err := store.MoveInCollection(ctx, user.ID, reactions.ID, "b", reactions.ID, 0); err != nil {
+        t.Fatalf("move error: %v", err)
+    }
+    if err := store.MoveInCollection(ctx, user.ID, reactions.ID, "a", deploys.ID, -1); err != nil {
+        t.Fatalf("move across error: %v", err)
+    }
+    if _, items, _ := store.Collection(ctx, user.ID, reactions.ID, 0, 10); strings.Join(itemIDs(items), ",") != "b,c" {
+        t.Errorf("expected [b c]; got %v", itemIDs(items))
+    }
+
+    // 3) Pages are windows on the order; past the end is empty, not an error.
+    col, page, err := store.Collection(ctx, user.ID, reactions.ID, 1, 1)
+    if err != nil || col.GIFCount != 2 || strings.Join(itemIDs(page), ",") != "c" {
+        t.Errorf("expected page [c] of 2; got %v of %d (err %v)", itemIDs(page), col.GIFCount, err)
+    }
+    if _, page, _ := store.Collection(ctx, user.ID, reactions.ID, 5, 10); len(page) != 0 {
+        t.Errorf("expected an empty page; got %v", itemIDs(page))
+    }
+
+    // 4) Deleting survives reopening, as do the other collections.
+    if err := store.DeleteCollection(ctx, user.ID, reactions.ID); err != nil {
+        t.Fatalf("delete error: %v", err)
+    }
+    reopened, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("reopen error: %v", err)
+    }
+    cols, _ := reopened.Collections(ctx, user.ID)
+    if len(cols) != 1 || cols[0].ID != deploys.ID || cols[0].GIFCount != 1 {
+        t.Errorf("expected only the deploy collection with one GIF; got %+v", cols)
+    }
+    if _, _, err := reopened.Collection(ctx, user.ID, reactions.ID, 0, 10); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected ErrNotFound for the deleted collection; got %v", err)
+    }
+}
+
+// TestFileStore_Users verifies unique user names, token lookup and expiry,
+// that every user only sees their own data, and the search history.
+func TestFileStore_Users(t *testing.T) {
+    ctx := context.Background()
+    store, err := OpenFileStore(filepath.Join(t.TempDir(), "store.json"))
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    t.Cleanup(func() { store.Close() })
+
+    // 1) Names are unique, ignoring case.
+    alice, err := store.CreateUser(ctx, "alice")
+    if err != nil {
+        t.Fatalf("create error: %v", err)
+    }
+    bob, _ := store.CreateUser(ctx, "bob")
+    if _, err := store.CreateUser(ctx, "ALICE"); !errors.Is(err, ErrExists) {
+        t.Errorf("expected ErrExists for a duplicate name; got %v", err)
+    }
+
+    // 2) Tokens resolve to their user until they expire or are revoked.
+    now := time.Now()
+    store.AddToken(ctx, Token{ID: "t1", UserID: alice.ID, Hash: "h1", CreatedAt: now})
+    store.AddToken(ctx, Token{ID: "t2", UserID: alice.ID, Hash: "h2", CreatedAt: now, ExpiresAt: now.Add(-time.Second)})
+    store.AddToken(ctx, Token{ID: "t3", UserID: alice.ID, Hash: "h3", Session: true, CreatedAt: now})
+    if u, tok, err := store.UserByToken(ctx, "h1"); err != nil || u.ID != alice.ID || tok.ID != "t1" {
+        t.Errorf("expected alice's token t1; got %+v %+v %v", u, tok, err)
+    }
+    if _, _, err := store.UserByToken(ctx, "h2"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected ErrNotFound for an expired token; got %v", err)
+    }
+    if tokens, _ := store.Tokens(ctx, alice.ID); len(tokens) != 1 || tokens[0].ID != "t1" {
+        t.Errorf("expected only t1 listed, without expired tokens and sessions; got %+v", tokens)
+    }
+    if err := store.RevokeToken(ctx, bob.ID, "t1"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected ErrNotFound revoking another user's token; got %v", err)
+    }
+    if err := store.RevokeToken(ctx, alice.ID, "t1"); err != nil {
+        t.Fatalf("revoke error: %v", err)
+    }
+    if _, _, err := store.UserByToken(ctx, "h1"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected ErrNotFound for a revoked token; got %v", err)
+    }
+
+    // 3) Data is per user.
+    store.AddFavorite(ctx, alice.ID, models.GIF{ID: "a"})
+    if favs, _ := store.Favorites(ctx, bob.ID); len(favs) != 0 {
+        t.Errorf("expected bob to have no favorites; got %v", favoriteIDs(favs))
+    }
+    if _, err := store.Favorites(ctx, "nobody"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected ErrNotFound for an unknown user; got %v", err)
+    }
+
+    // 4) Repeated searches move to the top, and the history is capped.
+    for _, q := range []string{"cats", "dogs", "CATS"} {
+        store.AddSearch(ctx, alice.ID, q)
+    }
+    history, _ := store.History(ctx, alice.ID)
+    if len(history) != 2 || history[0].Query != "CATS" || history[1].Query != "dogs" {
+        t.Errorf("expected [CATS dogs]; got %+v", history)
+    }
+    for i := range MaxHistory + 5 {
+        store.AddSearch(ctx, alice.ID, strings.Repeat("q", i+1))
+    }
+    if history, _ := store.History(ctx, alice.ID); len(history) != MaxHistory {
+        t.Errorf("expected %d entries; got %d", MaxHistory, len(history))
+    }
+    store.ClearHistory(ctx, alice.ID)
+    if history, _ := store.History(ctx, alice.ID); len(history) != 0 {
+        t.Errorf("expected an empty history; got %+v", history)
+    }
+}
+
+// TestFileStore_HistoryFile verifies that search history stays out of the
+// data file, reaches its own file on Close, and that history kept in the
+// data file by an older version carries over.
+func TestFileStore_HistoryFile(t *testing.T) {
+    ctx := context.Background()
+    dir := t.TempDir()
+    path := filepath.Join(dir, "store.json")
+    v2 := `{"version":2,"users":[{"id":"u1","name":"alice","created_at":"2024-01-01T00:00:00Z",` +
+        `"favorites":[],"collections":[],"history":[{"query":"old","searched_at":"2024-01-01T00:00:00Z"}]}],"tokens":[]}`
+    if err := os.WriteFile(path, []byte(v2), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    store, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+
+    // 1) Searches change only memory; repeating the newest changes nothing.
+    store.AddSearch(ctx, "u1", "cats")
+    first, _ := store.History(ctx, "u1")
+    store.AddSearch(ctx, "u1", "Cats")
+    if history, _ := store.History(ctx, "u1"); len(history) != 2 || history[0] != first[0] || history[1].Query != "old" {
+        t.Errorf("expected [cats old] unchanged; got %+v", history)
+    }
+    store.AddFavorite(ctx, "u1", models.GIF{ID: "a"}) // rewrites the data file
+    if raw, _ := os.ReadFile(path); strings.Contains(string(raw), "history") {
+        t.Errorf("expected no history in the data file; got %s", raw)
+    }
+
+    // 2) Close writes the history file, which a reopened store reads.
+    if err := store.Close(); err != nil {
+        t.Fatalf("close error: %v", err)
+    }
+    reopened, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("reopen error: %v", err)
+    }
+    defer reopened.Close()
+    if history, _ := reopened.History(ctx, "u1"); len(history) != 2 || history[0].Query != "cats" {
+        t.Errorf("expected [cats old] after reopening; got %+v", history)
+    }
+    if _, err := os.Stat(filepath.Join(dir, "store.history.json")); err != nil {
+        t.Errorf("expected the history file: %v", err)
+    }
+}
+
+// TestFileStore_Sessions verifies that sessions don't count toward
+// MaxTokens, that the oldest makes way past MaxSessions, and renewal.
+func TestFileStore_Sessions(t *testing.T) {
+    ctx := context.Background()
+    store, err := OpenFileStore(filepath.Join(t.TempDir(), "store.json"))
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+    alice, _ := store.CreateUser(ctx, "alice")
+
+    // 1) One session more than allowed ends the first.
+    now := time.Now()
+    for i := range MaxSessions + 1 {
+        id := fmt.Sprintf("s%d", i)
+        if err := store.AddToken(ctx, Token{ID: id, UserID: alice.ID, Hash: "h" + id, Session: true, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+            t.Fatalf("add session %d: %v", i, err)
+        }
+    }
+    if _, _, err := store.UserByToken(ctx, "hs0"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected the oldest session to be gone; got %v", err)
+    }
+    if _, _, err := store.UserByToken(ctx, "hs1"); err != nil {
+        t.Errorf("expected the second session to survive; got %v", err)
+    }
+
+    // 2) API tokens still have their whole budget.
+    for i := range MaxTokens {
+        id := fmt.Sprintf("t%d", i)
+        if err := store.AddToken(ctx, Token{ID: id, UserID: alice.ID, Hash: "h" + id, CreatedAt: now}); err != nil {
+            t.Fatalf("add token %d: %v", i, err)
+        }
+    }
+    if err := store.AddToken(ctx, Token{ID: "tx", UserID: alice.ID, Hash: "htx", CreatedAt: now}); !errors.Is(err, ErrFull) {
+        t.Errorf("expected ErrFull past MaxTokens; got %v", err)
+    }
+
+    // 3) Renewing moves the expiry.
+    later := now.Add(48 * time.Hour).UTC()
+    if err := store.RenewToken(ctx, alice.ID, "s1", later); err != nil {
+        t.Fatalf("renew error: %v", err)
+    }
+    if _, tok, _ := store.UserByToken(ctx, "hs1"); !tok.ExpiresAt.Equal(later) {
+        t.Errorf("expected expiry %v; got %v", later, tok.ExpiresAt)
+    }
+}
+
+// TestFileStore_Guests verifies that a guest is created with its session,
+// and deleted with its tokens once no session is left.
+func TestFileStore_Guests(t *testing.T) {
+    ctx := context.Background()
+    path := filepath.Join(t.TempDir(), "store.json")
+    store, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+
+    // 1) A guest is signed in by the session it was created with.
+    now := time.Now()
+    gone, err := store.CreateGuest(ctx, "guest-1", Token{ID: "s1", Hash: "h1", Session: true, CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+    if err != nil || !gone.Guest {
+        t.Fatalf("create guest: %+v, %v", gone, err)
+    }
+    if u, tok, err := store.UserByToken(ctx, "h1"); err != nil || u.ID != gone.ID || tok.UserID != gone.ID {
+        t.Errorf("expected the session to sign the guest in; got %+v %+v %v", u, tok, err)
+    }
+    kept, _ := store.CreateGuest(ctx, "guest-2", Token{ID: "s2", Hash: "h2", Session: true, CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+    store.AddFavorite(ctx, gone.ID, models.GIF{ID: "a"})
+
+    // 2) Once its session expires, the next guest replaces it.
+    if err := store.RenewToken(ctx, gone.ID, "s1", now.Add(-time.Second)); err != nil {
+        t.Fatalf("expire session: %v", err)
+    }
+    if _, err := store.CreateGuest(ctx, "guest-3", Token{ID: "s3", Hash: "h3", Session: true, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+        t.Fatalf("create guest: %v", err)
+    }
+    if _, err := store.Favorites(ctx, gone.ID); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected the expired guest to be deleted; got %v", err)
+    }
+    if _, err := store.Favorites(ctx, kept.ID); err != nil {
+        t.Errorf("expected the signed-in guest to stay; got %v", err)
+    }
+
+    // 3) Guests can't be named as the owner of legacy data.
+    if _, err := store.AdoptLegacy(ctx, "guest-2"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected ErrNotFound adopting into a guest; got %v", err)
+    }
+}
+
+// TestFileStore_Version1 verifies that the favorites and collections of a
+// file written before there were users go to nobody until AdoptLegacy gives
+// them to the named owner, merged with what the owner already has.
+func TestFileStore_Version1(t *testing.T) {
+    ctx := context.Background()
+    path := filepath.Join(t.TempDir(), "store.json")
+    v1 := `{"version":1,"favorites":[{"gif":{"id":"a"},"added_at":"2024-01-01T00:00:00Z"}],` +
+        `"collections":[{"id":"0123456789abcdef","name":"Old","items":[]}]}`
+    if err := os.WriteFile(path, []byte(v1), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    store, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("open error: %v", err)
+    }
+
+    // 1) Signing up doesn't hand anyone the legacy data.
+    first, _ := store.CreateUser(ctx, "first")
+    owner, _ := store.CreateUser(ctx, "owner")
+    if favs, _ := store.Favorites(ctx, first.ID); len(favs) != 0 || !store.HasLegacyData() {
+        t.Errorf("expected the first user to start empty; got %v", favoriteIDs(favs))
+    }
+
+    // 2) Only an existing owner can adopt it; their own data is kept.
+    if _, err := store.AdoptLegacy(ctx, "nobody"); !errors.Is(err, ErrNotFound) {
+        t.Errorf("expected ErrNotFound for an unknown owner; got %v", err)
+    }
+    store.AddFavorite(ctx, owner.ID, models.GIF{ID: "b"})
+    if adopted, err := store.AdoptLegacy(ctx, "OWNER"); !adopted || err != nil {
+        t.Fatalf("expected the owner to adopt the data; got %v, %v", adopted, err)
+    }
+    if favs, _ := store.Favorites(ctx, owner.ID); strings.Join(favoriteIDs(favs), ",") != "b,a" {
+        t.Errorf("expected [b a]; got %v", favoriteIDs(favs))
+    }
+    if cols, _ := store.Collections(ctx, owner.ID); len(cols) != 1 || cols[0].Name != "Old" {
+        t.Errorf("expected the owner to adopt the collections; got %+v", cols)
+    }
+
+    // 3) Adopting again, after a reopen, finds nothing left.
+    reopened, err := OpenFileStore(path)
+    if err != nil {
+        t.Fatalf("reopen error: %v", err)
+    }
+    if adopted, err := reopened.AdoptLegacy(ctx, "owner"); adopted || err != nil || reopened.HasLegacyData() {
+        t.Errorf("expected nothing left to adopt; got %v, %v", adopted, err)
+    }
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+    "encoding/json" // the on-disk format
+    "errors"        // distinguishing a missing file
+    "fmt"           // wrapping errors with the file path
+    "io/fs"         // fs.ErrNotExist
+    "os"            // reading the history file
+    "slices"        // copying and reordering entries
+    "strings"       // case-insensitive query comparison
+    "sync"          // guards the entries and serializes writes
+    "time"          // search timestamps and the flush delay
+
+    "github.com/adrian/gif-backend/models" // history entries
+)
+
+// historyVersion is the format version written to the history file.
+const historyVersion = 1
+
+// historyFlushDelay is how long a change to the history waits in memory
+// before the file is rewritten; every change in between shares that write.
+const historyFlushDelay = 5 * time.Second
+
+// historyData is the JSON document a historyLog keeps on disk.
+type historyData struct {
+    Version int                              `json:"version"`
+    Users   map[string][]models.HistoryEntry `json:"users"`
+}
+
+// historyLog holds every user's search history in memory and writes it to
+// its own file in the background. Searches are frequent and cheap to lose,
+// so recording one never waits on the disk or on the main data file.
+type historyLog struct {
+    path string // the JSON history file
+
+    mu      sync.Mutex
+    entries map[string][]models.HistoryEntry // user ID -> searches, newest first
+    dirty   bool                             // entries changed since the last write
+    pending *time.Timer                      // the scheduled write, if any
+    closed  bool                             // no more writes are scheduled
+
+    writeMu sync.Mutex // serializes writes, so an older snapshot never lands last
+}
+
+// openHistoryLog loads the history file at path. A missing file is an
+// empty history.
+func openHistoryLog(path string) (*historyLog, error) {
+    h := &historyLog{path: path, entries: make(map[string][]models.HistoryEntry)}
+    raw, err := os.ReadFile(path)
+    switch {
+    case errors.Is(err, fs.ErrNotExist):
+        return h, nil
+    case err != nil:
+        return nil, fmt.Errorf("storage: read %s: %w", path, err)
+    }
+    var data historyData
+    if err := json.Unmarshal(raw, &data); err != nil {
+        return nil, fmt.Errorf("storage: decode %s: %w", path, err)
+    }
+    if data.Version > historyVersion {
+        return nil, fmt.Errorf("storage: %s has version %d, newer than supported %d", path, data.Version, historyVersion)
+    }
+    if data.Users != nil {
+        h.entries = data.Users
+    }
+    return h, nil
+}
+
+// add puts query at the top of the user's history. When it is already
+// there nothing changes, so repeating a search costs nothing.
+func (h *historyLog) add(userID, query string) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    entries := h.entries[userID]
+    if len(entries) > 0 && strings.EqualFold(entries[0].Query, query) {
+        return
+    }
+    entries = slices.DeleteFunc(slices.Clone(entries), func(e models.HistoryEntry) bool { return strings.EqualFold(e.Query, query) })
+    entries = slices.Insert(entries, 0, models.HistoryEntry{Query: query, SearchedAt: time.Now().UTC()})
+    if len(entries) > MaxHistory {
+        entries = entries[:MaxHistory]
+    }
+    h.entries[userID] = entries
+    h.changed()
+}
+
+// get returns a copy of the user's history.
+func (h *historyLog) get(userID string) []models.HistoryEntry {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    return slices.Clone(h.entries[userID])
+}
+
+// set replaces the user's history, unless they already have one. It is
+// how history from an older data file is carried over.
+func (h *historyLog) set(userID string, entries []models.HistoryEntry) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    if _, ok := h.entries[userID]; ok || len(entries) == 0 {
+        return
+    }
+    h.entries[userID] = entries
+    h.changed()
+}
+
+// drop forgets the history of every user in userIDs.
+func (h *historyLog) drop(userIDs ...string) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    for _, id := range userIDs {
+        if _, ok := h.entries[id]; ok {
+            delete(h.entries, id)
+            h.changed()
+        }
+    }
+}
+
+// changed marks the entries for writing and schedules a write if none is
+// pending. The caller must hold h.mu.
+func (h *historyLog) changed() {
+    h.dirty = true
+    if h.pending == nil && !h.closed {
+        h.pending = time.AfterFunc(historyFlushDelay, func() { h.flush() })
+    }
+}
+
+// flush writes the entries to the file if they changed. On failure they
+// stay marked, so the next change, or close, tries again.
+func (h *historyLog) flush() error {
+    h.writeMu.Lock()
+    defer h.writeMu.Unlock()
+
+    // 1) Take a snapshot and clear the schedule.
+    h.mu.Lock()
+    if h.pending != nil {
+        h.pending.Stop()
+        h.pending = nil
+    }
+    if !h.dirty {
+        h.mu.Unlock()
+        return nil
+    }
+    raw, err := json.MarshalIndent(historyData{Version: historyVersion, Users: h.entries}, "", "  ")
+    h.dirty = false
+    h.mu.Unlock()
+    if err != nil {
+        return fmt.Errorf("storage: encode: %w", err)
+    }
+
+    // 2) Write it without holding h.mu, so searches carry on meanwhile.
+    if err := writeFile(h.path, raw); err != nil {
+        h.mu.Lock()
+        h.dirty = true
+        h.mu.Unlock()
+        return err
+    }
+    return nil
+}
+
+// close stops scheduling writes and writes any pending change.
+func (h *historyLog) close() error {
+    h.mu.Lock()
+    h.closed = true
+    h.mu.Unlock()
+    return h.flush()
+}
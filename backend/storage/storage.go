@@ -0,0 +1,191 @@
+// Package storage persists user accounts and their data: API tokens,
+// favorites, collections and search history. Handlers depend on the Store
+// interface; FileStore is the embedded, file-based implementation, and a
+// database-backed one can replace it without touching the handlers.
+package storage
+
+import (
+    "context" // request-scoped cancellation for implementations that need it
+    "errors"  // sentinel errors
+    "fmt"     // LimitError messages
+    "time"    // token expiry
+
+    "github.com/adrian/gif-backend/models" // the users and GIF snapshots we store
+)
+
+// Limits on what a store holds. Apart from MaxUsers and MaxGuests they
+// apply per user.
+const (
+    // MaxUsers caps how many registered accounts can exist.
+    MaxUsers = 10000
+
+    // MaxGuests caps how many guest accounts can exist at once. A guest is
+    // deleted once its sessions have expired or ended, freeing its place.
+    MaxGuests = 10000
+
+    // MaxTokens caps how many API tokens a user can hold.
+    MaxTokens = 20
+
+    // MaxSessions caps how many sessions a user can hold; starting another
+    // ends the oldest.
+    MaxSessions = 20
+
+    // MaxFavorites caps how many GIFs can be favorited.
+    MaxFavorites = 1000
+
+    // MaxCollections caps how many collections can exist.
+    MaxCollections = 100
+
+    // MaxCollectionGIFs caps how many GIFs one collection can hold.
+    MaxCollectionGIFs = 500
+
+    // MaxHistory is how many recent searches are kept; older ones are dropped.
+    MaxHistory = 50
+)
+
+// Errors returned by Store implementations. Callers compare with errors.Is.
+var (
+    // ErrNotFound means the item to change or remove doesn't exist, or
+    // belongs to another user.
+    ErrNotFound = errors.New("storage: not found")
+
+    // ErrFull means a limit such as MaxFavorites has been reached. The
+    // returned error is a *LimitError saying which.
+    ErrFull = errors.New("storage: limit reached")
+
+    // ErrExists means the item would duplicate an existing one, such as a
+    // collection name already in use.
+    ErrExists = errors.New("storage: already exists")
+
+    // ErrOrderMismatch means a reorder didn't list every item exactly once.
+    ErrOrderMismatch = errors.New("storage: order must list every item exactly once")
+)
+
+// LimitError reports which limit was reached. It matches ErrFull.
+type LimitError struct {
+    What string // what is limited, e.g. "favorites"
+    Max  int    // the limit
+}
+
+func (e *LimitError) Error() string {
+    return fmt.Sprintf("storage: at most %d %s are allowed", e.Max, e.What)
+}
+
+// Is makes errors.Is(err, ErrFull) true for every LimitError.
+func (e *LimitError) Is(target error) bool {
+    return target == ErrFull
+}
+
+// CollectionUpdate lists the collection fields to change; nil fields are
+// left as they are.
+type CollectionUpdate struct {
+    Name        *string
+    Description *string
+}
+
+// Token is a stored credential: an API token or a browser session. Only a
+// hash of the secret is kept, so a leaked data file can't be used to sign in.
+type Token struct {
+    ID     string `json:"id"`
+    UserID string `json:"user_id"`
+
+    // Hash is the hex SHA-256 of the secret the client sends.
+    Hash string `json:"hash"`
+
+    // Label is the user's name for an API token.
+    Label string `json:"label,omitempty"`
+
+    // Session marks a token issued as a session cookie rather than an API token.
+    Session bool `json:"session,omitempty"`
+
+    CreatedAt time.Time `json:"created_at"`
+
+    // ExpiresAt is when the token stops working; zero means never.
+    ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// Store persists users and everything they own. Every method that takes a
+// userID only sees that user's data. Implementations must be safe for
+// concurrent use.
+type Store interface {
+    // CreateUser creates an account. Names are unique, ignoring case; a
+    // duplicate is ErrExists.
+    CreateUser(ctx context.Context, name string) (models.User, error)
+
+    // CreateGuest creates a guest account signed in with session, whose
+    // UserID it fills in. Guests don't count toward MaxUsers; each is
+    // deleted, with everything it owns, once none of its sessions are left.
+    CreateGuest(ctx context.Context, name string, session Token) (models.User, error)
+
+    // AddToken stores a new token for token.UserID. API tokens are limited
+    // to MaxTokens; beyond MaxSessions sessions the oldest is dropped.
+    AddToken(ctx context.Context, token Token) error
+
+    // RenewToken moves the expiry of one of the user's tokens to expiresAt.
+    RenewToken(ctx context.Context, userID, tokenID string, expiresAt time.Time) error
+
+    // UserByToken returns the user and token whose hash is hash, or
+    // ErrNotFound if there is none or it has expired.
+    UserByToken(ctx context.Context, hash string) (models.User, Token, error)
+
+    // Tokens returns the user's API tokens, oldest first, without sessions.
+    Tokens(ctx context.Context, userID string) ([]Token, error)
+
+    // RevokeToken deletes one of the user's tokens or sessions.
+    RevokeToken(ctx context.Context, userID, tokenID string) error
+
+    // Favorites returns every favorite in the user's order.
+    Favorites(ctx context.Context, userID string) ([]models.Favorite, error)
+
+    // AddFavorite appends gif to the favorites. If it is already a favorite
+    // the existing entry is returned unchanged and created is false.
+    AddFavorite(ctx context.Context, userID string, gif models.GIF) (fav models.Favorite, created bool, err error)
+
+    // RemoveFavorite removes the favorite with the given GIF ID, or returns
+    // ErrNotFound.
+    RemoveFavorite(ctx context.Context, userID, id string) error
+
+    // ReorderFavorites puts the favorites in the order of ids, which must
+    // list every favorite's GIF ID exactly once, and returns the result.
+    ReorderFavorites(ctx context.Context, userID string, ids []string) ([]models.Favorite, error)
+
+    // Collections returns every collection, oldest first, without its GIFs.
+    Collections(ctx context.Context, userID string) ([]models.Collection, error)
+
+    // CreateCollection creates an empty collection. Names are unique,
+    // ignoring case; a duplicate is ErrExists.
+    CreateCollection(ctx context.Context, userID, name, description string) (models.Collection, error)
+
+    // Collection returns the collection with the given ID and the page of
+    // its GIFs that starts at offset and holds at most limit items.
+    Collection(ctx context.Context, userID, id string, offset, limit int) (models.Collection, []models.CollectionItem, error)
+
+    // UpdateCollection renames or re-describes a collection.
+    UpdateCollection(ctx context.Context, userID, id string, update CollectionUpdate) (models.Collection, error)
+
+    // DeleteCollection deletes a collection and its GIFs.
+    DeleteCollection(ctx context.Context, userID, id string) error
+
+    // AddToCollection inserts gif into a collection at position (clamped;
+    // negative means the end). If it is already there the existing item is
+    // returned unchanged and created is false.
+    AddToCollection(ctx context.Context, userID, id string, gif models.GIF, position int) (item models.CollectionItem, created bool, err error)
+
+    // RemoveFromCollection removes GIF gifID from a collection.
+    RemoveFromCollection(ctx context.Context, userID, id, gifID string) error
+
+    // MoveInCollection moves GIF gifID from collection id to position
+    // (clamped) in collection toID, which may be the same collection. A
+    // GIF already in the target collection is ErrExists.
+    MoveInCollection(ctx context.Context, userID, id, gifID, toID string, position int) error
+
+    // AddSearch records query at the top of the user's search history,
+    // moving it there if it was already present.
+    AddSearch(ctx context.Context, userID, query string) error
+
+    // History returns the user's recent searches, newest first.
+    History(ctx context.Context, userID string) ([]models.HistoryEntry, error)
+
+    // ClearHistory forgets the user's searches.
+    ClearHistory(ctx context.Context, userID string) error
+}
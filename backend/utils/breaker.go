@@ -0,0 +1,316 @@
+package utils
+
+import (
+    "context" // Provider methods take a request context
+    "errors"  // for classifying failures
+    "sync"    // breaker state is shared by concurrent handlers
+    "time"    // cool-down bookkeeping
+
+    "github.com/sirupsen/logrus" // log state transitions
+)
+
+// Defaults applied by NewBreakerProvider when a BreakerConfig field is zero.
+const (
+    // DefaultBreakerFailureThreshold is how many consecutive failures open the circuit.
+    DefaultBreakerFailureThreshold = 5
+
+    // DefaultBreakerCoolDown is how long the circuit stays open before probing again.
+    DefaultBreakerCoolDown = 30 * time.Second
+
+    // DefaultBreakerHalfOpenProbes is how many concurrent probe calls half-open allows.
+    DefaultBreakerHalfOpenProbes = 1
+)
+
+// ErrCircuitOpen is returned without contacting the upstream while the
+// circuit breaker is open (or half-open with its probes already in flight).
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+// Breaker states. The numeric values are exported as the
+// gif_upstream_circuit_state gauge.
+const (
+    BreakerClosed   BreakerState = 0 // calls flow normally
+    BreakerHalfOpen BreakerState = 1 // a limited number of probe calls are let through
+    BreakerOpen     BreakerState = 2 // calls fail fast with ErrCircuitOpen
+)
+
+// String returns the lower-case state name used in /ready and logs.
+func (s BreakerState) String() string {
+    switch s {
+    case BreakerClosed:
+        return "closed"
+    case BreakerHalfOpen:
+        return "half-open"
+    case BreakerOpen:
+        return "open"
+    default:
+        return "unknown"
+    }
+}
+
+// BreakerConfig controls a BreakerProvider.
+type BreakerConfig struct {
+    // FailureThreshold is the number of consecutive failures that opens the circuit.
+    FailureThreshold int
+
+    // CoolDown is how long the circuit stays open before a probe is allowed.
+    CoolDown time.Duration
+
+    // HalfOpenProbes is how many calls may be in flight while half-open.
+    HalfOpenProbes int
+}
+
+// BreakerProvider wraps a Provider with a circuit breaker. After
+// FailureThreshold consecutive upstream failures it opens and rejects calls
+// with ErrCircuitOpen for CoolDown, so requests stop waiting on a doomed
+// upstream; a cache in front of it can then answer with stale data. After
+// the cool-down it lets HalfOpenProbes calls through: a success closes the
+// circuit, a failure re-opens it.
+type BreakerProvider struct {
+    next             Provider
+    failureThreshold int
+    coolDown         time.Duration
+    halfOpenProbes   int
+
+    mu       sync.Mutex
+    state    BreakerState
+    failures int              // consecutive failures while closed
+    openedAt time.Time        // when the circuit last opened
+    probes   int              // probe calls in flight while half-open
+    now      func() time.Time // clock, replaceable in tests
+}
+
+// NewBreakerProvider returns a BreakerProvider in front of next, starting closed.
+func NewBreakerProvider(next Provider, cfg BreakerConfig) *BreakerProvider {
+    // 1) Fill in defaults for anything the caller left empty.
+    if cfg.FailureThreshold <= 0 {
+        cfg.FailureThreshold = DefaultBreakerFailureThreshold
+    }
+    if cfg.CoolDown <= 0 {
+        cfg.CoolDown = DefaultBreakerCoolDown
+    }
+    if cfg.HalfOpenProbes <= 0 {
+        cfg.HalfOpenProbes = DefaultBreakerHalfOpenProbes
+    }
+
+    // 2) Build the breaker and publish its initial state.
+    circuitState.Set(float64(BreakerClosed))
+    return &BreakerProvider{
+        next:             next,
+        failureThreshold: cfg.FailureThreshold,
+        coolDown:         cfg.CoolDown,
+        halfOpenProbes:   cfg.HalfOpenProbes,
+        state:            BreakerClosed,
+        now:              time.Now,
+    }
+}
+
+// Compile-time check that BreakerProvider satisfies Provider.
+var _ Provider = (*BreakerProvider)(nil)
+
+// Trending calls the wrapped provider through the breaker.
+func (b *BreakerProvider) Trending(ctx context.Context, params TrendingParams) (GiphyResponse, error) {
+    return callThrough(b, func() (GiphyResponse, error) {
+        return b.next.Trending(ctx, params)
+    })
+}
+
+// Search calls the wrapped provider through the breaker.
+func (b *BreakerProvider) Search(ctx context.Context, params SearchParams) (GiphyResponse, error) {
+    return callThrough(b, func() (GiphyResponse, error) {
+        return b.next.Search(ctx, params)
+    })
+}
+
+// GetByID calls the wrapped provider through the breaker.
+func (b *BreakerProvider) GetByID(ctx context.Context, id string) (Gif, error) {
+    return callThrough(b, func() (Gif, error) {
+        return b.next.GetByID(ctx, id)
+    })
+}
+
+// GetByIDs calls the wrapped provider through the breaker.
+func (b *BreakerProvider) GetByIDs(ctx context.Context, ids []string) ([]Gif, error) {
+    return callThrough(b, func() ([]Gif, error) {
+        return b.next.GetByIDs(ctx, ids)
+    })
+}
+
+// Random calls the wrapped provider through the breaker.
+func (b *BreakerProvider) Random(ctx context.Context, params RandomParams) (Gif, error) {
+    return callThrough(b, func() (Gif, error) {
+        return b.next.Random(ctx, params)
+    })
+}
+
+// Translate calls the wrapped provider through the breaker.
+func (b *BreakerProvider) Translate(ctx context.Context, params TranslateParams) (Gif, error) {
+    return callThrough(b, func() (Gif, error) {
+        return b.next.Translate(ctx, params)
+    })
+}
+
+// Autocomplete calls the wrapped provider through the breaker.
+func (b *BreakerProvider) Autocomplete(ctx context.Context, params AutocompleteParams) ([]Tag, error) {
+    return callThrough(b, func() ([]Tag, error) {
+        return b.next.Autocomplete(ctx, params)
+    })
+}
+
+// RelatedTerms calls the wrapped provider through the breaker.
+func (b *BreakerProvider) RelatedTerms(ctx context.Context, term string) ([]Tag, error) {
+    return callThrough(b, func() ([]Tag, error) {
+        return b.next.RelatedTerms(ctx, term)
+    })
+}
+
+// Categories calls the wrapped provider through the breaker.
+func (b *BreakerProvider) Categories(ctx context.Context) ([]Category, error) {
+    return callThrough(b, func() ([]Category, error) {
+        return b.next.Categories(ctx)
+    })
+}
+
+// Subcategories calls the wrapped provider through the breaker.
+func (b *BreakerProvider) Subcategories(ctx context.Context, category string) ([]Category, error) {
+    return callThrough(b, func() ([]Category, error) {
+        return b.next.Subcategories(ctx, category)
+    })
+}
+
+// State returns the current breaker state, moving an expired open
+// circuit to half-open so /ready reflects what the next call would see.
+func (b *BreakerProvider) State() BreakerState {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.coolDown {
+        b.setState(BreakerHalfOpen)
+    }
+    return b.state
+}
+
+// callThrough runs call if the breaker allows it and records the outcome.
+func callThrough[T any](b *BreakerProvider, call func() (T, error)) (T, error) {
+    // 1) Fail fast while open.
+    ok, probe := b.allow()
+    if !ok {
+        var zero T
+        return zero, ErrCircuitOpen
+    }
+
+    // 2) Make the call and feed its outcome back into the breaker.
+    v, err := call()
+    b.record(probe, err)
+    return v, err
+}
+
+// allow reports whether a call may proceed and whether it took one of the
+// half-open probe slots.
+func (b *BreakerProvider) allow() (ok, probe bool) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    switch b.state {
+    case BreakerOpen:
+        // Still cooling down: reject.
+        if b.now().Sub(b.openedAt) < b.coolDown {
+            return false, false
+        }
+        b.setState(BreakerHalfOpen)
+        fallthrough
+    case BreakerHalfOpen:
+        // Let a bounded number of probes through.
+        if b.probes >= b.halfOpenProbes {
+            return false, false
+        }
+        b.probes++
+        return true, true
+    default:
+        return true, false
+    }
+}
+
+// record updates the breaker with the outcome of a permitted call. probe
+// says whether the call held a probe slot; only probes decide a half-open
+// circuit. Calls let through while closed may finish after the circuit
+// changed state, and then say nothing about it.
+func (b *BreakerProvider) record(probe bool, err error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    // 1) Release the probe slot, if the call held one.
+    if probe {
+        b.probes--
+    }
+
+    // 2) The caller gave up: that says nothing about upstream health.
+    if errors.Is(err, context.Canceled) {
+        return
+    }
+
+    // 3) A probe settles a half-open circuit; an earlier probe may already have.
+    failed := isBreakerFailure(err)
+    if probe {
+        if b.state != BreakerHalfOpen {
+            return
+        }
+        if failed {
+            b.open()
+        } else {
+            b.failures = 0
+            b.setState(BreakerClosed)
+        }
+        return
+    }
+
+    // 4) Closed: count consecutive failures. Late outcomes of calls
+    //    started before the circuit opened are ignored.
+    if b.state != BreakerClosed {
+        return
+    }
+    if !failed {
+        b.failures = 0
+        return
+    }
+    b.failures++
+    if b.failures >= b.failureThreshold {
+        b.open()
+    }
+}
+
+// open trips the breaker. Caller holds b.mu.
+func (b *BreakerProvider) open() {
+    b.openedAt = b.now()
+    b.failures = 0
+    b.setState(BreakerOpen)
+}
+
+// setState changes state, publishing the gauge and logging transitions. Caller holds b.mu.
+func (b *BreakerProvider) setState(s BreakerState) {
+    if b.state == s {
+        return
+    }
+    logrus.WithFields(logrus.Fields{
+        "from": b.state.String(),
+        "to":   s.String(),
+    }).Warn("upstream circuit breaker state change")
+    b.state = s
+    circuitState.Set(float64(s))
+}
+
+// isBreakerFailure reports whether err indicates the upstream is unhealthy.
+// Client-side problems (bad request, not found, auth, our own exhausted
+// quota) say nothing about upstream health and are not counted.
+func isBreakerFailure(err error) bool {
+    if err == nil || errors.Is(err, ErrQuotaExhausted) {
+        return false
+    }
+    var upErr *UpstreamError
+    if errors.As(err, &upErr) {
+        return upErr.IsServerError() || upErr.IsRateLimited()
+    }
+    // Timeouts and transport errors.
+    return true
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+    "context" // Provider methods take a request context
+    "errors"  // for checking ErrCircuitOpen
+    "testing" // Go’s testing framework
+    "time"    // for advancing the fake clock
+)
+
+// TestBreakerProvider_OpensAndRecovers walks the breaker through
+// closed -> open -> half-open -> closed.
+func TestBreakerProvider_OpensAndRecovers(t *testing.T) {
+    upstream := &countingProvider{err: &UpstreamError{StatusCode: 503}}
+    breaker := NewBreakerProvider(upstream, BreakerConfig{FailureThreshold: 2, CoolDown: time.Minute})
+    now := time.Now()
+    breaker.now = func() time.Time { return now }
+    ctx := context.Background()
+    params := TrendingParams{Limit: 1}
+
+    // 1) Two consecutive upstream failures open the circuit.
+    breaker.Trending(ctx, params)
+    breaker.Trending(ctx, params)
+    if breaker.State() != BreakerOpen {
+        t.Fatalf("expected open after 2 failures; got %s", breaker.State())
+    }
+
+    // 2) While open, calls fail fast without reaching upstream.
+    if _, err := breaker.Trending(ctx, params); !errors.Is(err, ErrCircuitOpen) {
+        t.Fatalf("expected ErrCircuitOpen; got %v", err)
+    }
+    if upstream.calls != 2 {
+        t.Fatalf("expected upstream untouched while open; got %d calls", upstream.calls)
+    }
+
+    // 3) After the cool-down a probe goes through; success closes the circuit.
+    now = now.Add(2 * time.Minute)
+    if breaker.State() != BreakerHalfOpen {
+        t.Fatalf("expected half-open after cool-down; got %s", breaker.State())
+    }
+    upstream.err = nil
+    if _, err := breaker.Trending(ctx, params); err != nil {
+        t.Fatalf("probe failed: %v", err)
+    }
+    if breaker.State() != BreakerClosed {
+        t.Errorf("expected closed after successful probe; got %s", breaker.State())
+    }
+}
+
+// TestBreakerProvider_IgnoresClientErrors verifies that 4xx responses such
+// as "not found" don't count towards opening the circuit.
+func TestBreakerProvider_IgnoresClientErrors(t *testing.T) {
+    upstream := &countingProvider{err: &UpstreamError{StatusCode: 404}}
+    breaker := NewBreakerProvider(upstream, BreakerConfig{FailureThreshold: 1})
+
+    breaker.Trending(context.Background(), TrendingParams{Limit: 1})
+    if breaker.State() != BreakerClosed {
+        t.Errorf("expected closed after a 404; got %s", breaker.State())
+    }
+}
+
+// TestBreakerProvider_LateCallsDontProbe verifies that calls let through
+// while closed, which finish after the circuit went half-open, neither
+// release probe slots they never held nor close the circuit.
+func TestBreakerProvider_LateCallsDontProbe(t *testing.T) {
+    breaker := NewBreakerProvider(&countingProvider{}, BreakerConfig{FailureThreshold: 1, CoolDown: time.Minute})
+    now := time.Now()
+    breaker.now = func() time.Time { return now }
+
+    // 1) Two calls start while closed; then the circuit opens and cools down.
+    _, late1 := breaker.allow()
+    _, late2 := breaker.allow()
+    breaker.record(false, &UpstreamError{StatusCode: 503})
+    now = now.Add(2 * time.Minute)
+
+    // 2) One probe is admitted; the late calls finish successfully.
+    if ok, probe := breaker.allow(); !ok || !probe {
+        t.Fatalf("expected a probe slot; got ok=%v probe=%v", ok, probe)
+    }
+    breaker.record(late1, nil)
+    breaker.record(late2, nil)
+    if breaker.State() != BreakerHalfOpen {
+        t.Errorf("expected late calls to leave the circuit half-open; got %s", breaker.State())
+    }
+
+    // 3) The probe slot is still taken, so no second probe gets through.
+    if ok, _ := breaker.allow(); ok {
+        t.Error("expected the single probe slot to stay reserved")
+    }
+}
@@ -0,0 +1,501 @@
+package utils
+
+import (
+    "container/list" // doubly-linked list for LRU ordering
+    "context"        // Provider methods take a request context
+    "encoding/json"  // to estimate the size of cached values
+    "fmt"            // for formatting cache keys
+    "strings"        // for normalizing search terms
+    "sync"           // the cache is shared by concurrent handlers
+    "time"           // TTL bookkeeping
+
+    "github.com/sirupsen/logrus" // log failed background refreshes
+)
+
+// Defaults applied by NewCachedProvider when a CacheConfig field is zero.
+const (
+    // DefaultCacheTTL is how long a cached upstream response is served.
+    DefaultCacheTTL = 60 * time.Second
+
+    // DefaultCacheMaxEntries caps the number of cached responses.
+    DefaultCacheMaxEntries = 1000
+
+    // DefaultCacheMaxBytes caps the approximate total size of cached responses.
+    DefaultCacheMaxBytes = 32 << 20 // 32 MiB
+
+    // DefaultStaleWhileRevalidate is how long past its TTL an entry may be
+    // served while a background refresh runs.
+    DefaultStaleWhileRevalidate = 30 * time.Second
+
+    // DefaultStaleIfError is how long past its TTL an entry may be served
+    // when the upstream call fails.
+    DefaultStaleIfError = 10 * time.Minute
+
+    // DefaultSuggestTTL is the TTL main uses for autocomplete and related
+    // terms: short, since they are requested on almost every keystroke.
+    DefaultSuggestTTL = 30 * time.Second
+
+    // DefaultCategoriesTTL is the TTL main uses for the category tree, which
+    // changes rarely.
+    DefaultCategoriesTTL = 6 * time.Hour
+)
+
+// CacheConfig controls the in-memory response cache.
+type CacheConfig struct {
+    // TTL is how long an entry stays fresh after it is stored.
+    TTL time.Duration
+
+    // MaxEntries is the maximum number of entries before LRU eviction kicks in.
+    MaxEntries int
+
+    // MaxBytes is the maximum approximate size (JSON-encoded) of all entries.
+    MaxBytes int64
+
+    // StaleWhileRevalidate is how long after expiry an entry is still served
+    // immediately while one background refresh updates it. Zero disables it.
+    StaleWhileRevalidate time.Duration
+
+    // StaleIfError is the maximum staleness of an entry served in place of
+    // an upstream failure. Zero disables it.
+    StaleIfError time.Duration
+
+    // EndpointTTL overrides TTL for individual endpoints, keyed by the
+    // cache key's endpoint name ("autocomplete", "related", ...).
+    EndpointTTL map[string]time.Duration
+}
+
+// CachedProvider wraps another Provider and serves repeated Trending and
+// Search calls from memory, so a whole team loading the same page costs a
+// single upstream request. Expired entries can still be served while they
+// are refreshed in the background, or when the upstream is failing; the
+// outcome of each lookup is reported through WithCacheInfo. Cached values
+// are shared between callers and must be treated as read-only.
+type CachedProvider struct {
+    next                 Provider       // the provider we fall through to on a miss
+    cache                *responseCache // LRU + TTL store
+    staleWhileRevalidate time.Duration  // serve-stale window while refreshing
+    staleIfError         time.Duration  // serve-stale window on upstream failure
+
+    mu         sync.Mutex      // guards refreshing
+    refreshing map[string]bool // keys with a background refresh in flight
+    refreshWG  sync.WaitGroup  // tracks background refreshes (used by tests)
+}
+
+// NewCachedProvider returns a CachedProvider in front of next.
+func NewCachedProvider(next Provider, cfg CacheConfig) *CachedProvider {
+    // 1) Fill in defaults for anything the caller left empty.
+    if cfg.TTL <= 0 {
+        cfg.TTL = DefaultCacheTTL
+    }
+    if cfg.MaxEntries <= 0 {
+        cfg.MaxEntries = DefaultCacheMaxEntries
+    }
+    if cfg.MaxBytes <= 0 {
+        cfg.MaxBytes = DefaultCacheMaxBytes
+    }
+
+    // 2) Build the wrapper.
+    return &CachedProvider{
+        next:                 next,
+        cache:                newResponseCache(cfg),
+        staleWhileRevalidate: cfg.StaleWhileRevalidate,
+        staleIfError:         cfg.StaleIfError,
+        refreshing:           make(map[string]bool),
+    }
+}
+
+// Compile-time check that CachedProvider satisfies Provider.
+var _ Provider = (*CachedProvider)(nil)
+
+// Trending serves trending GIFs from the cache, falling through on a miss.
+func (p *CachedProvider) Trending(ctx context.Context, params TrendingParams) (GiphyResponse, error) {
+    key := cacheKey{
+        Endpoint: "trending",
+        Type:     string(params.Type.orDefault()),
+        Rating:   strings.ToLower(params.Rating),
+        Region:   strings.ToUpper(params.Region),
+        Limit:    params.Limit,
+        Offset:   params.Offset,
+    }
+    return fetchCached(ctx, p, key, func(ctx context.Context) (GiphyResponse, error) {
+        return p.next.Trending(ctx, params)
+    })
+}
+
+// Search serves search results from the cache, falling through on a miss.
+func (p *CachedProvider) Search(ctx context.Context, params SearchParams) (GiphyResponse, error) {
+    key := cacheKey{
+        Endpoint: "search",
+        Type:     string(params.Type.orDefault()),
+        Query:    normalizeQuery(params.Query),
+        Rating:   strings.ToLower(params.Rating),
+        Lang:     strings.ToLower(params.Lang),
+        Limit:    params.Limit,
+        Offset:   params.Offset,
+    }
+    return fetchCached(ctx, p, key, func(ctx context.Context) (GiphyResponse, error) {
+        return p.next.Search(ctx, params)
+    })
+}
+
+// GetByID serves a single GIF from the cache, falling through on a miss.
+// Single and batch lookups share per-GIF entries.
+func (p *CachedProvider) GetByID(ctx context.Context, id string) (Gif, error) {
+    return fetchCached(ctx, p, gifKey(id), func(ctx context.Context) (Gif, error) {
+        return p.next.GetByID(ctx, id)
+    })
+}
+
+// GetByIDs serves each GIF it has cached and fetches only the rest, in one
+// upstream batch call. If that call fails, stale entries within
+// StaleIfError stand in for the missing GIFs. When only part of the batch
+// could be served, the partial result is returned with a *PartialError.
+func (p *CachedProvider) GetByIDs(ctx context.Context, ids []string) ([]Gif, error) {
+    now := p.cache.now()
+    found := make([]Gif, 0, len(ids))
+    stale := make(map[string]*cacheEntry)
+    var missing []string
+
+    // 1) Split ids into fresh cache hits and ones we need to fetch.
+    for _, id := range ids {
+        key := gifKey(id)
+        if entry, ok := p.cache.get(key.String()); ok && now.Before(entry.expires) {
+            cacheHitsTotal.WithLabelValues(key.Endpoint).Inc()
+            found = append(found, entry.value.(Gif))
+            continue
+        } else if ok {
+            stale[id] = entry
+        }
+        cacheMissesTotal.WithLabelValues(key.Endpoint).Inc()
+        missing = append(missing, id)
+    }
+    if len(missing) == 0 {
+        recordCacheInfo(ctx, CacheInfo{Status: CacheHit})
+        return found, nil
+    }
+
+    // 2) Fetch the rest in one call and cache each GIF individually.
+    fetched, err := p.next.GetByIDs(ctx, missing)
+    if err != nil {
+        // 2a) Fall back to stale copies of the missing GIFs, if young enough.
+        for _, id := range missing {
+            if entry, ok := stale[id]; ok && now.Sub(entry.expires) <= p.staleIfError {
+                cacheStaleServedTotal.WithLabelValues("gif", "error").Inc()
+                found = append(found, entry.value.(Gif))
+            }
+        }
+        if len(found) == 0 {
+            return nil, err
+        }
+
+        // 2b) Some GIFs could be served: return them, flagging the rest as failed.
+        recordCacheInfo(ctx, CacheInfo{Status: CacheStale, RevalidationFailed: true})
+        return found, &PartialError{Err: err}
+    }
+    for _, g := range fetched {
+        key := gifKey(g.ID)
+        p.cache.set(key.Endpoint, key.String(), g)
+    }
+    recordCacheInfo(ctx, CacheInfo{Status: CacheMiss})
+    return append(found, fetched...), nil
+}
+
+// Random is never cached: every call should be a fresh pick.
+func (p *CachedProvider) Random(ctx context.Context, params RandomParams) (Gif, error) {
+    return p.next.Random(ctx, params)
+}
+
+// Translate serves phrase translations from the cache, falling through on a
+// miss. Caching pins the answer for a phrase and weirdness for one TTL.
+func (p *CachedProvider) Translate(ctx context.Context, params TranslateParams) (Gif, error) {
+    key := cacheKey{
+        Endpoint:  "translate",
+        Type:      string(params.Type.orDefault()),
+        Query:     normalizeQuery(params.Phrase),
+        Rating:    strings.ToLower(params.Rating),
+        Weirdness: params.Weirdness,
+    }
+    return fetchCached(ctx, p, key, func(ctx context.Context) (Gif, error) {
+        return p.next.Translate(ctx, params)
+    })
+}
+
+// Autocomplete serves term suggestions from the cache, falling through on a miss.
+func (p *CachedProvider) Autocomplete(ctx context.Context, params AutocompleteParams) ([]Tag, error) {
+    key := cacheKey{
+        Endpoint: "autocomplete",
+        Query:    normalizeQuery(params.Query),
+        Limit:    params.Limit,
+    }
+    return fetchCached(ctx, p, key, func(ctx context.Context) ([]Tag, error) {
+        return p.next.Autocomplete(ctx, params)
+    })
+}
+
+// RelatedTerms serves related terms from the cache, falling through on a miss.
+func (p *CachedProvider) RelatedTerms(ctx context.Context, term string) ([]Tag, error) {
+    key := cacheKey{Endpoint: "related", Query: normalizeQuery(term)}
+    return fetchCached(ctx, p, key, func(ctx context.Context) ([]Tag, error) {
+        return p.next.RelatedTerms(ctx, term)
+    })
+}
+
+// Categories serves the category list from the cache, falling through on a miss.
+func (p *CachedProvider) Categories(ctx context.Context) ([]Category, error) {
+    return fetchCached(ctx, p, cacheKey{Endpoint: "categories"}, func(ctx context.Context) ([]Category, error) {
+        return p.next.Categories(ctx)
+    })
+}
+
+// Subcategories serves a category's subcategories from the cache, falling
+// through on a miss.
+func (p *CachedProvider) Subcategories(ctx context.Context, category string) ([]Category, error) {
+    key := cacheKey{Endpoint: "subcategories", Query: strings.ToLower(category)}
+    return fetchCached(ctx, p, key, func(ctx context.Context) ([]Category, error) {
+        return p.next.Subcategories(ctx, category)
+    })
+}
+
+// gifKey is the cache key for a single GIF, shared by GetByID and GetByIDs.
+func gifKey(id string) cacheKey {
+    return cacheKey{Endpoint: "gif", Query: id}
+}
+
+// cacheKey identifies one upstream response. Every field that changes the
+// upstream result must be part of the key, in normalized form.
+type cacheKey struct {
+    Endpoint  string // "trending", "search", ...
+    Type      string // media type: "gifs" or "stickers"
+    Query     string // normalized search term
+    Rating    string // lower-cased rating filter
+    Lang      string // lower-cased language code
+    Region    string // upper-cased country code
+    Limit     int    // page size
+    Offset    int    // zero-based offset of the first item
+    Weirdness int    // translate weirdness (0-10)
+}
+
+// String renders the key in a stable form usable as a map key.
+func (k cacheKey) String() string {
+    return fmt.Sprintf("%s|type=%s|q=%s|rating=%s|lang=%s|region=%s|limit=%d|offset=%d|weirdness=%d",
+        k.Endpoint, k.Type, k.Query, k.Rating, k.Lang, k.Region, k.Limit, k.Offset, k.Weirdness)
+}
+
+// normalizeQuery lower-cases a search term and collapses whitespace so
+// "Cats ", "cats" and " CATS" share one cache entry.
+func normalizeQuery(q string) string {
+    return strings.ToLower(strings.Join(strings.Fields(q), " "))
+}
+
+// fetchCached returns the cached value for key, or calls fetch and caches
+// its result on success. Errors are never cached. Expired entries are served
+// stale while a background refresh runs (within staleWhileRevalidate), or in
+// place of an upstream error (within staleIfError).
+func fetchCached[T any](ctx context.Context, p *CachedProvider, key cacheKey, fetch func(context.Context) (T, error)) (T, error) {
+    k := key.String()
+
+    // 1) Serve fresh entries directly; note how stale an expired one is.
+    entry, found := p.cache.get(k)
+    var staleFor time.Duration
+    if found {
+        now := p.cache.now()
+        if now.Before(entry.expires) {
+            cacheHitsTotal.WithLabelValues(key.Endpoint).Inc()
+            recordCacheInfo(ctx, CacheInfo{Status: CacheHit, Age: now.Sub(entry.stored)})
+            return entry.value.(T), nil
+        }
+        staleFor = now.Sub(entry.expires)
+
+        // 2) Slightly stale: answer now, refresh in the background.
+        if staleFor <= p.staleWhileRevalidate {
+            p.refreshAsync(ctx, key, func(ctx context.Context) (interface{}, error) {
+                return fetch(ctx)
+            })
+            cacheStaleServedTotal.WithLabelValues(key.Endpoint, "revalidate").Inc()
+            recordCacheInfo(ctx, CacheInfo{Status: CacheStale, Age: now.Sub(entry.stored)})
+            return entry.value.(T), nil
+        }
+    }
+    cacheMissesTotal.WithLabelValues(key.Endpoint).Inc()
+
+    // 3) Fall through to the upstream provider.
+    v, err := fetch(ctx)
+    if err != nil {
+        // 3a) Upstream failed: an old answer beats an error, within limits.
+        if found && staleFor <= p.staleIfError {
+            cacheStaleServedTotal.WithLabelValues(key.Endpoint, "error").Inc()
+            recordCacheInfo(ctx, CacheInfo{
+                Status:             CacheStale,
+                Age:                p.cache.now().Sub(entry.stored),
+                RevalidationFailed: true,
+            })
+            return entry.value.(T), nil
+        }
+        return v, err
+    }
+
+    // 4) Remember the successful response.
+    p.cache.set(key.Endpoint, k, v)
+    recordCacheInfo(ctx, CacheInfo{Status: CacheMiss})
+    return v, nil
+}
+
+// refreshAsync refetches key in the background unless a refresh for it is
+// already running. The refresh keeps the request's values but not its
+// cancellation, so it completes even after the triggering client is gone.
+func (p *CachedProvider) refreshAsync(ctx context.Context, key cacheKey, fetch func(context.Context) (interface{}, error)) {
+    k := key.String()
+
+    // 1) Only one refresh per key at a time.
+    p.mu.Lock()
+    if p.refreshing[k] {
+        p.mu.Unlock()
+        return
+    }
+    p.refreshing[k] = true
+    p.mu.Unlock()
+
+    // 2) Refresh in a goroutine and store the result on success.
+    p.refreshWG.Add(1)
+    go func() {
+        defer p.refreshWG.Done()
+        defer func() {
+            p.mu.Lock()
+            delete(p.refreshing, k)
+            p.mu.Unlock()
+        }()
+
+        v, err := fetch(context.WithoutCancel(ctx))
+        if err != nil {
+            logrus.WithError(err).WithField("key", k).Warn("background cache refresh failed")
+            return
+        }
+        p.cache.set(key.Endpoint, k, v)
+    }()
+}
+
+// responseCache is a size-bounded LRU cache with a per-entry TTL.
+type responseCache struct {
+    mu         sync.Mutex
+    ttl        time.Duration
+    ttlFor     map[string]time.Duration // per-endpoint TTL overrides
+    retain     time.Duration            // how long past expiry entries are kept for stale serving
+    maxEntries int
+    maxBytes   int64
+    bytes      int64                    // current approximate size
+    ll         *list.List               // front = most recently used
+    items      map[string]*list.Element // key -> element holding *cacheEntry
+    now        func() time.Time         // clock, replaceable in tests
+}
+
+// cacheEntry is the value stored in each list element.
+type cacheEntry struct {
+    key     string
+    value   interface{}
+    size    int64
+    stored  time.Time // when the value was fetched
+    expires time.Time // when the value stops being fresh
+}
+
+// newResponseCache builds an empty cache from cfg.
+func newResponseCache(cfg CacheConfig) *responseCache {
+    // Keep expired entries around for as long as either stale window needs them.
+    retain := cfg.StaleWhileRevalidate
+    if cfg.StaleIfError > retain {
+        retain = cfg.StaleIfError
+    }
+    return &responseCache{
+        ttl:        cfg.TTL,
+        ttlFor:     cfg.EndpointTTL,
+        retain:     retain,
+        maxEntries: cfg.MaxEntries,
+        maxBytes:   cfg.MaxBytes,
+        ll:         list.New(),
+        items:      make(map[string]*list.Element),
+        now:        time.Now,
+    }
+}
+
+// get returns the entry for key if present. The entry may be past its
+// expiry but still within the retention window; callers compare
+// entry.expires themselves. Entries beyond retention are removed on access.
+// Entries are never mutated after being stored, so the returned pointer is
+// safe to read without the lock.
+func (c *responseCache) get(key string) (*cacheEntry, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    el, ok := c.items[key]
+    if !ok {
+        return nil, false
+    }
+    entry := el.Value.(*cacheEntry)
+    if c.now().After(entry.expires.Add(c.retain)) {
+        c.removeElement(el, "expired")
+        return nil, false
+    }
+    c.ll.MoveToFront(el)
+    return entry, true
+}
+
+// set stores value under key with the TTL configured for endpoint,
+// evicting least recently used entries until the cache is back within its
+// entry and byte limits.
+func (c *responseCache) set(endpoint, key string, value interface{}) {
+    // 1) Estimate the size outside the lock; values too large to ever fit are skipped.
+    size := estimateSize(value)
+    if size > c.maxBytes {
+        return
+    }
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    // 2) Replace an existing entry in place, or push a new one.
+    ttl := c.ttl
+    if d, ok := c.ttlFor[endpoint]; ok && d > 0 {
+        ttl = d
+    }
+    now := c.now()
+    entry := &cacheEntry{key: key, value: value, size: size, stored: now, expires: now.Add(ttl)}
+    if el, ok := c.items[key]; ok {
+        c.bytes -= el.Value.(*cacheEntry).size
+        el.Value = entry
+        c.ll.MoveToFront(el)
+    } else {
+        c.items[key] = c.ll.PushFront(entry)
+    }
+    c.bytes += size
+
+    // 3) Evict from the back until we're within limits.
+    for c.ll.Len() > c.maxEntries || c.bytes > c.maxBytes {
+        c.removeElement(c.ll.Back(), "capacity")
+    }
+    c.updateGauges()
+}
+
+// removeElement drops el from the cache and records why. Caller holds c.mu.
+func (c *responseCache) removeElement(el *list.Element, reason string) {
+    entry := el.Value.(*cacheEntry)
+    c.ll.Remove(el)
+    delete(c.items, entry.key)
+    c.bytes -= entry.size
+    cacheEvictionsTotal.WithLabelValues(reason).Inc()
+    c.updateGauges()
+}
+
+// updateGauges publishes the current size. Caller holds c.mu.
+func (c *responseCache) updateGauges() {
+    cacheEntries.Set(float64(c.ll.Len()))
+    cacheBytes.Set(float64(c.bytes))
+}
+
+// estimateSize approximates the memory cost of v by its JSON encoding,
+// which is close to what we would send to clients anyway.
+func estimateSize(v interface{}) int64 {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return 0
+    }
+    return int64(len(b))
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+    "context"  // Provider methods take a request context
+    "errors"   // to simulate upstream failures
+    "testing"  // Go’s testing framework
+    "time"     // for advancing the fake clock
+)
+
+// countingProvider is a Provider that counts upstream calls and returns
+// a response tagged with the requested offset (and the call number), or err when set.
+type countingProvider struct {
+    calls   int
+    err     error
+    lastIDs []string // ids passed to the last GetByIDs call
+}
+
+func (p *countingProvider) Trending(ctx context.Context, params TrendingParams) (GiphyResponse, error) {
+    p.calls++
+    if p.err != nil {
+        return GiphyResponse{}, p.err
+    }
+    return GiphyResponse{Pagination: Pagination{
+        Offset: params.Offset,
+        Count:  p.calls,
+    }}, nil
+}
+
+func (p *countingProvider) Search(ctx context.Context, params SearchParams) (GiphyResponse, error) {
+    p.calls++
+    return GiphyResponse{Data: []Gif{{ID: params.Query}}}, nil
+}
+
+func (p *countingProvider) GetByID(ctx context.Context, id string) (Gif, error) {
+    p.calls++
+    return Gif{ID: id}, nil
+}
+
+func (p *countingProvider) GetByIDs(ctx context.Context, ids []string) ([]Gif, error) {
+    p.calls++
+    p.lastIDs = ids
+    if p.err != nil {
+        return nil, p.err
+    }
+    gifs := make([]Gif, len(ids))
+    for i, id := range ids {
+        gifs[i] = Gif{ID: id}
+    }
+    return gifs, nil
+}
+
+func (p *countingProvider) Random(ctx context.Context, params RandomParams) (Gif, error) {
+    p.calls++
+    return Gif{ID: params.Tag}, nil
+}
+
+func (p *countingProvider) Translate(ctx context.Context, params TranslateParams) (Gif, error) {
+    p.calls++
+    return Gif{ID: params.Phrase}, nil
+}
+
+func (p *countingProvider) Autocomplete(ctx context.Context, params AutocompleteParams) ([]Tag, error) {
+    p.calls++
+    return []Tag{{Name: params.Query}}, nil
+}
+
+func (p *countingProvider) RelatedTerms(ctx context.Context, term string) ([]Tag, error) {
+    p.calls++
+    return []Tag{{Name: term}}, nil
+}
+
+func (p *countingProvider) Categories(ctx context.Context) ([]Category, error) {
+    p.calls++
+    return []Category{{Name: "Animals", NameEncoded: "animals"}}, nil
+}
+
+func (p *countingProvider) Subcategories(ctx context.Context, category string) ([]Category, error) {
+    p.calls++
+    return []Category{{Name: category}}, nil
+}
+
+// TestCachedProvider_HitsAndExpiry verifies that repeated calls are served
+// from the cache, that search terms are normalized, that language and region
+// are part of the key, and that TTL expiry sends the next call upstream again.
+func TestCachedProvider_HitsAndExpiry(t *testing.T) {
+    upstream := &countingProvider{}
+    cached := NewCachedProvider(upstream, CacheConfig{TTL: time.Minute})
+
+    // 1) Replace the clock so we can expire entries deterministically.
+    now := time.Now()
+    cached.cache.now = func() time.Time { return now }
+    ctx := context.Background()
+
+    // 2) Two identical trending calls: one upstream request.
+    cached.Trending(ctx, TrendingParams{Limit: 12})
+    cached.Trending(ctx, TrendingParams{Limit: 12})
+    if upstream.calls != 1 {
+        t.Fatalf("expected 1 upstream call; got %d", upstream.calls)
+    }
+
+    // 3) Searches differing only in case/whitespace share an entry.
+    cached.Search(ctx, SearchParams{Query: "Cats ", Limit: 12})
+    cached.Search(ctx, SearchParams{Query: "cats", Limit: 12})
+    if upstream.calls != 2 {
+        t.Fatalf("expected 2 upstream calls; got %d", upstream.calls)
+    }
+
+    // 3b) A different language or region is a different entry.
+    cached.Search(ctx, SearchParams{Query: "cats", Lang: "es", Limit: 12})
+    cached.Trending(ctx, TrendingParams{Region: "DE", Limit: 12})
+    if upstream.calls != 4 {
+        t.Fatalf("expected 4 upstream calls; got %d", upstream.calls)
+    }
+
+    // 4) After the TTL elapses the entry is refetched.
+    now = now.Add(2 * time.Minute)
+    cached.Trending(ctx, TrendingParams{Limit: 12})
+    if upstream.calls != 5 {
+        t.Fatalf("expected 5 upstream calls after expiry; got %d", upstream.calls)
+    }
+}
+
+// TestCachedProvider_LRUEviction verifies that the least recently used entry
+// is evicted once MaxEntries is exceeded.
+func TestCachedProvider_LRUEviction(t *testing.T) {
+    upstream := &countingProvider{}
+    cached := NewCachedProvider(upstream, CacheConfig{MaxEntries: 2})
+    ctx := context.Background()
+
+    // 1) Fill the cache with pages 1 and 2, then touch page 1 so page 2 is LRU.
+    cached.Trending(ctx, TrendingParams{Limit: 10})
+    cached.Trending(ctx, TrendingParams{Limit: 10, Offset: 10})
+    cached.Trending(ctx, TrendingParams{Limit: 10})
+
+    // 2) Page 3 pushes the cache over capacity and evicts page 2.
+    cached.Trending(ctx, TrendingParams{Limit: 10, Offset: 20})
+    if upstream.calls != 3 {
+        t.Fatalf("expected 3 upstream calls; got %d", upstream.calls)
+    }
+
+    // 3) Page 1 is still cached; page 2 must go upstream again.
+    cached.Trending(ctx, TrendingParams{Limit: 10})
+    cached.Trending(ctx, TrendingParams{Limit: 10, Offset: 10})
+    if upstream.calls != 4 {
+        t.Errorf("expected 4 upstream calls; got %d", upstream.calls)
+    }
+}
+
+// TestCachedProvider_StaleWhileRevalidate verifies that a recently expired
+// entry is served immediately as STALE while a background refresh replaces it.
+func TestCachedProvider_StaleWhileRevalidate(t *testing.T) {
+    upstream := &countingProvider{}
+    cached := NewCachedProvider(upstream, CacheConfig{
+        TTL:                  time.Minute,
+        StaleWhileRevalidate: time.Minute,
+    })
+    now := time.Now()
+    cached.cache.now = func() time.Time { return now }
+    params := TrendingParams{Limit: 12}
+
+    // 1) Prime the cache, then let the entry expire (but stay within the SWR window).
+    cached.Trending(context.Background(), params)
+    now = now.Add(90 * time.Second)
+
+    // 2) The stale value (from call #1) is served and reported as STALE.
+    ctx, info := WithCacheInfo(context.Background())
+    resp, err := cached.Trending(ctx, params)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if resp.Pagination.Count != 1 || info.Status != CacheStale {
+        t.Fatalf("expected stale value from call 1; got count=%d status=%q", resp.Pagination.Count, info.Status)
+    }
+
+    // 3) Once the background refresh finishes, the fresh value (call #2) is a HIT.
+    cached.refreshWG.Wait()
+    ctx, info = WithCacheInfo(context.Background())
+    resp, _ = cached.Trending(ctx, params)
+    if resp.Pagination.Count != 2 || info.Status != CacheHit {
+        t.Errorf("expected refreshed value from call 2; got count=%d status=%q", resp.Pagination.Count, info.Status)
+    }
+}
+
+// TestCachedProvider_StaleIfError verifies that an expired entry is served in
+// place of an upstream error within StaleIfError, and not beyond it.
+func TestCachedProvider_StaleIfError(t *testing.T) {
+    upstream := &countingProvider{}
+    cached := NewCachedProvider(upstream, CacheConfig{
+        TTL:          time.Minute,
+        StaleIfError: 5 * time.Minute,
+    })
+    now := time.Now()
+    cached.cache.now = func() time.Time { return now }
+    params := TrendingParams{Limit: 12}
+
+    // 1) Prime the cache, then break the upstream and expire the entry.
+    cached.Trending(context.Background(), params)
+    upstream.err = errors.New("giphy down")
+    now = now.Add(3 * time.Minute)
+
+    // 2) Within StaleIfError: stale data, flagged as a failed revalidation.
+    ctx, info := WithCacheInfo(context.Background())
+    if _, err := cached.Trending(ctx, params); err != nil {
+        t.Fatalf("expected stale fallback; got error %v", err)
+    }
+    if info.Status != CacheStale || !info.RevalidationFailed {
+        t.Errorf("expected STALE with failed revalidation; got %+v", *info)
+    }
+
+    // 3) Beyond StaleIfError: the upstream error surfaces.
+    now = now.Add(10 * time.Minute)
+    if _, err := cached.Trending(context.Background(), params); err == nil {
+        t.Error("expected upstream error once max staleness is exceeded")
+    }
+}
+
+// TestCachedProvider_GetByIDsFetchesOnlyMissing verifies that a batch lookup
+// reuses GIFs cached by earlier lookups and asks upstream only for the rest.
+func TestCachedProvider_GetByIDsFetchesOnlyMissing(t *testing.T) {
+    upstream := &countingProvider{}
+    cached := NewCachedProvider(upstream, CacheConfig{})
+    ctx := context.Background()
+
+    // 1) A single lookup caches "a".
+    cached.GetByID(ctx, "a")
+
+    // 2) The batch only fetches "b" and "c".
+    gifs, err := cached.GetByIDs(ctx, []string{"a", "b", "c"})
+    if err != nil || len(gifs) != 3 {
+        t.Fatalf("expected 3 GIFs; got %d (err %v)", len(gifs), err)
+    }
+    if len(upstream.lastIDs) != 2 || upstream.lastIDs[0] != "b" || upstream.lastIDs[1] != "c" {
+        t.Errorf("expected upstream batch [b c]; got %v", upstream.lastIDs)
+    }
+
+    // 3) Everything is now cached: no further upstream calls.
+    calls := upstream.calls
+    cached.GetByIDs(ctx, []string{"c", "a"})
+    if upstream.calls != calls {
+        t.Errorf("expected fully cached batch; got %d new upstream calls", upstream.calls-calls)
+    }
+}
+
+// TestCachedProvider_EndpointTTL verifies that an endpoint TTL override
+// expires suggestions sooner than the default TTL.
+func TestCachedProvider_EndpointTTL(t *testing.T) {
+    upstream := &countingProvider{}
+    cached := NewCachedProvider(upstream, CacheConfig{
+        TTL:         time.Minute,
+        EndpointTTL: map[string]time.Duration{"autocomplete": 5 * time.Second},
+    })
+    now := time.Now()
+    cached.cache.now = func() time.Time { return now }
+    ctx := context.Background()
+
+    // 1) Prime both a suggestion and a related-terms entry.
+    cached.Autocomplete(ctx, AutocompleteParams{Query: "ca", Limit: 5})
+    cached.RelatedTerms(ctx, "cats")
+
+    // 2) After 10s the suggestion has expired, the default-TTL entry has not.
+    now = now.Add(10 * time.Second)
+    cached.Autocomplete(ctx, AutocompleteParams{Query: "ca", Limit: 5})
+    cached.RelatedTerms(ctx, "cats")
+    if upstream.calls != 3 {
+        t.Errorf("expected 3 upstream calls; got %d", upstream.calls)
+    }
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+    "context" // cache outcomes travel back to handlers via the request context
+    "time"    // entry age
+)
+
+// Cache statuses reported through CacheInfo.Status; handlers echo them in X-Cache.
+const (
+    CacheHit   = "HIT"   // served fresh from the cache
+    CacheMiss  = "MISS"  // fetched from upstream (and cached)
+    CacheStale = "STALE" // served past its TTL
+)
+
+// CacheInfo describes how the CachedProvider answered a call.
+type CacheInfo struct {
+    // Status is one of CacheHit, CacheMiss or CacheStale; empty when no cache was involved.
+    Status string
+
+    // Age is how long ago the served value was fetched from upstream.
+    Age time.Duration
+
+    // RevalidationFailed is set when a stale value was served because the upstream call failed.
+    RevalidationFailed bool
+}
+
+// cacheInfoKey is the context key under which a *CacheInfo is stored.
+type cacheInfoKey struct{}
+
+// WithCacheInfo returns a context that lets the CachedProvider report how a
+// call was served, and the *CacheInfo that will receive the report. Provider
+// return types stay unchanged; handlers that care read the info afterwards.
+func WithCacheInfo(ctx context.Context) (context.Context, *CacheInfo) {
+    info := &CacheInfo{}
+    return context.WithValue(ctx, cacheInfoKey{}, info), info
+}
+
+// recordCacheInfo stores info in the *CacheInfo attached to ctx, if any.
+func recordCacheInfo(ctx context.Context, info CacheInfo) {
+    if dst, ok := ctx.Value(cacheInfoKey{}).(*CacheInfo); ok {
+        *dst = info
+    }
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+    "context" // shared calls get their own cancellable context
+    "sync"    // guards the in-flight call table
+)
+
+// flightGroup deduplicates identical concurrent upstream calls. The first
+// caller for a key starts the call; later callers with the same key wait
+// for it and share its result instead of issuing their own request.
+//
+// The shared call is detached from any single caller's cancellation and is
+// only cancelled once every waiter has given up, so one impatient client
+// cannot fail the request for everybody else. It does inherit the first
+// caller's deadline, which bounds retries inside the call.
+type flightGroup struct {
+    mu    sync.Mutex
+    calls map[string]*flightCall // key -> in-flight call
+}
+
+// flightCall is one in-flight upstream call and its eventual result.
+type flightCall struct {
+    done    chan struct{}      // closed once body/err are set
+    body    []byte             // shared result body (read-only)
+    err     error              // shared result error
+    waiters int                // callers still waiting; guarded by flightGroup.mu
+    cancel  context.CancelFunc // cancels the shared call
+}
+
+// newFlightGroup returns an empty flightGroup.
+func newFlightGroup() *flightGroup {
+    return &flightGroup{calls: make(map[string]*flightCall)}
+}
+
+// do runs fn for key, or joins an identical call already in flight.
+// endpoint labels the coalescing metric. The returned body is shared
+// between callers and must not be modified.
+func (g *flightGroup) do(ctx context.Context, endpoint, key string, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+    g.mu.Lock()
+
+    // 1) Someone is already fetching this: wait for their result.
+    if call, ok := g.calls[key]; ok {
+        call.waiters++
+        g.mu.Unlock()
+        upstreamCoalescedTotal.WithLabelValues(endpoint).Inc()
+        return g.wait(ctx, key, call)
+    }
+
+    // 2) Otherwise start the call on a context that keeps the caller's
+    //    values and deadline but is cancelled only when all waiters are gone.
+    var (
+        callCtx context.Context
+        cancel  context.CancelFunc
+    )
+    if deadline, ok := ctx.Deadline(); ok {
+        callCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+    } else {
+        callCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+    }
+    call := &flightCall{done: make(chan struct{}), waiters: 1, cancel: cancel}
+    g.calls[key] = call
+    g.mu.Unlock()
+
+    go func() {
+        call.body, call.err = fn(callCtx)
+        cancel()
+
+        // Forget the call before publishing, so later callers start fresh.
+        g.mu.Lock()
+        if g.calls[key] == call {
+            delete(g.calls, key)
+        }
+        g.mu.Unlock()
+        close(call.done)
+    }()
+
+    return g.wait(ctx, key, call)
+}
+
+// wait blocks until call finishes or ctx is done. The last waiter to give
+// up cancels the shared call and removes it so nobody joins a doomed request.
+func (g *flightGroup) wait(ctx context.Context, key string, call *flightCall) ([]byte, error) {
+    select {
+    case <-call.done:
+        return call.body, call.err
+    case <-ctx.Done():
+        g.mu.Lock()
+        call.waiters--
+        if call.waiters == 0 {
+            call.cancel()
+            if g.calls[key] == call {
+                delete(g.calls, key)
+            }
+        }
+        g.mu.Unlock()
+        return nil, ctx.Err()
+    }
+}
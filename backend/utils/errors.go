@@ -0,0 +1,126 @@
+package utils
+
+import (
+    "encoding/json" // for decoding Giphy's error body
+    "fmt"           // for formatting error messages
+    "io"            // for bounding how much of the body we read
+    "net/http"      // for status codes and headers
+    "strconv"       // for parsing Retry-After seconds
+    "time"          // for Retry-After durations
+)
+
+// UpstreamError describes a non-2xx response from Giphy.
+// Handlers inspect StatusCode to map rate limits, auth failures and
+// outages to distinct responses rather than a generic 500.
+type UpstreamError struct {
+    // StatusCode is the HTTP status Giphy responded with.
+    StatusCode int
+
+    // Message is Giphy's human-readable error ("meta.msg" or "message").
+    Message string
+
+    // ResponseID is Giphy's "meta.response_id", useful when reporting issues upstream.
+    ResponseID string
+
+    // RetryAfter is the delay suggested by a Retry-After header, if any.
+    RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *UpstreamError) Error() string {
+    if e.ResponseID != "" {
+        return fmt.Sprintf("giphy: %d %s (response_id=%s)", e.StatusCode, e.Message, e.ResponseID)
+    }
+    return fmt.Sprintf("giphy: %d %s", e.StatusCode, e.Message)
+}
+
+// IsRateLimited reports whether Giphy rejected the call for exceeding quota.
+func (e *UpstreamError) IsRateLimited() bool {
+    return e.StatusCode == http.StatusTooManyRequests
+}
+
+// IsAuth reports whether Giphy rejected our API key.
+func (e *UpstreamError) IsAuth() bool {
+    return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// IsNotFound reports whether the requested resource does not exist upstream.
+func (e *UpstreamError) IsNotFound() bool {
+    return e.StatusCode == http.StatusNotFound
+}
+
+// IsServerError reports whether Giphy itself failed (5xx).
+func (e *UpstreamError) IsServerError() bool {
+    return e.StatusCode >= 500
+}
+
+// PartialError accompanies a non-empty batch result when some items could
+// not be fetched because the upstream call failed. Err is that failure; any
+// requested item missing from the result should be reported with it.
+type PartialError struct {
+    Err error
+}
+
+// Error implements the error interface.
+func (e *PartialError) Error() string {
+    return "partial result: " + e.Err.Error()
+}
+
+// Unwrap exposes the underlying upstream failure to errors.Is/As.
+func (e *PartialError) Unwrap() error {
+    return e.Err
+}
+
+// giphyErrorBody covers the error shapes Giphy returns: the usual
+// {"meta":{...}} envelope and the bare {"message":"..."} used for auth errors.
+type giphyErrorBody struct {
+    Meta struct {
+        Status     int    `json:"status"`
+        Msg        string `json:"msg"`
+        ResponseID string `json:"response_id"`
+    } `json:"meta"`
+    Message string `json:"message"`
+}
+
+// maxErrorBody caps how much of an error body we read; error payloads are tiny.
+const maxErrorBody = 64 << 10
+
+// newUpstreamError builds an *UpstreamError from a non-2xx response.
+// The body is decoded best-effort; if it is not JSON we fall back to the status text.
+func newUpstreamError(resp *http.Response) *UpstreamError {
+    // 1) Start with what the status line tells us.
+    upErr := &UpstreamError{
+        StatusCode: resp.StatusCode,
+        Message:    http.StatusText(resp.StatusCode),
+        RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+    }
+
+    // 2) Prefer Giphy's own message and response ID when present.
+    var body giphyErrorBody
+    if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&body); err == nil {
+        if body.Meta.Msg != "" {
+            upErr.Message = body.Meta.Msg
+        } else if body.Message != "" {
+            upErr.Message = body.Message
+        }
+        upErr.ResponseID = body.Meta.ResponseID
+    }
+    return upErr
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: delay-seconds
+// or an HTTP date. Unparseable or past values yield zero.
+func parseRetryAfter(v string) time.Duration {
+    if v == "" {
+        return 0
+    }
+    if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+        return time.Duration(secs) * time.Second
+    }
+    if t, err := http.ParseTime(v); err == nil {
+        if d := time.Until(t); d > 0 {
+            return d
+        }
+    }
+    return 0
+}
@@ -1,74 +1,358 @@
 package utils
 
 import (
+    "context"       // request-scoped cancellation for upstream calls
     "encoding/json" // for decoding JSON responses
-    "fmt"           // for building URLs with fmt.Sprintf
+    "errors"        // for recognising quota errors
+    "io"            // for reading response bodies
     "net/http"      // for making HTTP requests
-    "os"            // for reading environment variables
+    "net/url"       // for building and escaping upstream URLs
+    "strconv"       // for formatting numeric query values
+    "strings"       // for joining path segments
+    "time"          // per-request timeouts
 )
 
-// baseURL is the Giphy API endpoint root. 
-// We declare it as a var so tests can override it if needed.
-var baseURL = "https://api.giphy.com/v1/gifs"
+// Defaults applied by NewGiphyClient when a GiphyConfig field is left empty.
+const (
+    // DefaultGiphyBaseURL is the Giphy API root; the media type ("gifs" or
+    // "stickers") is appended per call.
+    DefaultGiphyBaseURL = "https://api.giphy.com/v1"
 
-// FetchTrending retrieves the current trending GIFs from Giphy.
+    // DefaultGiphyTimeout bounds a single upstream request, including reading the body.
+    DefaultGiphyTimeout = 10 * time.Second
+
+    // DefaultUserAgent identifies this service to Giphy.
+    DefaultUserAgent = "gif-explorer-backend/1.0"
+)
+
+// GiphyConfig holds everything a GiphyClient needs to talk to Giphy.
+// Only APIKey is required; zero values fall back to the defaults above.
+type GiphyConfig struct {
+    // APIKey is the Giphy API key sent with every request.
+    APIKey string
+
+    // BaseURL is the API root, e.g. "https://api.giphy.com/v1". A trailing
+    // "/gifs" (the documented value before stickers were supported) is
+    // dropped. Tests point this at an httptest.Server.
+    BaseURL string
+
+    // HTTPClient performs the requests. When nil a dedicated client is created
+    // so we never share (or mutate) http.DefaultClient.
+    HTTPClient *http.Client
+
+    // Timeout bounds each upstream request. It is applied on top of the
+    // caller's context, so whichever deadline is earlier wins.
+    Timeout time.Duration
+
+    // UserAgent is sent in the User-Agent header.
+    UserAgent string
+
+    // Retry controls retries of failed GETs; zero fields use the defaults.
+    Retry RetryPolicy
+
+    // RateLimit keeps us under the API key's quota. Clients sharing a key
+    // share one budget. A zero Limit disables it.
+    RateLimit RateLimitConfig
+}
+
+// GiphyClient is the Provider implementation backed by the Giphy API.
+// It is safe for concurrent use by multiple handlers.
+type GiphyClient struct {
+    apiKey     string        // Giphy API key
+    baseURL    string        // API root without media type or trailing slash
+    httpClient *http.Client  // client used for all upstream calls
+    timeout    time.Duration // per-request timeout
+    userAgent  string        // User-Agent header value
+    flights    *flightGroup  // coalesces identical in-flight requests
+    retry      RetryPolicy   // retry/backoff policy for upstream GETs
+    limiter    *TokenBucket  // per-key request budget; nil when disabled
+    maxWait    time.Duration // how long to queue for a token
+}
+
+// NewGiphyClient returns a GiphyClient configured from cfg, ready to be
+// injected into the handlers.
+func NewGiphyClient(cfg GiphyConfig) *GiphyClient {
+    // 1) Fill in defaults for anything the caller left empty.
+    if cfg.BaseURL == "" {
+        cfg.BaseURL = DefaultGiphyBaseURL
+    }
+    cfg.BaseURL = strings.TrimSuffix(strings.TrimSuffix(cfg.BaseURL, "/"), "/gifs")
+    if cfg.Timeout <= 0 {
+        cfg.Timeout = DefaultGiphyTimeout
+    }
+    if cfg.UserAgent == "" {
+        cfg.UserAgent = DefaultUserAgent
+    }
+    if cfg.HTTPClient == nil {
+        // The client-level timeout is a backstop; the context deadline set
+        // per request is what normally fires first.
+        cfg.HTTPClient = &http.Client{Timeout: cfg.Timeout}
+    }
+
+    // 2) Build the client.
+    return &GiphyClient{
+        apiKey:     cfg.APIKey,
+        baseURL:    cfg.BaseURL,
+        httpClient: cfg.HTTPClient,
+        timeout:    cfg.Timeout,
+        userAgent:  cfg.UserAgent,
+        flights:    newFlightGroup(),
+        retry:      cfg.Retry.withDefaults(),
+        limiter:    limiterForKey(cfg.APIKey, cfg.RateLimit),
+        maxWait:    cfg.RateLimit.MaxWait,
+    }
+}
+
+// Compile-time check that GiphyClient satisfies Provider.
+var _ Provider = (*GiphyClient)(nil)
+
+// Trending retrieves the current trending GIFs from Giphy.
 // It returns a typed GiphyResponse or an error.
-func FetchTrending(limit, page int) (GiphyResponse, error) {
-    // 1) Calculate pagination offset.
-    offset := (page - 1) * limit
-
-    // 2) Build the request URL including API key, limit, offset, and a 'g' rating filter.
-    url := fmt.Sprintf(
-        "%s/trending?api_key=%s&limit=%d&offset=%d&rating=g",
-        baseURL,
-        os.Getenv("GIPHY_API_KEY"), // read API key at runtime
-        limit,
-        offset,
-    )
-
-    // 3) Perform the HTTP GET request.
-    resp, err := http.Get(url)
-    if err != nil {
-        // Network or DNS error: bubble up to caller.
-        return GiphyResponse{}, err
+func (c *GiphyClient) Trending(ctx context.Context, params TrendingParams) (GiphyResponse, error) {
+    // 1) Build the query: limit, offset, and the optional filters.
+    q := url.Values{}
+    q.Set("limit", strconv.Itoa(params.Limit))
+    q.Set("offset", strconv.Itoa(params.Offset))
+    if params.Rating != "" {
+        q.Set("rating", params.Rating) // content rating filter
+    }
+    if params.Region != "" {
+        q.Set("country_code", params.Region) // localize trends
     }
-    // Ensure the response body is closed after decoding.
-    defer resp.Body.Close()
 
-    // 4) Decode the JSON response into our typed struct.
+    // 2) Perform the request and decode the JSON response into our typed struct.
     var result GiphyResponse
-    err = json.NewDecoder(resp.Body).Decode(&result)
+    err := c.getJSON(ctx, "trending", c.endpoint(q, string(params.Type.orDefault()), "trending"), &result)
     // Return the decoded struct (even if err is non-nil, so caller sees partial data).
     return result, err
 }
 
-// SearchGIFs queries Giphy for GIFs matching the given search term.
+// Search queries Giphy for GIFs matching the given search term.
 // It accepts a rating filter, pagination parameters, and returns a GiphyResponse.
-func SearchGIFs(query, rating string, limit, page int) (GiphyResponse, error) {
-    // 1) Calculate pagination offset.
-    offset := (page - 1) * limit
-
-    // 2) Build the search URL with API key, escaped query, limit, offset, and rating.
-    url := fmt.Sprintf(
-        "%s/search?api_key=%s&q=%s&limit=%d&offset=%d&rating=%s",
-        baseURL,
-        os.Getenv("GIPHY_API_KEY"), // fetch API key
-        query,                      // search term (assumed already URL-escaped by caller if needed)
-        limit,
-        offset,
-        rating,                     // content rating filter
-    )
-
-    // 3) Perform the HTTP GET request against Giphy’s search endpoint.
-    resp, err := http.Get(url)
-    if err != nil {
-        // Return zero-value struct and the error.
-        return GiphyResponse{}, err
+func (c *GiphyClient) Search(ctx context.Context, params SearchParams) (GiphyResponse, error) {
+    // 1) Build the query. url.Values escapes the raw search term, so
+    //    characters like "&" or "#" cannot corrupt the request.
+    q := url.Values{}
+    q.Set("q", params.Query)
+    q.Set("limit", strconv.Itoa(params.Limit))
+    q.Set("offset", strconv.Itoa(params.Offset))
+    if params.Rating != "" {
+        q.Set("rating", params.Rating) // content rating filter
+    }
+    if params.Lang != "" {
+        q.Set("lang", params.Lang) // language of the query
     }
-    defer resp.Body.Close()
 
-    // 4) Decode JSON into our typed response struct.
+    // 2) Perform the request against Giphy’s search endpoint and decode it.
     var result GiphyResponse
-    err = json.NewDecoder(resp.Body).Decode(&result)
+    err := c.getJSON(ctx, "search", c.endpoint(q, string(params.Type.orDefault()), "search"), &result)
     return result, err
 }
+
+// GetByID fetches a single GIF by its Giphy ID. Stickers share the GIF ID
+// space, so /gifs/{id} serves both; Gif.Type tells them apart.
+func (c *GiphyClient) GetByID(ctx context.Context, id string) (Gif, error) {
+    // 1) Build the by-id URL; the ID is path-escaped by endpoint.
+    //    Giphy wraps the single GIF in a "data" object.
+    var result GiphySingleResponse
+    err := c.getJSON(ctx, "gif_by_id", c.endpoint(nil, string(MediaGIFs), id), &result)
+
+    // 2) Unwrap the GIF.
+    return result.Data, err
+}
+
+// GetByIDs fetches several GIFs or stickers in one request via Giphy's
+// /gifs?ids= endpoint. Unknown IDs are silently dropped by Giphy, so the
+// result may be shorter than ids.
+func (c *GiphyClient) GetByIDs(ctx context.Context, ids []string) ([]Gif, error) {
+    // 1) Giphy expects a single comma-separated "ids" parameter on the collection root.
+    q := url.Values{}
+    q.Set("ids", strings.Join(ids, ","))
+
+    // 2) Perform the request and return the array of found GIFs.
+    var result GiphyResponse
+    err := c.getJSON(ctx, "gifs_by_ids", c.endpoint(q, string(MediaGIFs)), &result)
+    return result.Data, err
+}
+
+// Random fetches one random GIF, optionally filtered by tag and rating.
+// Identical concurrent calls are coalesced like any other GET, so callers
+// asking at the same instant may share the same pick.
+func (c *GiphyClient) Random(ctx context.Context, params RandomParams) (Gif, error) {
+    // 1) Build the query; both filters are optional.
+    q := url.Values{}
+    if params.Tag != "" {
+        q.Set("tag", params.Tag)
+    }
+    if params.Rating != "" {
+        q.Set("rating", params.Rating)
+    }
+
+    // 2) Perform the request and unwrap the single GIF.
+    var result GiphySingleResponse
+    if err := c.getJSON(ctx, "random", c.endpoint(q, string(params.Type.orDefault()), "random"), &result); err != nil {
+        return Gif{}, err
+    }
+    return singleResult(result, "No GIF matched the tag")
+}
+
+// Translate asks Giphy for the single GIF that best matches a phrase.
+// Weirdness (0-10) trades literal matches for more unexpected ones.
+func (c *GiphyClient) Translate(ctx context.Context, params TranslateParams) (Gif, error) {
+    // 1) Build the query: Giphy calls the phrase "s".
+    q := url.Values{}
+    q.Set("s", params.Phrase)
+    q.Set("weirdness", strconv.Itoa(params.Weirdness))
+    if params.Rating != "" {
+        q.Set("rating", params.Rating) // content rating filter
+    }
+
+    // 2) Perform the request and unwrap the single GIF.
+    var result GiphySingleResponse
+    if err := c.getJSON(ctx, "translate", c.endpoint(q, string(params.Type.orDefault()), "translate"), &result); err != nil {
+        return Gif{}, err
+    }
+    return singleResult(result, "No GIF matched the phrase")
+}
+
+// Autocomplete suggests search terms completing params.Query, via Giphy's
+// /gifs/search/tags endpoint.
+func (c *GiphyClient) Autocomplete(ctx context.Context, params AutocompleteParams) ([]Tag, error) {
+    // 1) Build the query: the partial term and how many suggestions we want.
+    q := url.Values{}
+    q.Set("q", params.Query)
+    q.Set("limit", strconv.Itoa(params.Limit))
+
+    // 2) Perform the request and return the suggested tags.
+    var result TagsResponse
+    err := c.getJSON(ctx, "autocomplete", c.endpoint(q, string(MediaGIFs), "search", "tags"), &result)
+    return result.Data, err
+}
+
+// RelatedTerms returns search terms related to term, via Giphy's
+// /tags/related/{term} endpoint. The term is path-escaped by endpoint.
+func (c *GiphyClient) RelatedTerms(ctx context.Context, term string) ([]Tag, error) {
+    var result TagsResponse
+    err := c.getJSON(ctx, "related", c.endpoint(nil, "tags", "related", term), &result)
+    return result.Data, err
+}
+
+// Categories fetches Giphy's top-level GIF categories.
+func (c *GiphyClient) Categories(ctx context.Context) ([]Category, error) {
+    var result CategoriesResponse
+    err := c.getJSON(ctx, "categories", c.endpoint(nil, string(MediaGIFs), "categories"), &result)
+    return result.Data, err
+}
+
+// Subcategories fetches the subcategories of one category by its encoded
+// name. The name is path-escaped by endpoint.
+func (c *GiphyClient) Subcategories(ctx context.Context, category string) ([]Category, error) {
+    var result CategoriesResponse
+    err := c.getJSON(ctx, "subcategories", c.endpoint(nil, string(MediaGIFs), "categories", category), &result)
+    return result.Data, err
+}
+
+// singleResult unwraps a single-GIF response. Giphy answers 200 with empty
+// data when nothing matched; that is reported as a 404 UpstreamError so it
+// is handled like any other missing GIF (and not counted by the breaker).
+func singleResult(result GiphySingleResponse, notFound string) (Gif, error) {
+    if result.Data.ID == "" {
+        return Gif{}, &UpstreamError{StatusCode: http.StatusNotFound, Message: notFound}
+    }
+    return result.Data, nil
+}
+
+// endpoint builds an absolute upstream URL from the base URL, the given
+// path segments (each path-escaped) and query values. The API key is added
+// here so individual methods never format it into strings themselves.
+func (c *GiphyClient) endpoint(query url.Values, segments ...string) string {
+    // 1) Escape every segment so IDs or terms cannot inject extra path parts.
+    escaped := make([]string, len(segments))
+    for i, seg := range segments {
+        escaped[i] = url.PathEscape(seg)
+    }
+
+    // 2) Attach the API key to a copy of the caller's query values.
+    q := url.Values{}
+    for k, v := range query {
+        q[k] = v
+    }
+    q.Set("api_key", c.apiKey)
+
+    // 3) Join base URL, path and encoded query.
+    path := c.baseURL
+    if len(escaped) > 0 {
+        path += "/" + strings.Join(escaped, "/")
+    }
+    return path + "?" + q.Encode()
+}
+
+// getJSON performs a GET against rawURL and decodes the JSON body into out.
+// Identical concurrent calls are coalesced: only one upstream request runs
+// and every caller decodes its own copy of the shared body. endpoint names
+// the upstream endpoint for metrics. Non-2xx responses are returned as *UpstreamError.
+func (c *GiphyClient) getJSON(ctx context.Context, endpoint, rawURL string, out interface{}) error {
+    // 1) Fetch the body, sharing the request with any identical in-flight call.
+    //    Transient failures are retried inside the shared call, so waiters
+    //    don't each start their own retry loop.
+    body, err := c.flights.do(ctx, endpoint, rawURL, func(ctx context.Context) ([]byte, error) {
+        return c.retry.withRetry(ctx, endpoint, func(ctx context.Context) ([]byte, error) {
+            return c.fetch(ctx, rawURL)
+        })
+    })
+    if err != nil {
+        return err
+    }
+
+    // 2) Decode into the caller's struct; each waiter gets an independent value.
+    return json.Unmarshal(body, out)
+}
+
+// maxResponseBody caps how much of a successful upstream body we buffer.
+const maxResponseBody = 16 << 20 // 16 MiB
+
+// fetch performs a single GET against rawURL and returns the raw body.
+// The request is bound to ctx (plus the client's timeout), so a cancelled
+// handler or a hung connection aborts the upstream call instead of blocking.
+func (c *GiphyClient) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+    // 0) Spend one token of the API key's budget, queueing briefly if allowed.
+    //    Every attempt counts, since Giphy bills retries too.
+    if c.limiter != nil {
+        if err := c.limiter.Wait(ctx, c.maxWait); err != nil {
+            if errors.Is(err, ErrQuotaExhausted) {
+                rateLimitRejectedTotal.WithLabelValues(keyID(c.apiKey)).Inc()
+            }
+            return nil, err
+        }
+    }
+
+    // 1) Derive a context that also expires after the per-request timeout.
+    ctx, cancel := context.WithTimeout(ctx, c.timeout)
+    defer cancel()
+
+    // 2) Build a request that carries that context and our User-Agent.
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("User-Agent", c.userAgent)
+    req.Header.Set("Accept", "application/json")
+
+    // 3) Perform the HTTP GET request with our dedicated client.
+    resp, err := c.httpClient.Do(req)
+    if err != nil {
+        // Network or DNS error: bubble up to caller.
+        return nil, err
+    }
+    // Ensure the response body is closed after reading.
+    defer resp.Body.Close()
+
+    // 4) Anything outside 2xx is an upstream failure; decode Giphy's
+    //    error body into a typed error instead of the data struct.
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return nil, newUpstreamError(resp)
+    }
+
+    // 5) Buffer the body so it can be shared by coalesced callers.
+    return io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+}
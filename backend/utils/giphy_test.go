@@ -1,15 +1,23 @@
 package utils
 
 import (
+    "context"             // Provider methods take a request context
     "encoding/json"       // to encode our fake data as JSON
+    "errors"              // for unwrapping typed errors
     "net/http"            // for HTTP status and request types
     "net/http/httptest"   // to create a fake HTTP server
-    "os"                  // to set/unset environment variables
+    "net/url"             // to rebuild the coalescing key
+    "strings"             // for inspecting error messages
+    "sync"                // to run concurrent callers
+    "sync/atomic"         // to count upstream hits safely
     "testing"             // Go’s testing framework
+    "time"                // for timeout configuration
+
+    "github.com/prometheus/client_golang/prometheus/testutil" // reading attempt counters
 )
 
-// TestFetchTrending verifies that FetchTrending correctly calls the Giphy API,
-// passes along the API key, and parses the JSON into our typed GiphyResponse.
+// TestFetchTrending verifies that GiphyClient.Trending correctly calls the Giphy API,
+// passes along the configured API key, and parses the JSON into our typed GiphyResponse.
 func TestFetchTrending(t *testing.T) {
     // 1) Prepare fake Giphy JSON payload with one GIF and pagination info.
     fakeData := map[string]interface{}{
@@ -37,37 +45,37 @@ func TestFetchTrending(t *testing.T) {
         if r.URL.Path != "/v1/gifs/trending" {
             t.Errorf("unexpected path: %s", r.URL.Path)
         }
-        // 2b) Ensure the API key query parameter is non-empty.
-        if key := r.URL.Query().Get("api_key"); key == "" {
-            t.Error("expected api_key query param")
+        // 2b) Ensure the configured API key and User-Agent are sent.
+        if key := r.URL.Query().Get("api_key"); key != "test-key" {
+            t.Errorf("expected api_key=test-key; got %q", key)
+        }
+        if ua := r.Header.Get("User-Agent"); ua != DefaultUserAgent {
+            t.Errorf("expected User-Agent %q; got %q", DefaultUserAgent, ua)
         }
         // 2c) Write our fake JSON payload to the response.
         json.NewEncoder(w).Encode(fakeData)
     }))
     defer server.Close() // shut down server when test completes
 
-    // 3) Override the package-level baseURL so FetchTrending points at our fake server.
-    oldBase := baseURL
-    baseURL = server.URL + "/v1/gifs"
-    defer func() { baseURL = oldBase }() // restore original baseURL after test
+    // 3) Build a client pointed at our fake server with a dummy API key.
+    client := NewGiphyClient(GiphyConfig{
+        APIKey:  "test-key",
+        BaseURL: server.URL + "/v1/gifs",
+    })
 
-    // 4) Set a dummy GIPHY_API_KEY so FetchTrending includes it in requests.
-    os.Setenv("GIPHY_API_KEY", "test-key")
-    defer os.Unsetenv("GIPHY_API_KEY") // clean up afterwards
-
-    // 5) Call the function under test
-    resp, err := FetchTrending(5, 1)
+    // 4) Call the function under test
+    resp, err := client.Trending(context.Background(), TrendingParams{Limit: 5})
     if err != nil {
-        t.Fatalf("FetchTrending error: %v", err)
+        t.Fatalf("Trending error: %v", err)
     }
 
-    // 6) Assert that one GIF was returned
+    // 5) Assert that one GIF was returned
     if len(resp.Data) != 1 {
         t.Fatalf("expected 1 GIF; got %d", len(resp.Data))
     }
     gif := resp.Data[0]
 
-    // 7) Validate that the fields were parsed correctly
+    // 6) Validate that the fields were parsed correctly
     if gif.ID != "abc123" {
         t.Errorf("expected ID 'abc123'; got %q", gif.ID)
     }
@@ -81,8 +89,379 @@ func TestFetchTrending(t *testing.T) {
         )
     }
 
-    // 8) Verify pagination metadata
+    // 7) Verify pagination metadata
     if resp.Pagination.TotalCount != 1 {
         t.Errorf("expected total_count=1; got %d", resp.Pagination.TotalCount)
     }
 }
+
+// TestGiphyClient_Timeout verifies that a hung upstream connection is abandoned
+// once the configured per-request timeout elapses.
+func TestGiphyClient_Timeout(t *testing.T) {
+    // 1) Fake server that never answers until the test finishes.
+    release := make(chan struct{})
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-release:
+        case <-r.Context().Done():
+        }
+    }))
+    defer server.Close()
+    defer close(release)
+
+    // 2) Client with a short timeout.
+    client := NewGiphyClient(GiphyConfig{
+        APIKey:  "test-key",
+        BaseURL: server.URL,
+        Timeout: 50 * time.Millisecond,
+    })
+
+    // 3) The call must fail quickly with a deadline error rather than hang.
+    start := time.Now()
+    _, err := client.Trending(context.Background(), TrendingParams{Limit: 1})
+    if err == nil {
+        t.Fatal("expected timeout error; got nil")
+    }
+    if !strings.Contains(err.Error(), "deadline exceeded") {
+        t.Errorf("expected deadline error; got %v", err)
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("request took %s; timeout not applied", elapsed)
+    }
+}
+
+// TestSearch_EscapesQuery verifies that special characters in the search term
+// reach Giphy intact instead of being interpreted as URL syntax.
+func TestSearch_EscapesQuery(t *testing.T) {
+    const term = "cats & dogs #1"
+
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // The decoded q must equal the raw term, and no stray params may appear.
+        if got := r.URL.Query().Get("q"); got != term {
+            t.Errorf("expected q=%q; got %q", term, got)
+        }
+        if _, ok := r.URL.Query()[" dogs "]; ok {
+            t.Error("query term leaked into a separate parameter")
+        }
+        if got := r.URL.Query().Get("lang"); got != "es" {
+            t.Errorf("expected lang=es to be forwarded; got %q", got)
+        }
+        json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{}})
+    }))
+    defer server.Close()
+
+    client := NewGiphyClient(GiphyConfig{APIKey: "test-key", BaseURL: server.URL})
+    if _, err := client.Search(context.Background(), SearchParams{Query: term, Lang: "es", Limit: 5}); err != nil {
+        t.Fatalf("Search error: %v", err)
+    }
+}
+
+// TestGiphyClient_UpstreamError verifies that a non-2xx response is decoded
+// into an *UpstreamError carrying Giphy's status, message and response ID.
+func TestGiphyClient_UpstreamError(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Retry-After", "7")
+        w.WriteHeader(http.StatusTooManyRequests)
+        w.Write([]byte(`{"meta":{"status":429,"msg":"API rate limit exceeded","response_id":"r-42"}}`))
+    }))
+    defer server.Close()
+
+    // Retries are disabled so the 429 is returned straight away.
+    client := NewGiphyClient(GiphyConfig{
+        APIKey:  "test-key",
+        BaseURL: server.URL,
+        Retry:   RetryPolicy{MaxAttempts: 1},
+    })
+    _, err := client.Trending(context.Background(), TrendingParams{Limit: 5})
+
+    // 1) The error must be an *UpstreamError.
+    var upErr *UpstreamError
+    if !errors.As(err, &upErr) {
+        t.Fatalf("expected *UpstreamError; got %T (%v)", err, err)
+    }
+
+    // 2) Its fields must reflect the upstream response.
+    if !upErr.IsRateLimited() {
+        t.Errorf("expected rate-limited error; got status %d", upErr.StatusCode)
+    }
+    if upErr.Message != "API rate limit exceeded" || upErr.ResponseID != "r-42" {
+        t.Errorf("unexpected message/response_id: %q / %q", upErr.Message, upErr.ResponseID)
+    }
+    if upErr.RetryAfter != 7*time.Second {
+        t.Errorf("expected RetryAfter=7s; got %s", upErr.RetryAfter)
+    }
+}
+
+// TestGiphyClient_CoalescesIdenticalCalls verifies that identical concurrent
+// calls share one upstream request and each receive the result.
+func TestGiphyClient_CoalescesIdenticalCalls(t *testing.T) {
+    const callers = 5
+    var hits int32
+    arrived := make(chan struct{})
+    release := make(chan struct{})
+
+    // 1) Fake server that counts hits and holds the first request until released.
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if atomic.AddInt32(&hits, 1) == 1 {
+            close(arrived)
+        }
+        <-release
+        json.NewEncoder(w).Encode(map[string]interface{}{
+            "data": []map[string]interface{}{{"id": "shared"}},
+        })
+    }))
+    defer server.Close()
+
+    client := NewGiphyClient(GiphyConfig{APIKey: "test-key", BaseURL: server.URL})
+    params := TrendingParams{Limit: 5}
+
+    // 2) Start all callers; the first one reaches the server.
+    var wg sync.WaitGroup
+    results := make([]GiphyResponse, callers)
+    errs := make([]error, callers)
+    for i := 0; i < callers; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            results[i], errs[i] = client.Trending(context.Background(), params)
+        }(i)
+    }
+    <-arrived
+
+    // 3) Wait until every caller has joined the in-flight call, then release it.
+    key := client.endpoint(url.Values{"limit": {"5"}, "offset": {"0"}}, "gifs", "trending")
+    for deadline := time.Now().Add(2 * time.Second); ; {
+        client.flights.mu.Lock()
+        call := client.flights.calls[key]
+        joined := call != nil && call.waiters == callers
+        client.flights.mu.Unlock()
+        if joined {
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatal("callers never joined the in-flight request")
+        }
+        time.Sleep(time.Millisecond)
+    }
+    close(release)
+    wg.Wait()
+
+    // 4) One upstream hit; every caller got the GIF.
+    if n := atomic.LoadInt32(&hits); n != 1 {
+        t.Errorf("expected 1 upstream request; got %d", n)
+    }
+    for i := range results {
+        if errs[i] != nil || len(results[i].Data) != 1 || results[i].Data[0].ID != "shared" {
+            t.Errorf("caller %d: unexpected result %+v (err %v)", i, results[i], errs[i])
+        }
+    }
+}
+
+// TestGiphyClient_RetriesTransientFailures verifies that a 503 followed by a
+// success is retried transparently, while a 400 is returned immediately.
+func TestGiphyClient_RetriesTransientFailures(t *testing.T) {
+    var hits int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        n := atomic.AddInt32(&hits, 1)
+        switch {
+        case r.URL.Path == "/gifs/search":
+            w.WriteHeader(http.StatusBadRequest) // permanent: never retried
+        case n == 1:
+            w.WriteHeader(http.StatusServiceUnavailable) // transient: retried
+        default:
+            json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{}})
+        }
+    }))
+    defer server.Close()
+
+    client := NewGiphyClient(GiphyConfig{
+        APIKey:  "test-key",
+        BaseURL: server.URL,
+        Retry:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+    })
+
+    // 1) Transient failure, then success: two hits, no error.
+    if _, err := client.Trending(context.Background(), TrendingParams{Limit: 5}); err != nil {
+        t.Fatalf("expected success after retry; got %v", err)
+    }
+    if n := atomic.LoadInt32(&hits); n != 2 {
+        t.Fatalf("expected 2 upstream hits; got %d", n)
+    }
+
+    // 2) Permanent failure: exactly one more hit.
+    if _, err := client.Search(context.Background(), SearchParams{Query: "x", Limit: 5}); err == nil {
+        t.Fatal("expected error for 400 response")
+    }
+    if n := atomic.LoadInt32(&hits); n != 3 {
+        t.Errorf("expected 400 not to be retried; got %d hits", n)
+    }
+}
+
+// TestGiphyClient_RetryRespectsDeadline verifies that a Retry-After longer
+// than the caller's remaining deadline ends the retry loop immediately.
+func TestGiphyClient_RetryRespectsDeadline(t *testing.T) {
+    var hits int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        w.Header().Set("Retry-After", "30")
+        w.WriteHeader(http.StatusTooManyRequests)
+    }))
+    defer server.Close()
+
+    client := NewGiphyClient(GiphyConfig{APIKey: "test-key", BaseURL: server.URL})
+    ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+    defer cancel()
+
+    // The 30s Retry-After can't fit in a 500ms deadline: fail fast after one attempt.
+    start := time.Now()
+    if _, err := client.Trending(ctx, TrendingParams{Limit: 5}); err == nil {
+        t.Fatal("expected rate-limit error")
+    }
+    if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+        t.Errorf("retry loop waited %s despite the deadline", elapsed)
+    }
+    if n := atomic.LoadInt32(&hits); n != 1 {
+        t.Errorf("expected 1 attempt; got %d", n)
+    }
+}
+
+// TestGiphyClient_RetryAfterBeyondMaxDelay verifies that a Retry-After
+// longer than MaxDelay ends the retry loop even without a deadline, rather
+// than leaving the caller asleep, and that the attempt counts as a final
+// error rather than a retry.
+func TestGiphyClient_RetryAfterBeyondMaxDelay(t *testing.T) {
+    retries := upstreamAttemptsTotal.WithLabelValues("trending", "1", "retry")
+    failures := upstreamAttemptsTotal.WithLabelValues("trending", "1", "error")
+    retriesBefore, failuresBefore := testutil.ToFloat64(retries), testutil.ToFloat64(failures)
+
+    var hits int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        w.Header().Set("Retry-After", "3600")
+        w.WriteHeader(http.StatusTooManyRequests)
+    }))
+    defer server.Close()
+
+    client := NewGiphyClient(GiphyConfig{APIKey: "test-key", BaseURL: server.URL})
+    start := time.Now()
+    if _, err := client.Trending(context.Background(), TrendingParams{Limit: 5}); err == nil {
+        t.Fatal("expected rate-limit error")
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("retry loop waited %s for an hour-long Retry-After", elapsed)
+    }
+    if n := atomic.LoadInt32(&hits); n != 1 {
+        t.Errorf("expected 1 attempt; got %d", n)
+    }
+    if got := testutil.ToFloat64(retries) - retriesBefore; got != 0 {
+        t.Errorf("expected no retry recorded; got %v", got)
+    }
+    if got := testutil.ToFloat64(failures) - failuresBefore; got != 1 {
+        t.Errorf("expected 1 final error recorded; got %v", got)
+    }
+}
+
+// TestImages_DecodesRenditions verifies that Giphy's rendition objects,
+// whose numbers arrive as strings, decode into typed fields and that absent
+// renditions are omitted when re-encoded for our clients.
+func TestImages_DecodesRenditions(t *testing.T) {
+    raw := `{
+        "fixed_height": {"url": "https://example.com/fh.gif", "width": "356", "height": "200",
+                         "size": "1024", "mp4": "https://example.com/fh.mp4", "mp4_size": "512",
+                         "webp": "https://example.com/fh.webp", "webp_size": "256", "frames": "12"},
+        "original_still": {"url": "https://example.com/s.gif", "width": 480, "height": 270},
+        "preview": {"mp4": "https://example.com/p.mp4", "width": "", "height": ""}
+    }`
+
+    // 1) Decode the upstream shape.
+    var images Images
+    if err := json.Unmarshal([]byte(raw), &images); err != nil {
+        t.Fatalf("decode error: %v", err)
+    }
+    fh := images.FixedHeight
+    if fh.Width != 356 || fh.Height != 200 || fh.Size != 1024 || fh.MP4Size != 512 || fh.Frames != 12 {
+        t.Errorf("unexpected fixed_height metadata: %+v", fh)
+    }
+    if fh.WebP != "https://example.com/fh.webp" || images.OriginalStill.Width != 480 {
+        t.Errorf("unexpected webp/still fields: %+v / %+v", fh, images.OriginalStill)
+    }
+    if images.Preview.MP4 == "" || images.Preview.Width != 0 {
+        t.Errorf("unexpected preview: %+v", images.Preview)
+    }
+
+    // 2) Re-encode: numbers are numbers, missing renditions are dropped.
+    out, err := json.Marshal(images)
+    if err != nil {
+        t.Fatalf("encode error: %v", err)
+    }
+    if !strings.Contains(string(out), `"width":356`) {
+        t.Errorf("expected numeric width in %s", out)
+    }
+    if strings.Contains(string(out), `"downsized"`) {
+        t.Errorf("expected absent renditions to be omitted in %s", out)
+    }
+}
+
+// TestGiphyClient_TranslateAndRandom verifies the single-GIF endpoints: the
+// phrase and weirdness reach /translate, and an empty "data" array from
+// /random (nothing matched the tag) becomes a not-found UpstreamError.
+func TestGiphyClient_TranslateAndRandom(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/gifs/translate":
+            if r.URL.Query().Get("s") != "good morning" || r.URL.Query().Get("weirdness") != "3" {
+                t.Errorf("unexpected translate query: %s", r.URL.RawQuery)
+            }
+            w.Write([]byte(`{"data":{"id":"gm1","title":"Good Morning"}}`))
+        case "/gifs/random":
+            w.Write([]byte(`{"data":[],"meta":{"status":200}}`))
+        default:
+            t.Errorf("unexpected path %s", r.URL.Path)
+        }
+    }))
+    defer server.Close()
+    client := NewGiphyClient(GiphyConfig{APIKey: "test-key", BaseURL: server.URL})
+
+    // 1) Translate returns the single GIF object.
+    gif, err := client.Translate(context.Background(), TranslateParams{Phrase: "good morning", Weirdness: 3})
+    if err != nil || gif.ID != "gm1" {
+        t.Fatalf("unexpected translate result: %+v (err %v)", gif, err)
+    }
+
+    // 2) Random with no match reports 404.
+    _, err = client.Random(context.Background(), RandomParams{Tag: "no-such-tag"})
+    var upErr *UpstreamError
+    if !errors.As(err, &upErr) || !upErr.IsNotFound() {
+        t.Errorf("expected not-found UpstreamError; got %v", err)
+    }
+}
+
+// TestGiphyClient_Stickers verifies that the media type selects the
+// /stickers endpoints, that by-ID lookups always use /gifs and that
+// related terms use /tags.
+func TestGiphyClient_Stickers(t *testing.T) {
+    var paths []string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        paths = append(paths, r.URL.Path)
+        w.Write([]byte(`{"data":{"id":"s1","type":"sticker"}}`))
+    }))
+    defer server.Close()
+    client := NewGiphyClient(GiphyConfig{APIKey: "test-key", BaseURL: server.URL})
+
+    // 1) A sticker pick hits /stickers/random and keeps Giphy's type.
+    gif, err := client.Random(context.Background(), RandomParams{Type: MediaStickers})
+    if err != nil || gif.Type != "sticker" {
+        t.Fatalf("unexpected random result: %+v (err %v)", gif, err)
+    }
+
+    // 2) Stickers share the GIF ID space, so lookups go to /gifs/{id}.
+    client.GetByID(context.Background(), "s1")
+
+    // 3) Related terms live outside the media type, under /tags.
+    client.RelatedTerms(context.Background(), "cats & dogs")
+
+    want := []string{"/stickers/random", "/gifs/s1", "/tags/related/cats & dogs"}
+    if strings.Join(paths, " ") != strings.Join(want, " ") {
+        t.Errorf("expected paths %v; got %v", want, paths)
+    }
+}
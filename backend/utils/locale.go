@@ -0,0 +1,54 @@
+package utils
+
+import (
+    "strings" // case-insensitive matching
+)
+
+// DefaultLanguage is the language Giphy assumes when a search sends no lang.
+const DefaultLanguage = "en"
+
+// supportedLanguages are the search languages Giphy documents, keyed by
+// lower-cased code with the canonical spelling as the value.
+// See https://developers.giphy.com/docs/optional-settings/#language-support
+var supportedLanguages = func() map[string]string {
+    codes := []string{
+        "en", "es", "pt", "id", "fr", "ar", "tr", "th", "vi", "de", "it",
+        "ja", "zh-CN", "zh-TW", "ru", "ko", "pl", "nl", "ro", "hu", "sv",
+        "cs", "hi", "bn", "da", "fa", "tl", "fi", "he", "ms", "no", "uk",
+    }
+    m := make(map[string]string, len(codes))
+    for _, c := range codes {
+        m[strings.ToLower(c)] = c
+    }
+    return m
+}()
+
+// ParseLanguage validates a client-supplied language code and returns its
+// canonical spelling ("ZH-cn" becomes "zh-CN"). An empty string means
+// DefaultLanguage.
+func ParseLanguage(s string) (string, bool) {
+    if s == "" {
+        return DefaultLanguage, true
+    }
+    lang, ok := supportedLanguages[strings.ToLower(s)]
+    return lang, ok
+}
+
+// ParseRegion validates a client-supplied ISO 3166-1 alpha-2 country code
+// and returns it upper-cased. An empty string means "no region" and is valid.
+// Only the shape is checked; Giphy ignores codes it has no trends for.
+func ParseRegion(s string) (string, bool) {
+    if s == "" {
+        return "", true
+    }
+    if len(s) != 2 {
+        return "", false
+    }
+    s = strings.ToUpper(s)
+    for _, r := range s {
+        if r < 'A' || r > 'Z' {
+            return "", false
+        }
+    }
+    return s, true
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+    // Prometheus client library for metrics
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// Define Prometheus metrics for the upstream layer as package-level variables,
+// mirroring the HTTP metrics registered in handlers/middleware.go.
+
+// cacheHitsTotal counts lookups answered from the response cache,
+// labeled by upstream endpoint (trending, search, ...).
+var cacheHitsTotal = prometheus.NewCounterVec(
+    prometheus.CounterOpts{
+        Name: "gif_cache_hits_total",
+        Help: "Count of response cache hits",
+    },
+    []string{"endpoint"},
+)
+
+// cacheMissesTotal counts lookups that had to go to the upstream provider.
+var cacheMissesTotal = prometheus.NewCounterVec(
+    prometheus.CounterOpts{
+        Name: "gif_cache_misses_total",
+        Help: "Count of response cache misses",
+    },
+    []string{"endpoint"},
+)
+
+// cacheEvictionsTotal counts entries removed from the cache,
+// labeled by reason ("capacity" for LRU eviction, "expired" for TTL).
+var cacheEvictionsTotal = prometheus.NewCounterVec(
+    prometheus.CounterOpts{
+        Name: "gif_cache_evictions_total",
+        Help: "Count of response cache evictions",
+    },
+    []string{"reason"},
+)
+
+// cacheStaleServedTotal counts responses served past their TTL, labeled by
+// endpoint and reason ("revalidate" while refreshing, "error" on upstream failure).
+var cacheStaleServedTotal = prometheus.NewCounterVec(
+    prometheus.CounterOpts{
+        Name: "gif_cache_stale_served_total",
+        Help: "Count of stale responses served from the cache",
+    },
+    []string{"endpoint", "reason"},
+)
+
+// cacheEntries and cacheBytes report the current size of the cache.
+var (
+    cacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "gif_cache_entries",
+        Help: "Number of entries currently held in the response cache",
+    })
+    cacheBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+        Name: "gif_cache_bytes",
+        Help: "Approximate size in bytes of the response cache",
+    })
+)
+
+// upstreamCoalescedTotal counts upstream calls that were not made because
+// an identical request was already in flight, labeled by endpoint.
+var upstreamCoalescedTotal = prometheus.NewCounterVec(
+    prometheus.CounterOpts{
+        Name: "gif_upstream_coalesced_total",
+        Help: "Count of upstream calls coalesced into an identical in-flight request",
+    },
+    []string{"endpoint"},
+)
+
+// upstreamAttemptsTotal counts individual upstream HTTP attempts, labeled by
+// endpoint, attempt number (1 = first try) and result ("success", "retry", "error").
+var upstreamAttemptsTotal = prometheus.NewCounterVec(
+    prometheus.CounterOpts{
+        Name: "gif_upstream_attempts_total",
+        Help: "Count of upstream request attempts",
+    },
+    []string{"endpoint", "attempt", "result"},
+)
+
+// upstreamAttemptDuration tracks how long each upstream attempt took.
+var upstreamAttemptDuration = prometheus.NewHistogramVec(
+    prometheus.HistogramOpts{
+        Name:    "gif_upstream_attempt_duration_seconds",
+        Help:    "Duration of upstream request attempts",
+        Buckets: prometheus.DefBuckets, // default buckets provided by Prometheus
+    },
+    []string{"endpoint"},
+)
+
+// circuitState reports the upstream circuit breaker state
+// (0 = closed, 1 = half-open, 2 = open).
+var circuitState = prometheus.NewGauge(prometheus.GaugeOpts{
+    Name: "gif_upstream_circuit_state",
+    Help: "Upstream circuit breaker state (0=closed, 1=half-open, 2=open)",
+})
+
+// rateLimitRejectedTotal counts upstream calls refused by the client-side
+// limiter because the API key's budget was exhausted.
+var rateLimitRejectedTotal = prometheus.NewCounterVec(
+    prometheus.CounterOpts{
+        Name: "gif_upstream_ratelimit_rejected_total",
+        Help: "Count of upstream calls rejected by the client-side rate limiter",
+    },
+    []string{"key_id"},
+)
+
+// init registers the upstream metrics with Prometheus’s default registry,
+// so they are served by the same /metrics handler as the HTTP metrics.
+func init() {
+    prometheus.MustRegister(
+        cacheHitsTotal, cacheMissesTotal, cacheEvictionsTotal,
+        cacheStaleServedTotal, cacheEntries, cacheBytes,
+        upstreamCoalescedTotal, upstreamAttemptsTotal, upstreamAttemptDuration,
+        circuitState, rateLimitRejectedTotal, budgetCollector{},
+    )
+}
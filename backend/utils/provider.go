@@ -0,0 +1,152 @@
+package utils
+
+import (
+    "context" // request-scoped cancellation passed through to the upstream source
+)
+
+// Provider is the abstraction the HTTP handlers use to fetch GIFs.
+// The Giphy client is one implementation; other sources, or wrappers that
+// add caching and retries, can satisfy the same interface and be injected
+// into the handlers without any package-level globals.
+type Provider interface {
+    // Trending returns the currently trending GIFs for the given page.
+    Trending(ctx context.Context, params TrendingParams) (GiphyResponse, error)
+
+    // Search returns GIFs matching params.Query.
+    Search(ctx context.Context, params SearchParams) (GiphyResponse, error)
+
+    // GetByID returns a single GIF or sticker by its provider-specific identifier.
+    GetByID(ctx context.Context, id string) (Gif, error)
+
+    // GetByIDs returns the GIFs for ids in one call. IDs the provider doesn't
+    // know are simply absent from the result; order is not guaranteed.
+    GetByIDs(ctx context.Context, ids []string) ([]Gif, error)
+
+    // Random returns one random GIF, optionally restricted to a tag and rating.
+    Random(ctx context.Context, params RandomParams) (Gif, error)
+
+    // Translate returns the single GIF that best matches params.Phrase.
+    Translate(ctx context.Context, params TranslateParams) (Gif, error)
+
+    // Autocomplete suggests search terms that start with params.Query.
+    Autocomplete(ctx context.Context, params AutocompleteParams) ([]Tag, error)
+
+    // RelatedTerms returns search terms related to term.
+    RelatedTerms(ctx context.Context, term string) ([]Tag, error)
+
+    // Categories returns the top-level GIF categories with their subcategory names.
+    Categories(ctx context.Context) ([]Category, error)
+
+    // Subcategories returns the subcategories of the category whose encoded
+    // name is category, each with a representative GIF.
+    Subcategories(ctx context.Context, category string) ([]Category, error)
+}
+
+// MediaType selects which Giphy content family a call targets. GIFs and
+// stickers (GIFs with transparent backgrounds) have parallel endpoints.
+type MediaType string
+
+const (
+    // MediaGIFs is the regular GIF library, and the default.
+    MediaGIFs MediaType = "gifs"
+
+    // MediaStickers is the sticker library.
+    MediaStickers MediaType = "stickers"
+)
+
+// ParseMediaType validates a client-supplied media type. An empty string
+// means MediaGIFs.
+func ParseMediaType(s string) (MediaType, bool) {
+    switch MediaType(s) {
+    case "", MediaGIFs:
+        return MediaGIFs, true
+    case MediaStickers:
+        return MediaStickers, true
+    }
+    return "", false
+}
+
+// orDefault returns t, or MediaGIFs when t is unset.
+func (t MediaType) orDefault() MediaType {
+    if t == "" {
+        return MediaGIFs
+    }
+    return t
+}
+
+// TrendingParams carries the options for a Provider.Trending call.
+type TrendingParams struct {
+    // Type selects GIFs or stickers; empty means GIFs.
+    Type MediaType
+
+    // Rating is the content rating filter (optional).
+    Rating string
+
+    // Region is an ISO 3166-1 alpha-2 country code to localize trends for
+    // (optional, upper-case).
+    Region string
+
+    // Limit is the number of GIFs per page.
+    Limit int
+
+    // Offset is the zero-based index of the first GIF to return.
+    Offset int
+}
+
+// SearchParams carries the options for a Provider.Search call.
+type SearchParams struct {
+    // Type selects GIFs or stickers; empty means GIFs.
+    Type MediaType
+
+    // Query is the raw search term.
+    Query string
+
+    // Rating is the content rating filter (e.g. "g", "pg").
+    Rating string
+
+    // Lang is the language the query is written in, as returned by
+    // ParseLanguage (optional; Giphy assumes DefaultLanguage).
+    Lang string
+
+    // Limit is the number of GIFs per page.
+    Limit int
+
+    // Offset is the zero-based index of the first GIF to return.
+    Offset int
+}
+
+// RandomParams carries the options for a Provider.Random call.
+type RandomParams struct {
+    // Type selects GIFs or stickers; empty means GIFs.
+    Type MediaType
+
+    // Tag restricts the pick to GIFs with this tag (optional).
+    Tag string
+
+    // Rating is the content rating filter (optional).
+    Rating string
+}
+
+// TranslateParams carries the options for a Provider.Translate call.
+type TranslateParams struct {
+    // Type selects GIFs or stickers; empty means GIFs.
+    Type MediaType
+
+    // Phrase is the word or phrase to translate into a GIF.
+    Phrase string
+
+    // Rating is the content rating filter (optional).
+    Rating string
+
+    // Weirdness ranges from 0 (most literal) to 10 (most unexpected).
+    Weirdness int
+}
+
+// AutocompleteParams carries the options for a Provider.Autocomplete call.
+type AutocompleteParams struct {
+    // Query is the partial term typed so far.
+    Query string
+
+    // Limit is the maximum number of suggestions.
+    Limit int
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+    "context"       // waits are bounded by the caller's context
+    "crypto/sha256" // to derive a non-secret label from an API key
+    "encoding/hex"  // hex-encode the label
+    "errors"        // sentinel error
+    "fmt"           // error messages
+    "sync"          // buckets are shared by concurrent requests
+    "time"          // refill arithmetic
+
+    "github.com/prometheus/client_golang/prometheus" // budget gauge collector
+)
+
+// TokenBucket is a classic token-bucket rate limiter: it holds up to burst
+// tokens and refills at rate tokens per second. It is safe for concurrent use.
+type TokenBucket struct {
+    mu     sync.Mutex
+    rate   float64          // tokens added per second
+    burst  float64          // bucket capacity
+    tokens float64          // tokens currently available (may dip below 0 for reservations)
+    last   time.Time        // last refill time
+    now    func() time.Time // clock, replaceable in tests
+}
+
+// NewTokenBucket returns a full bucket allowing limit events per period
+// with bursts of up to burst events.
+func NewTokenBucket(limit int, per time.Duration, burst int) *TokenBucket {
+    if burst < 1 {
+        burst = 1
+    }
+    return &TokenBucket{
+        rate:   float64(limit) / per.Seconds(),
+        burst:  float64(burst),
+        tokens: float64(burst),
+        last:   time.Now(),
+        now:    time.Now,
+    }
+}
+
+// refill adds the tokens earned since the last call. Caller holds b.mu.
+func (b *TokenBucket) refill() {
+    now := b.now()
+    b.tokens += now.Sub(b.last).Seconds() * b.rate
+    if b.tokens > b.burst {
+        b.tokens = b.burst
+    }
+    b.last = now
+}
+
+// Allow takes one token if available. When the bucket is empty it returns
+// false and how long until the next token arrives.
+func (b *TokenBucket) Allow() (bool, time.Duration) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    b.refill()
+    if b.tokens >= 1 {
+        b.tokens--
+        return true, 0
+    }
+    return false, b.delayFor(1)
+}
+
+// Wait takes one token, sleeping until it is available if that takes no
+// longer than maxWait and fits within ctx's deadline. Otherwise it returns
+// immediately with a *QuotaError and consumes nothing.
+func (b *TokenBucket) Wait(ctx context.Context, maxWait time.Duration) error {
+    // 1) Reserve a token, or refuse if the wait is too long.
+    b.mu.Lock()
+    b.refill()
+    wait := b.delayFor(1)
+    if wait > maxWait {
+        b.mu.Unlock()
+        return &QuotaError{RetryAfter: wait}
+    }
+    if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+        b.mu.Unlock()
+        return &QuotaError{RetryAfter: wait}
+    }
+    b.tokens--
+    b.mu.Unlock()
+
+    // 2) Sleep until the reserved token is ours.
+    if wait <= 0 {
+        return nil
+    }
+    timer := time.NewTimer(wait)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return nil
+    case <-ctx.Done():
+        // Give the reservation back; we never used it.
+        b.mu.Lock()
+        b.tokens++
+        b.mu.Unlock()
+        return ctx.Err()
+    }
+}
+
+// Status returns the currently available budget (never negative) and how
+// long until the bucket is full again; used for RateLimit-* headers.
+func (b *TokenBucket) Status() (remaining int, untilFull time.Duration) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.refill()
+    if b.tokens > 0 {
+        remaining = int(b.tokens)
+    }
+    return remaining, b.delayFor(b.burst)
+}
+
+// Tokens returns the currently available budget.
+func (b *TokenBucket) Tokens() float64 {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.refill()
+    return b.tokens
+}
+
+// delayFor returns how long until n tokens are available. Caller holds b.mu.
+func (b *TokenBucket) delayFor(n float64) time.Duration {
+    missing := n - b.tokens
+    if missing <= 0 {
+        return 0
+    }
+    return time.Duration(missing / b.rate * float64(time.Second))
+}
+
+// ErrQuotaExhausted is matched (via errors.Is) by every *QuotaError.
+var ErrQuotaExhausted = errors.New("upstream request budget exhausted")
+
+// QuotaError is returned when the client-side limiter refuses an upstream
+// call because the API key's budget is spent.
+type QuotaError struct {
+    // RetryAfter is how long until the budget allows another call.
+    RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *QuotaError) Error() string {
+    return fmt.Sprintf("%s, retry after %s", ErrQuotaExhausted, e.RetryAfter.Round(time.Second))
+}
+
+// Unwrap lets errors.Is(err, ErrQuotaExhausted) match.
+func (e *QuotaError) Unwrap() error {
+    return ErrQuotaExhausted
+}
+
+// RateLimitConfig configures the client-side limiter protecting an API key's quota.
+type RateLimitConfig struct {
+    // Limit is the number of upstream requests allowed per Per. Zero disables limiting.
+    Limit int
+
+    // Per is the period Limit applies to, e.g. time.Hour for an hourly quota.
+    Per time.Duration
+
+    // Burst is how many requests may be made back to back. Defaults to Limit.
+    Burst int
+
+    // MaxWait is how long a request may queue for a token before being
+    // rejected with a *QuotaError. Zero rejects immediately.
+    MaxWait time.Duration
+}
+
+// keyLimiters holds one bucket per API key, so every client using the same
+// key draws from the same budget.
+var keyLimiters = struct {
+    sync.Mutex
+    buckets map[string]*TokenBucket // key ID -> bucket
+}{buckets: make(map[string]*TokenBucket)}
+
+// limiterForKey returns the shared bucket for apiKey, creating it from cfg
+// on first use, or nil when cfg disables limiting.
+func limiterForKey(apiKey string, cfg RateLimitConfig) *TokenBucket {
+    if cfg.Limit <= 0 || cfg.Per <= 0 {
+        return nil
+    }
+    if cfg.Burst <= 0 {
+        cfg.Burst = cfg.Limit
+    }
+
+    id := keyID(apiKey)
+    keyLimiters.Lock()
+    defer keyLimiters.Unlock()
+    if b, ok := keyLimiters.buckets[id]; ok {
+        return b
+    }
+    b := NewTokenBucket(cfg.Limit, cfg.Per, cfg.Burst)
+    keyLimiters.buckets[id] = b
+    return b
+}
+
+// keyID derives a short, non-reversible identifier for an API key so it can
+// be used as a metric label without leaking the key.
+func keyID(apiKey string) string {
+    sum := sha256.Sum256([]byte(apiKey))
+    return hex.EncodeToString(sum[:4])
+}
+
+// budgetDesc describes the remaining-budget gauge exported per API key.
+var budgetDesc = prometheus.NewDesc(
+    "gif_upstream_ratelimit_tokens",
+    "Remaining upstream request budget (tokens) per API key",
+    []string{"key_id"}, nil,
+)
+
+// budgetCollector computes each key's remaining budget at scrape time, so
+// the gauge reflects refills even while no requests are being made.
+type budgetCollector struct{}
+
+// Describe implements prometheus.Collector.
+func (budgetCollector) Describe(ch chan<- *prometheus.Desc) {
+    ch <- budgetDesc
+}
+
+// Collect implements prometheus.Collector.
+func (budgetCollector) Collect(ch chan<- prometheus.Metric) {
+    keyLimiters.Lock()
+    defer keyLimiters.Unlock()
+    for id, b := range keyLimiters.buckets {
+        ch <- prometheus.MustNewConstMetric(budgetDesc, prometheus.GaugeValue, b.Tokens(), id)
+    }
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+    "context"           // Provider methods take a request context
+    "encoding/json"     // to encode fake responses
+    "errors"            // for unwrapping QuotaError
+    "net/http"          // for HTTP handler types
+    "net/http/httptest" // to create a fake HTTP server
+    "sync/atomic"       // to count upstream hits safely
+    "testing"           // Go’s testing framework
+    "time"              // for advancing the fake clock
+)
+
+// TestTokenBucket_AllowAndRefill verifies burst capacity, rejection when
+// empty, and refill over time.
+func TestTokenBucket_AllowAndRefill(t *testing.T) {
+    bucket := NewTokenBucket(1, time.Second, 2) // 1/s, burst 2
+    now := time.Now()
+    bucket.now = func() time.Time { return now }
+    bucket.last = now
+
+    // 1) The burst is available immediately.
+    for i := 0; i < 2; i++ {
+        if ok, _ := bucket.Allow(); !ok {
+            t.Fatalf("expected token %d to be available", i+1)
+        }
+    }
+
+    // 2) The third call is refused with a ~1s hint.
+    ok, wait := bucket.Allow()
+    if ok || wait != time.Second {
+        t.Fatalf("expected refusal with 1s wait; got ok=%v wait=%s", ok, wait)
+    }
+
+    // 3) After a second, one token has been refilled.
+    now = now.Add(time.Second)
+    if ok, _ := bucket.Allow(); !ok {
+        t.Error("expected a refilled token after 1s")
+    }
+}
+
+// TestGiphyClient_RateLimit verifies that once the API key's budget is spent
+// the client rejects calls with a *QuotaError instead of contacting Giphy.
+func TestGiphyClient_RateLimit(t *testing.T) {
+    var hits int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&hits, 1)
+        json.NewEncoder(w).Encode(map[string]interface{}{"data": []interface{}{}})
+    }))
+    defer server.Close()
+
+    // A key unique to this test, so its bucket isn't shared with other tests.
+    client := NewGiphyClient(GiphyConfig{
+        APIKey:    "ratelimit-test-key",
+        BaseURL:   server.URL,
+        RateLimit: RateLimitConfig{Limit: 1, Per: time.Hour},
+    })
+    ctx := context.Background()
+
+    // 1) The first call spends the only token.
+    if _, err := client.Trending(ctx, TrendingParams{Limit: 1}); err != nil {
+        t.Fatalf("first call failed: %v", err)
+    }
+
+    // 2) The second is refused locally with a Retry-After hint.
+    _, err := client.Trending(ctx, TrendingParams{Limit: 1, Offset: 1})
+    var quotaErr *QuotaError
+    if !errors.As(err, &quotaErr) || quotaErr.RetryAfter <= 0 {
+        t.Fatalf("expected *QuotaError with RetryAfter; got %v", err)
+    }
+    if n := atomic.LoadInt32(&hits); n != 1 {
+        t.Errorf("expected 1 upstream hit; got %d", n)
+    }
+}
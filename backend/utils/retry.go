@@ -0,0 +1,153 @@
+package utils
+
+import (
+    "context"      // retries stop when the caller's deadline would be exceeded
+    "errors"       // for classifying errors
+    "math/rand/v2" // jitter
+    "net/http"     // retryable status codes
+    "strconv"      // attempt label
+    "time"         // backoff durations
+)
+
+// Defaults applied when a RetryPolicy field is zero.
+const (
+    // DefaultRetryMaxAttempts is the total number of attempts, including the first.
+    DefaultRetryMaxAttempts = 3
+
+    // DefaultRetryBaseDelay is the backoff ceiling before the first retry.
+    DefaultRetryBaseDelay = 100 * time.Millisecond
+
+    // DefaultRetryMaxDelay caps the backoff between two attempts.
+    DefaultRetryMaxDelay = 2 * time.Second
+)
+
+// defaultRetryableStatus lists upstream statuses worth retrying: rate
+// limiting and the transient gateway/availability errors.
+var defaultRetryableStatus = []int{
+    http.StatusTooManyRequests,
+    http.StatusBadGateway,
+    http.StatusServiceUnavailable,
+    http.StatusGatewayTimeout,
+}
+
+// RetryPolicy controls how the GiphyClient retries idempotent GETs.
+// Delays use exponential backoff with full jitter; a Retry-After header
+// from Giphy overrides the computed delay, up to MaxDelay (a longer one ends
+// the retries). No attempt is started if its
+// wait would run past the caller's context deadline.
+type RetryPolicy struct {
+    // MaxAttempts is the total number of attempts; 1 disables retries.
+    MaxAttempts int
+
+    // BaseDelay is the backoff ceiling before the first retry; it doubles each attempt.
+    BaseDelay time.Duration
+
+    // MaxDelay caps the computed backoff.
+    MaxDelay time.Duration
+
+    // RetryableStatus lists upstream HTTP statuses that are retried.
+    // Network errors are always retried.
+    RetryableStatus []int
+}
+
+// withDefaults fills zero fields of p with the package defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+    if p.MaxAttempts <= 0 {
+        p.MaxAttempts = DefaultRetryMaxAttempts
+    }
+    if p.BaseDelay <= 0 {
+        p.BaseDelay = DefaultRetryBaseDelay
+    }
+    if p.MaxDelay <= 0 {
+        p.MaxDelay = DefaultRetryMaxDelay
+    }
+    if len(p.RetryableStatus) == 0 {
+        p.RetryableStatus = defaultRetryableStatus
+    }
+    return p
+}
+
+// retryable reports whether err is worth another attempt. The caller's own
+// cancellation is checked separately; a per-attempt timeout counts as transient.
+func (p RetryPolicy) retryable(err error) bool {
+    // 0) Our own quota is spent: retrying would only spend more.
+    if errors.Is(err, ErrQuotaExhausted) {
+        return false
+    }
+
+    // 1) Upstream answered: retry only the configured statuses.
+    var upErr *UpstreamError
+    if errors.As(err, &upErr) {
+        for _, code := range p.RetryableStatus {
+            if upErr.StatusCode == code {
+                return true
+            }
+        }
+        return false
+    }
+
+    // 2) Anything else is a transport failure (reset, refused, timeout, ...): retry.
+    return true
+}
+
+// backoff returns the delay before attempt n+1 (n starts at 1): a random
+// duration in [0, min(MaxDelay, BaseDelay*2^(n-1))], unless the upstream
+// asked for a specific Retry-After. A Retry-After beyond MaxDelay reports
+// false: waiting that long would stall the caller, so we give up instead.
+func (p RetryPolicy) backoff(n int, err error) (time.Duration, bool) {
+    var upErr *UpstreamError
+    if errors.As(err, &upErr) && upErr.RetryAfter > 0 {
+        return upErr.RetryAfter, upErr.RetryAfter <= p.MaxDelay
+    }
+
+    ceiling := p.BaseDelay << (n - 1)
+    if ceiling > p.MaxDelay || ceiling <= 0 { // <= 0 guards against shift overflow
+        ceiling = p.MaxDelay
+    }
+    return rand.N(ceiling + 1), true
+}
+
+// withRetry calls attempt until it succeeds, fails with a non-retryable
+// error, runs out of attempts, or the next wait would outlive ctx.
+// Each attempt is recorded in the upstream attempt metrics under endpoint:
+// "retry" only when we go on to wait for another, "error" when we give up.
+func (p RetryPolicy) withRetry(ctx context.Context, endpoint string, attempt func(context.Context) ([]byte, error)) ([]byte, error) {
+    var lastErr error
+    for n := 1; n <= p.MaxAttempts; n++ {
+        // 1) Make the attempt and record its outcome.
+        start := time.Now()
+        body, err := attempt(ctx)
+        upstreamAttemptDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+        if err == nil {
+            upstreamAttemptsTotal.WithLabelValues(endpoint, strconv.Itoa(n), "success").Inc()
+            return body, nil
+        }
+        lastErr = err
+
+        // 2) Give up if the caller is gone, on permanent errors, or when
+        //    we're out of attempts.
+        if ctx.Err() != nil || !p.retryable(err) || n == p.MaxAttempts {
+            upstreamAttemptsTotal.WithLabelValues(endpoint, strconv.Itoa(n), "error").Inc()
+            return nil, err
+        }
+
+        // 3) Don't start a wait longer than MaxDelay, or one the caller's
+        //    deadline won't let us finish.
+        wait, ok := p.backoff(n, err)
+        if deadline, hasDeadline := ctx.Deadline(); !ok || hasDeadline && time.Until(deadline) <= wait {
+            upstreamAttemptsTotal.WithLabelValues(endpoint, strconv.Itoa(n), "error").Inc()
+            return nil, err
+        }
+
+        // 4) Sleep, but wake up if the caller goes away.
+        upstreamAttemptsTotal.WithLabelValues(endpoint, strconv.Itoa(n), "retry").Inc()
+        timer := time.NewTimer(wait)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return nil, err
+        case <-timer.C:
+        }
+    }
+    return nil, lastErr
+}
@@ -1,17 +1,150 @@
 package utils
 
-// GifImage holds a single image URL for a specific GIF variant.
-// The `json:"url"` tag tells Go’s JSON decoder to fill this field from the “url” key.
+import (
+    "encoding/json" // custom decoding for Giphy's stringly-typed numbers
+    "strconv"       // parsing numeric strings
+)
+
+// FlexInt is an integer that Giphy sometimes sends as a JSON string
+// ("width": "200") and sometimes as a number. It always encodes as a number.
+type FlexInt int
+
+// UnmarshalJSON accepts 200, "200", "" and null.
+func (n *FlexInt) UnmarshalJSON(b []byte) error {
+    // 1) Plain JSON number.
+    var i int
+    if err := json.Unmarshal(b, &i); err == nil {
+        *n = FlexInt(i)
+        return nil
+    }
+
+    // 2) Quoted number, or empty/null meaning "unknown".
+    var s string
+    if err := json.Unmarshal(b, &s); err != nil {
+        return err
+    }
+    if s == "" {
+        *n = 0
+        return nil
+    }
+    i, err := strconv.Atoi(s)
+    if err != nil {
+        return err
+    }
+    *n = FlexInt(i)
+    return nil
+}
+
+// GifImage describes one rendition of a GIF: its URL(s), dimensions and
+// sizes. Not every rendition carries every field (stills have no MP4, the
+// MP4-only renditions have no GIF URL), so unset fields are omitted.
 type GifImage struct {
-    URL string `json:"url"`
+    // URL is the GIF (or still image) URL for this rendition.
+    URL string `json:"url,omitempty"`
+
+    // Width and Height are the pixel dimensions.
+    Width  FlexInt `json:"width,omitempty"`
+    Height FlexInt `json:"height,omitempty"`
+
+    // Size is the size of the GIF file in bytes.
+    Size FlexInt `json:"size,omitempty"`
+
+    // MP4 is the URL of the MP4 video version, and MP4Size its size in bytes.
+    MP4     string  `json:"mp4,omitempty"`
+    MP4Size FlexInt `json:"mp4_size,omitempty"`
+
+    // WebP is the URL of the animated WebP version, and WebPSize its size in bytes.
+    WebP     string  `json:"webp,omitempty"`
+    WebPSize FlexInt `json:"webp_size,omitempty"`
+
+    // Frames is the number of animation frames.
+    Frames FlexInt `json:"frames,omitempty"`
+
+    // Hash is Giphy's content hash for the rendition.
+    Hash string `json:"hash,omitempty"`
 }
 
-// Images bundles all the different size/format variants for a GIF.
-// We only care about the “fixed_height” variant in our UI, but you could
-// add others (e.g., original, downsized) as additional fields here.
+// Images bundles all the different size/format variants Giphy provides for
+// a GIF. Renditions missing from a given response are omitted when encoded.
+// See https://developers.giphy.com/docs/optional-settings/#rendition-guide
 type Images struct {
-    // FixedHeight contains the URL and metadata for the fixed-height version.
-    FixedHeight GifImage `json:"fixed_height"`
+    // Original is the GIF as uploaded; OriginalStill its first frame,
+    // OriginalMP4 its MP4 encoding.
+    Original      GifImage `json:"original,omitzero"`
+    OriginalStill GifImage `json:"original_still,omitzero"`
+    OriginalMP4   GifImage `json:"original_mp4,omitzero"`
+
+    // Downsized variants are the original reduced to fit a file-size budget
+    // (2MB, 8MB, 5MB and 200KB respectively), plus a still of the 2MB version.
+    Downsized       GifImage `json:"downsized,omitzero"`
+    DownsizedLarge  GifImage `json:"downsized_large,omitzero"`
+    DownsizedMedium GifImage `json:"downsized_medium,omitzero"`
+    DownsizedSmall  GifImage `json:"downsized_small,omitzero"`
+    DownsizedStill  GifImage `json:"downsized_still,omitzero"`
+
+    // FixedHeight contains the URL and metadata for the 200px-high version
+    // used in our grid, followed by its still, lower-framerate and 100px variants.
+    FixedHeight            GifImage `json:"fixed_height"`
+    FixedHeightStill       GifImage `json:"fixed_height_still,omitzero"`
+    FixedHeightDownsampled GifImage `json:"fixed_height_downsampled,omitzero"`
+    FixedHeightSmall       GifImage `json:"fixed_height_small,omitzero"`
+    FixedHeightSmallStill  GifImage `json:"fixed_height_small_still,omitzero"`
+
+    // FixedWidth and friends mirror the fixed-height set at 200px (100px small) wide.
+    FixedWidth            GifImage `json:"fixed_width,omitzero"`
+    FixedWidthStill       GifImage `json:"fixed_width_still,omitzero"`
+    FixedWidthDownsampled GifImage `json:"fixed_width_downsampled,omitzero"`
+    FixedWidthSmall       GifImage `json:"fixed_width_small,omitzero"`
+    FixedWidthSmallStill  GifImage `json:"fixed_width_small_still,omitzero"`
+
+    // Preview renditions are tiny looping previews in MP4, GIF and WebP form.
+    Preview     GifImage `json:"preview,omitzero"`
+    PreviewGIF  GifImage `json:"preview_gif,omitzero"`
+    PreviewWebP GifImage `json:"preview_webp,omitzero"`
+
+    // Looping is a ~15s looping MP4; Still480w a 480px-wide still;
+    // HD a high-definition MP4 when available.
+    Looping   GifImage `json:"looping,omitzero"`
+    Still480w GifImage `json:"480w_still,omitzero"`
+    HD        GifImage `json:"hd,omitzero"`
+}
+
+// ByName returns every non-empty rendition keyed by its Giphy name
+// ("original", "fixed_height", "preview_webp", ...), for callers that
+// map renditions generically rather than field by field.
+func (i Images) ByName() map[string]GifImage {
+    all := map[string]GifImage{
+        "original":                 i.Original,
+        "original_still":           i.OriginalStill,
+        "original_mp4":             i.OriginalMP4,
+        "downsized":                i.Downsized,
+        "downsized_large":          i.DownsizedLarge,
+        "downsized_medium":         i.DownsizedMedium,
+        "downsized_small":          i.DownsizedSmall,
+        "downsized_still":          i.DownsizedStill,
+        "fixed_height":             i.FixedHeight,
+        "fixed_height_still":       i.FixedHeightStill,
+        "fixed_height_downsampled": i.FixedHeightDownsampled,
+        "fixed_height_small":       i.FixedHeightSmall,
+        "fixed_height_small_still": i.FixedHeightSmallStill,
+        "fixed_width":              i.FixedWidth,
+        "fixed_width_still":        i.FixedWidthStill,
+        "fixed_width_downsampled":  i.FixedWidthDownsampled,
+        "fixed_width_small":        i.FixedWidthSmall,
+        "fixed_width_small_still":  i.FixedWidthSmallStill,
+        "preview":                  i.Preview,
+        "preview_gif":              i.PreviewGIF,
+        "preview_webp":             i.PreviewWebP,
+        "looping":                  i.Looping,
+        "480w_still":               i.Still480w,
+        "hd":                       i.HD,
+    }
+    for name, img := range all {
+        if img == (GifImage{}) {
+            delete(all, name)
+        }
+    }
+    return all
 }
 
 // Gif represents one GIF item returned by the Giphy API.
@@ -23,8 +156,35 @@ type Gif struct {
     // Title is the descriptive text for the GIF.
     Title string `json:"title"`
 
-    // Images holds different size variants; we use Images.FixedHeight in our grid.
+    // Type is "gif" or "sticker".
+    Type string `json:"type,omitempty"`
+
+    // Images holds every rendition; the grid uses Images.FixedHeight, but
+    // clients can pick smaller previews, stills, MP4 or WebP variants.
     Images Images `json:"images"`
+
+    // URL is the GIF's page on giphy.com.
+    URL string `json:"url,omitempty"`
+
+    // Rating is the content rating Giphy assigned ("g", "pg", "pg-13", "r").
+    Rating string `json:"rating,omitempty"`
+
+    // Source is the page the GIF was originally found on, if known.
+    Source string `json:"source,omitempty"`
+
+    // Username is the uploader's Giphy username (empty for anonymous uploads).
+    Username string `json:"username,omitempty"`
+
+    // User holds the uploader's profile, when Giphy includes it.
+    User *GiphyUser `json:"user,omitempty"`
+}
+
+// GiphyUser is the uploader profile Giphy attaches to verified content.
+type GiphyUser struct {
+    Username    string `json:"username"`
+    DisplayName string `json:"display_name"`
+    ProfileURL  string `json:"profile_url"`
+    AvatarURL   string `json:"avatar_url"`
 }
 
 // Pagination contains metadata about the result set, such as total count,
@@ -49,3 +209,65 @@ type GiphyResponse struct {
     // Pagination holds paging metadata for the Data slice.
     Pagination Pagination `json:"pagination"`
 }
+
+// GiphySingleResponse mirrors Giphy's envelope for endpoints that return
+// exactly one GIF (e.g. /gifs/{id}), where "data" is an object, not an array.
+type GiphySingleResponse struct {
+    // Data is the single GIF item. It is the zero Gif when nothing matched.
+    Data Gif `json:"data"`
+}
+
+// UnmarshalJSON accepts the empty array /random and /translate send as
+// "data" when nothing matched, leaving Data as the zero Gif.
+func (r *GiphySingleResponse) UnmarshalJSON(b []byte) error {
+    // 1) Decode the envelope, keeping "data" raw.
+    var raw struct {
+        Data json.RawMessage `json:"data"`
+    }
+    if err := json.Unmarshal(b, &raw); err != nil {
+        return err
+    }
+
+    // 2) An array (always empty in practice), null or missing data means no match.
+    r.Data = Gif{}
+    if len(raw.Data) == 0 || raw.Data[0] == '[' || string(raw.Data) == "null" {
+        return nil
+    }
+    return json.Unmarshal(raw.Data, &r.Data)
+}
+
+// Tag is one search term suggested by Giphy's tag endpoints.
+type Tag struct {
+    // Name is the suggested term.
+    Name string `json:"name"`
+}
+
+// TagsResponse mirrors Giphy's envelope for /gifs/search/tags and
+// /tags/related/{term}.
+type TagsResponse struct {
+    // Data is the list of suggested terms.
+    Data []Tag `json:"data"`
+}
+
+// Category is one entry of Giphy's category tree. Top-level categories list
+// their subcategories by name; subcategory entries leave Subcategories empty.
+type Category struct {
+    // Name is the display name ("Animals").
+    Name string `json:"name"`
+
+    // NameEncoded is the URL-safe slug used in paths ("animals").
+    NameEncoded string `json:"name_encoded"`
+
+    // Subcategories lists the child categories, names only.
+    Subcategories []Category `json:"subcategories,omitempty"`
+
+    // Gif is a representative GIF for the category, when Giphy includes one.
+    Gif *Gif `json:"gif,omitempty"`
+}
+
+// CategoriesResponse mirrors Giphy's envelope for /gifs/categories and
+// /gifs/categories/{category}.
+type CategoriesResponse struct {
+    // Data is the list of categories.
+    Data []Category `json:"data"`
+}
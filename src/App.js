@@ -1,5 +1,5 @@
 import React, { useEffect, useState, useCallback } from "react";
-import { searchGIFs } from "./api/api";
+import { searchGIFs, ensureSession, listFavorites, addFavorite, removeFavorite } from "./api/api";
 import "./styles/App.css";
 
 function App() {
@@ -20,6 +20,8 @@ function App() {
   const [page, setPage] = useState(1);
   // animateId: temporarily holds a GIF id to trigger a favorite animation
   const [animateId, setAnimateId] = useState(null);
+  // notice: a message for the user, such as an expired session
+  const [notice, setNotice] = useState("");
   // rating & lang: filters for search requests, persisted to localStorage
   const [rating, setRating] = useState("g");
   const [lang, setLang] = useState("en");
@@ -58,7 +60,7 @@ function App() {
       // Call our backend directly
       const res  = await fetch(`${BACKEND_URL}/trending?limit=12&page=${page}`);
       const data = await res.json();
-      // GiphyResponse has shape { data: [...], pagination: {...} }
+      // Our API returns { version, data: [...], pagination: {...} }
       setGifs(data.data);
     } catch (err) {
       console.error("Failed to fetch trending GIFs", err);
@@ -93,16 +95,40 @@ function App() {
   // ─────────────────────────────────────────────────────────────────────────────
   // Favorites management
   // ─────────────────────────────────────────────────────────────────────────────
-  const toggleFavorite = (gif) => {
+  // Favorites are stored by the backend per user; sign in (as a guest if
+  // needed) and load them once on mount.
+  useEffect(() => {
+    ensureSession()
+      .then(({ expired }) => {
+        if (expired) {
+          setNotice("Your session expired, so a new one was started and your earlier favorites are no longer available.");
+        }
+        return listFavorites();
+      })
+      .then((favs) => setFavorites(favs.map((f) => f.gif)))
+      .catch((err) => {
+        // Without a session (e.g. guest accounts are disabled) there are no favorites.
+        console.error("Failed to load favorites", err);
+        setNotice(`Favorites are unavailable: ${err.message}`);
+      });
+  }, []);
+
+  const toggleFavorite = async (gif) => {
     const exists = favorites.some((f) => f.id === gif.id);
-    if (exists) {
-      // Remove from favorites
-      setFavorites(favorites.filter((f) => f.id !== gif.id));
-    } else {
-      // Add to favorites and trigger animation
-      setFavorites([...favorites, gif]);
-      setAnimateId(gif.id);
-      setTimeout(() => setAnimateId(null), 300);
+    try {
+      if (exists) {
+        // Remove from favorites
+        await removeFavorite(gif.id);
+        setFavorites((favs) => favs.filter((f) => f.id !== gif.id));
+      } else {
+        // Add to favorites (the backend returns its snapshot) and trigger animation
+        const fav = await addFavorite(gif.id);
+        setFavorites((favs) => [...favs, fav.gif]);
+        setAnimateId(gif.id);
+        setTimeout(() => setAnimateId(null), 300);
+      }
+    } catch (err) {
+      console.error("Failed to update favorites", err);
     }
   };
   const isFavorited = (gif) => favorites.some((f) => f.id === gif.id);
@@ -140,6 +166,14 @@ function App() {
         </div>
       </header>
 
+      {/* Notices, such as an expired session */}
+      {notice && (
+        <div className="notice" role="status">
+          {notice}
+          <button onClick={() => setNotice("")} aria-label="Dismiss">×</button>
+        </div>
+      )}
+
       {/* Filters & Search Bar (only in Trending tab) */}
       {activeTab === "trending" && (
         <>
@@ -203,7 +237,7 @@ function App() {
           {(activeTab === "trending" ? gifs : favorites).map((gif) => (
             <div className="gif-card" key={gif.id}>
               <div className="gif-wrapper">
-                <img src={gif.images.fixed_height.url} alt={gif.title} />
+                <img src={gif.renditions.fixed_height.url} alt={gif.title} />
                 <button
                   className={`heart-icon ${
                     isFavorited(gif) ? "active" : ""
@@ -27,8 +27,9 @@ export async function searchGIFs(
   // 2) Combine the backend base URL, API path, and query params.
   const url = `${BACKEND}/api/search${params}`;
 
-  // 3) Perform the HTTP GET request to our Go backend.
-  const res = await fetch(url);
+  // 3) Perform the HTTP GET request to our Go backend. The session cookie
+  //    goes along so the search lands in the user's history.
+  const res = await fetch(url, { credentials: "include" });
 
   // 4) If the response status is not in the 200–299 range, throw an error.
   if (!res.ok) {
@@ -36,7 +37,117 @@ export async function searchGIFs(
     throw new Error("Failed to search GIFs");
   }
 
-  // 5) Parse and return the JSON body. The shape matches our GiphyResponse struct
-  //    transformed by the backend: { data: [...], pagination: {...} }
+  // 5) Parse and return the JSON body. The shape is the backend's provider-neutral
+  //    schema: { version, data: [{ id, title, renditions, ... }], pagination: {...} }
   return res.json();
 }
+
+/**
+ * suggestTerms
+ *  - q:     the partial search term typed so far
+ *  - limit: maximum number of suggestions, defaults to 5
+ *
+ * Returns an array of suggested search terms (strings).
+ */
+export async function suggestTerms(q, limit = 5) {
+  // 1) Ask the backend's autocomplete endpoint; responses are browser-cacheable.
+  const url = `${BACKEND}/api/autocomplete?q=${encodeURIComponent(q)}&limit=${limit}`;
+  const res = await fetch(url);
+
+  // 2) Suggestions are a nicety: treat failures as "no suggestions".
+  if (!res.ok) {
+    return [];
+  }
+
+  // 3) The body is { version, query, data: ["term", ...] }.
+  const body = await res.json();
+  return body.data;
+}
+
+/**
+ * ensureSession makes sure the browser holds a session cookie, so the
+ * favorites and history endpoints know who is asking. Checking the session
+ * also renews it. Without one it asks for a guest account, which comes
+ * signed in with a session and lasts as long as that session is used.
+ *
+ * Returns { user, expired }: expired is true when the browser's previous
+ * session had run out, so the app can tell the user their old favorites
+ * are no longer available rather than starting over silently.
+ */
+export async function ensureSession() {
+  // 1) An existing session is still good, and now renewed.
+  const me = await fetch(`${BACKEND}/api/me`, { credentials: "include" });
+  if (me.ok) {
+    return { user: (await me.json()).data, expired: false };
+  }
+  const problem = await me.json().catch(() => ({}));
+  const expired = problem.cause === "session_expired";
+
+  // 2) Start a guest; the response sets its HttpOnly session cookie.
+  const guest = await fetch(`${BACKEND}/api/guests`, {
+    method: "POST",
+    credentials: "include",
+  });
+  if (!guest.ok) {
+    const failure = await guest.json().catch(() => ({}));
+    throw new Error(failure.detail || `Sign-in failed (${guest.status})`);
+  }
+  return { user: (await guest.json()).data, expired };
+}
+
+/**
+ * favoritesRequest
+ *  - path:   path under /api/favorites (e.g., "", "/order", "/abc123")
+ *  - method: HTTP method, defaults to GET
+ *  - body:   optional object sent as JSON
+ *
+ * Returns the parsed response body, or null for 204 No Content.
+ */
+async function favoritesRequest(path, method = "GET", body) {
+  // 1) Send JSON when there is a body.
+  const res = await fetch(`${BACKEND}/api/favorites${path}`, {
+    method,
+    headers: body ? { "Content-Type": "application/json" } : undefined,
+    body: body ? JSON.stringify(body) : undefined,
+    credentials: "include", // favorites belong to the session's user
+  });
+
+  // 2) Errors are problem documents; surface their detail.
+  if (!res.ok) {
+    const problem = await res.json().catch(() => ({}));
+    throw new Error(problem.detail || `Favorites request failed (${res.status})`);
+  }
+  return res.status === 204 ? null : res.json();
+}
+
+/**
+ * listFavorites returns the saved favorites in order, each
+ * { gif: { id, title, renditions, ... }, added_at }.
+ */
+export async function listFavorites() {
+  const body = await favoritesRequest("");
+  return body.data;
+}
+
+/**
+ * addFavorite saves the GIF with the given id; the backend stores a snapshot.
+ */
+export async function addFavorite(id) {
+  const body = await favoritesRequest("", "POST", { id });
+  return body.data;
+}
+
+/**
+ * removeFavorite deletes the GIF with the given id from the favorites.
+ */
+export async function removeFavorite(id) {
+  await favoritesRequest(`/${encodeURIComponent(id)}`, "DELETE");
+}
+
+/**
+ * reorderFavorites saves a new order; ids must list every favorite once.
+ */
+export async function reorderFavorites(ids) {
+  const body = await favoritesRequest("/order", "PUT", { ids });
+  return body.data;
+}
@@ -1,4 +1,8 @@
-import React from "react";
+import React, { useEffect, useState } from "react";
+import { suggestTerms } from "../api/api";
+
+// How long to wait after the last keystroke before asking for suggestions.
+const SUGGEST_DEBOUNCE_MS = 200;
 
 /**
  * SearchBar renders an input field and a button for searching GIFs.
@@ -9,6 +13,28 @@ import React from "react";
  * - handleSearch: function to trigger the search action (e.g., fetch GIFs)
  */
 function SearchBar({ searchTerm, setSearchTerm, handleSearch }) {
+  // suggestions: autocomplete terms for the current input
+  const [suggestions, setSuggestions] = useState([]);
+
+  // Fetch suggestions once typing pauses; ignore responses for stale input.
+  useEffect(() => {
+    const term = searchTerm.trim();
+    if (term === "") {
+      setSuggestions([]);
+      return;
+    }
+    let cancelled = false;
+    const timer = setTimeout(() => {
+      suggestTerms(term)
+        .then((terms) => !cancelled && setSuggestions(terms))
+        .catch(() => !cancelled && setSuggestions([]));
+    }, SUGGEST_DEBOUNCE_MS);
+    return () => {
+      cancelled = true;
+      clearTimeout(timer);
+    };
+  }, [searchTerm]);
+
   return (
     <div className="search-bar">
       {/*
@@ -22,7 +48,16 @@ function SearchBar({ searchTerm, setSearchTerm, handleSearch }) {
         value={searchTerm}
         onChange={(e) => setSearchTerm(e.target.value)}
         onKeyDown={(e) => e.key === "Enter" && handleSearch()}
+        list="search-suggestions"
       />
+      {/*
+        The browser shows these as a dropdown under the input.
+      */}
+      <datalist id="search-suggestions">
+        {suggestions.map((term) => (
+          <option key={term} value={term} />
+        ))}
+      </datalist>
       {/*
         The Search button invokes handleSearch when clicked.
       */}